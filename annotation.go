@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// AnnotatedComparison is a comparison with notes on some of the changed files, e.g. to explain expected changes
+// when reporting them in a pull request.
+type AnnotatedComparison struct {
+	*Comparison
+	Notes map[string]string // Notes by path of changed file; the new path for renamed or moved files.
+}
+
+// Annotate returns the comparison with the notes attached to the changed files. The notes of renamed or moved files
+// can be given for their old or new path. Notes for paths that did not change are ignored.
+func (c *Comparison) Annotate(notes map[string]string) *AnnotatedComparison {
+	changed := make(map[string]bool)
+	for _, paths := range [][]string{c.Added, c.Modified, c.Deleted, c.Unstable} {
+		for _, path := range paths {
+			changed[path] = true
+		}
+	}
+	for _, file := range c.Truncated {
+		changed[file.Path] = true
+	}
+	for _, change := range c.PermissionsChanged {
+		changed[change.Path] = true
+	}
+
+	annotated := &AnnotatedComparison{Comparison: c, Notes: make(map[string]string)}
+	for path, note := range notes {
+		if changed[path] {
+			annotated.Notes[path] = note
+		}
+	}
+	for _, file := range c.RenamedOrMoved {
+		if note, exists := notes[file.OldPath]; exists {
+			annotated.Notes[file.NewPath] = note
+		}
+		if note, exists := notes[file.NewPath]; exists {
+			annotated.Notes[file.NewPath] = note
+		}
+	}
+
+	return annotated
+}
+
+// LoadNotes loads notes for Annotate from a JSON file mapping paths to notes, e.g. {"file.go": "part of the refactor"}.
+func LoadNotes(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notes: %w", err)
+	}
+
+	notes := map[string]string{}
+	if err := json.Unmarshal(data, &notes); err != nil {
+		return nil, fmt.Errorf("failed to parse notes: %w", err)
+	}
+
+	return notes, nil
+}
+
+// ToMarkdown returns the comparison as Markdown, e.g. for a comment of a pull request: a list of files per type
+// of change, each followed by its note in italic if any, then the number of changes.
+func (a *AnnotatedComparison) ToMarkdown() string {
+	var sb strings.Builder
+
+	if !a.HasChanges() {
+		sb.WriteString("No changes detected\n")
+		return sb.String()
+	}
+
+	section := func(title string, items []string, paths []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&sb, "### %s\n\n", title)
+		for i, item := range items {
+			sb.WriteString("- " + item)
+			if note, exists := a.Notes[paths[i]]; exists {
+				sb.WriteString(" " + markdownItalic(note))
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+	pathSection := func(title string, paths []string) {
+		items := make([]string, len(paths))
+		for i, path := range paths {
+			items[i] = markdownCode(path)
+		}
+		section(title, items, paths)
+	}
+
+	pathSection("Unstable", a.Unstable)
+
+	var items, paths []string
+	for _, file := range a.Truncated {
+		items = append(items, fmt.Sprintf("%s (was %s)", markdownCode(file.Path), FormatSize(file.OriginalSize)))
+		paths = append(paths, file.Path)
+	}
+	section("Truncated", items, paths)
+
+	pathSection("Added", a.Added)
+	pathSection("Modified", a.Modified)
+
+	items, paths = nil, nil
+	for _, change := range a.PermissionsChanged {
+		items = append(items, fmt.Sprintf("%s (%o → %o)", markdownCode(change.Path), change.OldMode, change.NewMode))
+		paths = append(paths, change.Path)
+	}
+	section("Permissions changed", items, paths)
+
+	items, paths = nil, nil
+	for _, file := range a.RenamedOrMoved {
+		items = append(items, markdownCode(file.OldPath)+" → "+markdownCode(file.NewPath))
+		paths = append(paths, file.NewPath)
+	}
+	section("Renamed/Moved", items, paths)
+
+	pathSection("Deleted", a.Deleted)
+
+	fmt.Fprintf(&sb, "**%d added, %d modified, %d renamed/moved, %d deleted**\n",
+		len(a.Added), len(a.Modified), len(a.RenamedOrMoved), len(a.Deleted))
+
+	return sb.String()
+}
+
+// markdownCode returns s as inline code, delimited by more backticks than it contains in a row.
+func markdownCode(s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+
+	fence := strings.Repeat("`", longest+1)
+	if longest > 0 {
+		return fence + " " + s + " " + fence
+	}
+	return fence + s + fence
+}
+
+// markdownItalic returns s in italic, escaping the characters that would end it early.
+func markdownItalic(s string) string {
+	return "*" + strings.NewReplacer(`\`, `\\`, "*", `\*`, "\n", " ").Replace(s) + "*"
+}
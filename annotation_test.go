@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnnotateToMarkdown(t *testing.T) {
+	comparison := &Comparison{
+		Added:          []string{"new.go"},
+		Modified:       []string{"main.go"},
+		Deleted:        []string{},
+		RenamedOrMoved: []RenamedOrMovedFile{{OldPath: "old.go", NewPath: "renamed.go"}},
+	}
+
+	annotated := comparison.Annotate(map[string]string{
+		"new.go":       "part of the refactor",
+		"old.go":       "moved with the refactor",
+		"unchanged.go": "not a change",
+	})
+
+	if len(annotated.Notes) != 2 {
+		t.Errorf("expected 2 notes for the changed files, got %d: %v", len(annotated.Notes), annotated.Notes)
+	}
+
+	markdown := annotated.ToMarkdown()
+	for _, expected := range []string{
+		"### Added\n\n- `new.go` *part of the refactor*\n",
+		"### Modified\n\n- `main.go`\n",
+		"- `old.go` → `renamed.go` *moved with the refactor*\n",
+		"**1 added, 1 modified, 1 renamed/moved, 0 deleted**\n",
+	} {
+		if !strings.Contains(markdown, expected) {
+			t.Errorf("expected markdown to contain %q, got:\n%s", expected, markdown)
+		}
+	}
+	if strings.Contains(markdown, "unchanged.go") || strings.Contains(markdown, "not a change") {
+		t.Errorf("expected no note for an unchanged file, got:\n%s", markdown)
+	}
+}
+
+func TestMarkdownEscaping(t *testing.T) {
+	tests := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"plain code", markdownCode("file.go"), "`file.go`"},
+		{"code with backticks", markdownCode("a`b``c.go"), "``` a`b``c.go ```"},
+		{"plain italic", markdownItalic("expected"), "*expected*"},
+		{"italic with stars", markdownItalic("a*b\nc"), `*a\*b c*`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, tt.got)
+			}
+		})
+	}
+}
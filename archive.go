@@ -0,0 +1,143 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Types of sources of the indexed files.
+const (
+	SourceFilesystem = "filesystem"
+	SourceTar        = "tar"
+)
+
+// ArchiveIndexSuffix is appended to the path of an archive to get the path of its index file.
+const ArchiveIndexSuffix = ".bff.json"
+
+// errArchiveIndex is returned by the operations that need to access the indexed files on disk.
+var errArchiveIndex = errors.New("not supported for the index of an archive, whose files are not on disk")
+
+// isArchive returns true if the index was built from an archive rather than a directory.
+func (idx *Index) isArchive() bool {
+	return idx.SourceType == SourceTar
+}
+
+// IndexTar reads a tar archive, compressed with gzip or not, and adds its regular files to the index with
+// their path in the archive, without extracting them. The hidden files, patterns, depth and size settings
+// of the index apply. It returns the number of files indexed. The index file is not saved.
+func (idx *Index) IndexTar(r io.Reader) (int, error) {
+	if _, err := newHasher(idx.HashAlgorithm); err != nil {
+		return 0, err
+	}
+	idx.SourceType = SourceTar
+
+	reader := bufio.NewReader(r)
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read gzip stream: %w", err)
+		}
+		defer gzipReader.Close()
+		r = gzipReader
+	} else {
+		r = reader
+	}
+
+	// Archives may contain several versions of a file, the last one wins like when extracting.
+	type archiveFile struct {
+		hash     string
+		fileInfo *FileInfo
+	}
+	files := make(map[string]archiveFile)
+	paths := []string{}
+
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return len(files), fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		info := header.FileInfo()
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		relPath := archiveRelPath(header.Name)
+		if relPath == "" || idx.isHidden(relPath) || !idx.isSelected(relPath, info) {
+			continue
+		}
+
+		hash, err := hashReader(tarReader, idx.HashAlgorithm)
+		if err != nil {
+			return len(files), fmt.Errorf("failed to hash %s: %w", header.Name, err)
+		}
+
+		if _, exists := files[relPath]; !exists {
+			paths = append(paths, relPath)
+		}
+		files[relPath] = archiveFile{hash: hash, fileInfo: &FileInfo{
+			Path:    relPath,
+			Size:    header.Size,
+			ModTime: header.ModTime,
+		}}
+	}
+
+	// The files of the archive replace the ones already indexed at the same path.
+	for hash, indexed := range idx.FilesByContentHash {
+		kept := indexed[:0]
+		for _, file := range indexed {
+			if _, replaced := files[file.Path]; !replaced {
+				kept = append(kept, file)
+			}
+		}
+		if len(kept) == 0 {
+			delete(idx.FilesByContentHash, hash)
+		} else {
+			idx.FilesByContentHash[hash] = kept
+		}
+	}
+
+	for _, relPath := range paths {
+		file := files[relPath]
+		idx.FilesByContentHash[file.hash] = append(idx.FilesByContentHash[file.hash], file.fileInfo)
+	}
+
+	return len(files), nil
+}
+
+// archiveRelPath returns the path of an archive entry as a clean relative path using the OS separator,
+// or an empty path if it is outside of the archive root.
+func archiveRelPath(name string) string {
+	cleaned := path.Clean("/" + name)
+	relPath := strings.TrimPrefix(cleaned, "/")
+	if relPath == "" {
+		return ""
+	}
+	return filepath.FromSlash(relPath)
+}
+
+// hashReader returns the hex-encoded hash of the content of r.
+func hashReader(r io.Reader, algorithm string) (string, error) {
+	hasher, err := getHasher(algorithm)
+	if err != nil {
+		return "", err
+	}
+	defer putHasher(algorithm, hasher)
+
+	if _, err := io.Copy(hasher, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
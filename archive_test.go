@@ -0,0 +1,143 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// buildTar returns a tar archive of the given files, with a directory entry, gzipped if compress is true.
+func buildTar(t *testing.T, files map[string]string, compress bool) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var gzipWriter *gzip.Writer
+	if compress {
+		gzipWriter = gzip.NewWriter(&buf)
+		w = gzipWriter
+	}
+	tarWriter := tar.NewWriter(w)
+
+	if err := tarWriter.WriteHeader(&tar.Header{Name: "docs/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), ModTime: time.Unix(1700000000, 0)}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gzipWriter != nil {
+		if err := gzipWriter.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestIndexTar(t *testing.T) {
+	files := map[string]string{
+		"docs/report.txt":        "same content",
+		"backup/report-copy.txt": "same content",
+		"notes.txt":              "unique content",
+		".hidden/secret.txt":     "same content",
+	}
+
+	for _, compress := range []bool{true, false} {
+		name := "tar"
+		if compress {
+			name = "tar.gz"
+		}
+		t.Run(name, func(t *testing.T) {
+			idx := NewIndexWithOptions(IndexOptions{RootPath: filepath.Join(t.TempDir(), "archive."+name)})
+
+			count, err := idx.IndexTar(bytes.NewReader(buildTar(t, files, compress)))
+			if err != nil {
+				t.Fatalf("IndexTar failed: %v", err)
+			}
+			if count != 3 {
+				t.Errorf("expected 3 files indexed without the hidden one, got %d", count)
+			}
+			if idx.SourceType != SourceTar {
+				t.Errorf("expected source type %q, got %q", SourceTar, idx.SourceType)
+			}
+
+			duplicates := idx.FindAllDuplicates()
+			group, exists := duplicates[computeHash([]byte("same content"))]
+			if len(duplicates) != 1 || !exists || len(group) != 2 {
+				t.Fatalf("expected one group of 2 duplicates, got %v", duplicates)
+			}
+
+			file, _ := idx.FindByPath(filepath.Join("docs", "report.txt"))
+			if file == nil || file.Size != int64(len("same content")) {
+				t.Errorf("expected docs/report.txt with its size from the tar header, got %+v", file)
+			}
+		})
+	}
+}
+
+func TestIndexTarSaveAndLoad(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "archive.tar.gz")
+	idx := NewIndexWithOptions(IndexOptions{RootPath: archivePath})
+	if _, err := idx.IndexTar(bytes.NewReader(buildTar(t, map[string]string{"a.txt": "a"}, true))); err != nil {
+		t.Fatalf("IndexTar failed: %v", err)
+	}
+	if err := idx.save(); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if idx.indexPath() != archivePath+ArchiveIndexSuffix {
+		t.Errorf("expected the index next to the archive, got %s", idx.indexPath())
+	}
+
+	loaded := NewIndexWithOptions(IndexOptions{RootPath: archivePath})
+	loaded.SourceType = SourceTar
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.FileCount() != 1 {
+		t.Errorf("expected 1 file in the loaded index, got %d", loaded.FileCount())
+	}
+	if _, err := loaded.Compare(); err == nil {
+		t.Error("expected comparing an archive index to fail")
+	}
+}
+
+func TestIndexTarRepeatedEntries(t *testing.T) {
+	var buf bytes.Buffer
+	tarWriter := tar.NewWriter(&buf)
+	for _, entry := range []struct{ name, content string }{{"file.txt", "old"}, {"other.txt", "other"}, {"./file.txt", "new"}} {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: filepath.Join(t.TempDir(), "archive.tar")})
+	count, err := idx.IndexTar(&buf)
+	if err != nil {
+		t.Fatalf("IndexTar failed: %v", err)
+	}
+	if count != 2 || idx.FileCount() != 2 {
+		t.Errorf("expected 2 files indexed, got %d (%d in the index)", count, idx.FileCount())
+	}
+	if _, hash := idx.FindByPath("file.txt"); hash != computeHash([]byte("new")) {
+		t.Errorf("expected the last version of file.txt to be indexed, got %s", hash)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// rename is used to move the temp files to their target, and can be replaced in tests.
+var rename = os.Rename
+
+// atomicWriteFile writes data to the file at path, using a temp file in the same directory
+// so that the file is never left partially written.
+func atomicWriteFile(path string, data []byte, perm os.FileMode) error {
+	return atomicWriteFileTo(path, filepath.Dir(path), data, perm)
+}
+
+// atomicWriteFileTo writes data to the file at targetPath through a temp file created in tempDir,
+// or in os.TempDir() if tempDir is empty.
+// When tempDir is on another device than the target, the temp file is copied then deleted instead
+// of renamed, in which case the write is no longer atomic.
+func atomicWriteFileTo(targetPath, tempDir string, data []byte, perm os.FileMode) error {
+	if tempDir == "" {
+		tempDir = os.TempDir()
+	}
+
+	tempFile, err := os.CreateTemp(tempDir, tempFilePrefix(targetPath)+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath)
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tempFile.Sync(); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Chmod(tempPath, perm); err != nil {
+		return fmt.Errorf("failed to set temp file permissions: %w", err)
+	}
+
+	err = rename(tempPath, targetPath)
+	if errors.Is(err, syscall.EXDEV) {
+		err = copyFile(tempPath, targetPath, perm)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to move temp file to %s: %w", targetPath, err)
+	}
+
+	return nil
+}
+
+// tempFilePrefix returns the prefix of the names of the temp files used to write the file at targetPath.
+func tempFilePrefix(targetPath string) string {
+	return "." + filepath.Base(targetPath) + ".tmp-"
+}
+
+// isTempFileOf returns true if path is a temp file used to write the file at targetPath in its directory.
+func isTempFileOf(path, targetPath string) bool {
+	return filepath.Dir(path) == filepath.Dir(targetPath) && strings.HasPrefix(filepath.Base(path), tempFilePrefix(targetPath))
+}
+
+// copyFile copies the content of the file at src to dst, creating or truncating it.
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
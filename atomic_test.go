@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestAtomicWriteFileTo(t *testing.T) {
+	targetDir := t.TempDir()
+	tempDir := t.TempDir()
+	targetPath := filepath.Join(targetDir, "bff.json")
+
+	if err := os.WriteFile(targetPath, []byte("old content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	if err := atomicWriteFileTo(targetPath, tempDir, []byte("new content"), 0644); err != nil {
+		t.Fatalf("atomicWriteFileTo() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(targetPath)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(data) != "new content" {
+		t.Errorf("expected 'new content', got %q", data)
+	}
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("failed to read temp directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected temp directory to be empty, got %d entries", len(entries))
+	}
+}
+
+func TestAtomicWriteFileToCrossDevice(t *testing.T) {
+	targetDir := t.TempDir()
+	tempDir := t.TempDir()
+	targetPath := filepath.Join(targetDir, "bff.json")
+
+	renamed := false
+	rename = func(oldPath, newPath string) error {
+		renamed = true
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: syscall.EXDEV}
+	}
+	defer func() { rename = os.Rename }()
+
+	if err := atomicWriteFileTo(targetPath, tempDir, []byte("content"), 0600); err != nil {
+		t.Fatalf("atomicWriteFileTo() failed: %v", err)
+	}
+	if !renamed {
+		t.Error("expected rename to be attempted")
+	}
+
+	data, err := os.ReadFile(targetPath)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(data) != "content" {
+		t.Errorf("expected 'content', got %q", data)
+	}
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("failed to read temp directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected temp file to be deleted after the copy, got %d entries", len(entries))
+	}
+}
+
+func TestAtomicWriteFileToRenameError(t *testing.T) {
+	targetPath := filepath.Join(t.TempDir(), "bff.json")
+
+	rename = func(oldPath, newPath string) error {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: syscall.EACCES}
+	}
+	defer func() { rename = os.Rename }()
+
+	if err := atomicWriteFileTo(targetPath, t.TempDir(), []byte("content"), 0644); err == nil {
+		t.Error("expected error when rename fails for another reason than a cross-device move")
+	}
+	if _, err := os.Stat(targetPath); !os.IsNotExist(err) {
+		t.Error("expected target file not to be created")
+	}
+}
+
+func TestIsTempFileOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected bool
+	}{
+		{"temp file", "/data/.bff.json.tmp-123", true},
+		{"target itself", "/data/bff.json", false},
+		{"other directory", "/other/.bff.json.tmp-123", false},
+		{"other file", "/data/.other.json.tmp-123", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTempFileOf(filepath.FromSlash(tt.path), filepath.FromSlash("/data/bff.json")); got != tt.expected {
+				t.Errorf("isTempFileOf(%s) = %v, expected %v", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
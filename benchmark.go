@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// BenchmarkSampleSize is the maximum amount of data read from a directory by each benchmark.
+const BenchmarkSampleSize = 100 << 20
+
+// hashAlgorithmNames are the display names of the hash algorithms in the benchmark results.
+var hashAlgorithmNames = map[string]string{
+	HashSHA256:  "SHA-256",
+	HashSHA512:  "SHA-512",
+	HashBLAKE2b: "BLAKE2b",
+	HashMD5:     "MD5",
+	HashXXHash:  "xxHash",
+}
+
+// Benchmarker measures the throughput of hashing data with an algorithm, or of only reading it if the algorithm is empty.
+type Benchmarker struct {
+	Algorithm string
+}
+
+// BenchmarkResult is the outcome of a benchmark.
+type BenchmarkResult struct {
+	Name     string        // Name of the algorithm, or "Read" for reading without hashing.
+	Bytes    int64         // Amount of data processed.
+	Duration time.Duration // Time taken to process the data.
+	Err      error         // Error that stopped the benchmark, if any.
+}
+
+// Throughput returns the amount of data processed per second, in MB/s.
+func (r BenchmarkResult) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / bytesPerMB / r.Duration.Seconds()
+}
+
+// add accumulates the result of another benchmark of the same kind.
+func (r *BenchmarkResult) add(other BenchmarkResult) {
+	r.Bytes += other.Bytes
+	r.Duration += other.Duration
+	if r.Err == nil {
+		r.Err = other.Err
+	}
+}
+
+// name returns the display name of the benchmark.
+func (b *Benchmarker) name() string {
+	if b.Algorithm == "" {
+		return "Read"
+	}
+	if name, exists := hashAlgorithmNames[b.Algorithm]; exists {
+		return name
+	}
+	return b.Algorithm
+}
+
+// Run reads up to size bytes of testData, hashing them with the algorithm of the benchmarker, and returns how long it took.
+func (b *Benchmarker) Run(testData io.Reader, size int64) BenchmarkResult {
+	result := BenchmarkResult{Name: b.name()}
+
+	var w io.Writer = io.Discard
+	if b.Algorithm != "" {
+		hasher, err := newHasher(b.Algorithm)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		w = hasher
+	}
+
+	start := time.Now()
+	n, err := io.CopyN(w, testData, size)
+	result.Duration = time.Since(start)
+	result.Bytes = n
+	if err != nil && err != io.EOF {
+		result.Err = fmt.Errorf("failed to read test data: %w", err)
+	}
+
+	return result
+}
+
+// RunFiles runs the benchmark on the given files, up to BenchmarkSampleSize bytes in total,
+// and returns the total amount of data processed and time taken.
+func (b *Benchmarker) RunFiles(paths []string) BenchmarkResult {
+	total := BenchmarkResult{Name: b.name()}
+
+	for _, path := range paths {
+		remaining := BenchmarkSampleSize - total.Bytes
+		if remaining <= 0 {
+			break
+		}
+
+		file, err := os.Open(longPath(path))
+		if err != nil {
+			total.add(BenchmarkResult{Err: fmt.Errorf("failed to open file: %w", err)})
+			break
+		}
+		total.add(b.Run(file, remaining))
+		file.Close()
+		if total.Err != nil {
+			break
+		}
+	}
+
+	return total
+}
+
+// HashBenchmark records how long each file took to hash while indexing, e.g. to find the slow files of a network drive.
+type HashBenchmark struct {
+	entries []BenchmarkEntry
+}
+
+// BenchmarkEntry is the time taken to hash a file.
+type BenchmarkEntry struct {
+	Path     string
+	Size     int64
+	Duration time.Duration
+}
+
+// Throughput returns the amount of data of the file hashed per second, in MB/s.
+func (e BenchmarkEntry) Throughput() float64 {
+	if e.Duration <= 0 {
+		return 0
+	}
+	return float64(e.Size) / bytesPerMB / e.Duration.Seconds()
+}
+
+// Record adds the time taken to hash the file at the given path.
+func (b *HashBenchmark) Record(path string, duration time.Duration, size int64) {
+	b.entries = append(b.entries, BenchmarkEntry{Path: path, Size: size, Duration: duration})
+}
+
+// TopSlowest returns the n files that took the longest to hash, slowest first, or all of them if there are fewer.
+func (b *HashBenchmark) TopSlowest(n int) []BenchmarkEntry {
+	slowest := append([]BenchmarkEntry{}, b.entries...)
+	sort.Slice(slowest, func(i, j int) bool {
+		if slowest[i].Duration != slowest[j].Duration {
+			return slowest[i].Duration > slowest[j].Duration
+		}
+		return slowest[i].Path < slowest[j].Path
+	})
+
+	if n < len(slowest) {
+		slowest = slowest[:n]
+	}
+	return slowest
+}
+
+// BenchmarkDirectory measures the throughput of reading the files of a directory without hashing them,
+// then of hashing them with each supported algorithm, on a sample of up to BenchmarkSampleSize bytes.
+func BenchmarkDirectory(dir string) ([]BenchmarkResult, error) {
+	paths, err := sampleFiles(dir, BenchmarkSampleSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files to benchmark in %s", dir)
+	}
+
+	benchmarkers := []*Benchmarker{{}}
+	for _, algorithm := range SupportedHashAlgorithms() {
+		benchmarkers = append(benchmarkers, &Benchmarker{Algorithm: algorithm})
+	}
+
+	results := make([]BenchmarkResult, 0, len(benchmarkers))
+	for _, benchmarker := range benchmarkers {
+		result := benchmarker.RunFiles(paths)
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// sampleFiles returns the paths of the non-empty regular files of a directory, in walk order, until their total size
+// reaches maxSize.
+func sampleFiles(dir string, maxSize int64) ([]string, error) {
+	paths := []string{}
+	var total int64
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || filepath.Base(path) == IndexFile {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() == 0 {
+			return nil
+		}
+
+		paths = append(paths, path)
+		total += info.Size()
+		if total >= maxSize {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files to benchmark: %w", err)
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBenchmarkerRun(t *testing.T) {
+	data := make([]byte, 4<<20)
+
+	for _, algorithm := range append([]string{""}, SupportedHashAlgorithms()...) {
+		benchmarker := &Benchmarker{Algorithm: algorithm}
+		t.Run(benchmarker.name(), func(t *testing.T) {
+			result := benchmarker.Run(bytes.NewReader(data), int64(len(data)))
+			if result.Err != nil {
+				t.Fatalf("Run failed: %v", result.Err)
+			}
+			if result.Bytes != int64(len(data)) {
+				t.Errorf("expected %d bytes processed, got %d", len(data), result.Bytes)
+			}
+			if result.Throughput() <= 0 {
+				t.Errorf("expected a positive throughput, got %f", result.Throughput())
+			}
+		})
+	}
+}
+
+func TestBenchmarkDirectory(t *testing.T) {
+	testDir := t.TempDir()
+	for _, name := range []string{"a.bin", "b.bin"} {
+		if err := os.WriteFile(filepath.Join(testDir, name), make([]byte, 1<<20), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := BenchmarkDirectory(testDir)
+	if err != nil {
+		t.Fatalf("BenchmarkDirectory failed: %v", err)
+	}
+
+	if len(results) != len(SupportedHashAlgorithms())+1 {
+		t.Fatalf("expected a result for reading and for each algorithm, got %d", len(results))
+	}
+	for _, result := range results {
+		if result.Bytes != 2<<20 {
+			t.Errorf("expected %s to process both files, got %d bytes", result.Name, result.Bytes)
+		}
+	}
+
+	if _, err := BenchmarkDirectory(t.TempDir()); err == nil {
+		t.Error("expected an error for a directory without files")
+	}
+}
+
+func TestHashBenchmarkTopSlowest(t *testing.T) {
+	benchmark := &HashBenchmark{}
+	benchmark.Record("fast.txt", time.Millisecond, 1<<20)
+	benchmark.Record("slow.pdf", 30*time.Second, 3<<20)
+	benchmark.Record("medium.bin", time.Second, 10<<20)
+	benchmark.Record("tied.bin", time.Second, 1<<20)
+
+	for _, n := range []int{0, 2, 4, 10} {
+		top := benchmark.TopSlowest(n)
+		if len(top) != min(n, 4) {
+			t.Fatalf("TopSlowest(%d): expected %d entries, got %d", n, min(n, 4), len(top))
+		}
+		for i := 1; i < len(top); i++ {
+			if top[i].Duration > top[i-1].Duration {
+				t.Errorf("TopSlowest(%d): entries not sorted by duration: %v", n, top)
+			}
+		}
+	}
+
+	top := benchmark.TopSlowest(3)
+	if top[0].Path != "slow.pdf" || top[1].Path != "medium.bin" || top[2].Path != "tied.bin" {
+		t.Errorf("expected slow.pdf, medium.bin and tied.bin, got %v", top)
+	}
+	if throughput := top[1].Throughput(); throughput != 10 {
+		t.Errorf("expected a throughput of 10 MB/s, got %.1f", throughput)
+	}
+}
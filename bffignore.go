@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile is the name of the file at the root of the indexed directory containing its exclusion patterns.
+const IgnoreFile = ".bffignore"
+
+// LoadIgnoreFile reads the exclusion patterns of the .bffignore file at the root of the indexed directory into
+// IgnorePatterns, one glob pattern per line, skipping empty lines and comments starting with "#". There are no
+// patterns if the file doesn't exist. The scans call it before walking the directory.
+func (idx *Index) LoadIgnoreFile() error {
+	idx.IgnorePatterns = nil
+
+	file, err := os.Open(filepath.Join(idx.AbsPath, IgnoreFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", IgnoreFile, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		idx.IgnorePatterns = append(idx.IgnorePatterns, filepath.FromSlash(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", IgnoreFile, err)
+	}
+
+	return nil
+}
+
+// isIgnored returns true if the file or directory at the given relative path, or one of its parent directories,
+// matches one of the patterns of the .bffignore file. A pattern starting with "/" is matched against the whole
+// relative path, the other ones against the end of the path, e.g. "*.tmp" or "cache/*.bin" anywhere in the tree.
+func (idx *Index) isIgnored(relPath string) bool {
+	for _, pattern := range idx.IgnorePatterns {
+		anchored := strings.HasPrefix(pattern, string(filepath.Separator))
+		pattern = strings.TrimLeft(pattern, string(filepath.Separator))
+		depth := pathDepth(pattern)
+
+		for path := relPath; path != "." && path != string(filepath.Separator); path = filepath.Dir(path) {
+			name := path
+			if !anchored {
+				parts := strings.Split(path, string(filepath.Separator))
+				if len(parts) < depth {
+					continue
+				}
+				name = filepath.Join(parts[len(parts)-depth:]...)
+			}
+			if matched, _ := filepath.Match(pattern, name); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
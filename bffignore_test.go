@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadIgnoreFile(t *testing.T) {
+	testDir := t.TempDir()
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := idx.LoadIgnoreFile(); err != nil {
+		t.Fatalf("LoadIgnoreFile() failed without %s: %v", IgnoreFile, err)
+	}
+	if len(idx.IgnorePatterns) != 0 {
+		t.Errorf("expected no patterns without %s, got %v", IgnoreFile, idx.IgnorePatterns)
+	}
+
+	if err := os.WriteFile(filepath.Join(testDir, IgnoreFile), []byte("# comment\n\n*.tmp\n  /build  \n"), 0644); err != nil {
+		t.Fatalf("failed to create %s: %v", IgnoreFile, err)
+	}
+	if err := idx.LoadIgnoreFile(); err != nil {
+		t.Fatalf("LoadIgnoreFile() failed: %v", err)
+	}
+	expected := []string{"*.tmp", filepath.FromSlash("/build")}
+	if len(idx.IgnorePatterns) != len(expected) || idx.IgnorePatterns[0] != expected[0] || idx.IgnorePatterns[1] != expected[1] {
+		t.Errorf("expected patterns %v, got %v", expected, idx.IgnorePatterns)
+	}
+}
+
+func TestUpdatePathBffignore(t *testing.T) {
+	testDir := t.TempDir()
+	files := map[string]string{IgnoreFile: "*.tmp\n", "kept.txt": "kept", "draft.tmp": "draft"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := idx.LoadIgnoreFile(); err != nil {
+		t.Fatalf("LoadIgnoreFile() failed: %v", err)
+	}
+	for _, path := range []string{"kept.txt", "draft.tmp"} {
+		if err := idx.updatePath(path, nil); err != nil {
+			t.Fatalf("updatePath(%s) failed: %v", path, err)
+		}
+	}
+
+	if _, found := idx.hashOf("kept.txt"); !found {
+		t.Error("expected kept.txt to be indexed")
+	}
+	if _, found := idx.hashOf("draft.tmp"); found {
+		t.Errorf("expected draft.tmp to be excluded by %s", IgnoreFile)
+	}
+}
+
+func TestIndexBffignore(t *testing.T) {
+	testDir := t.TempDir()
+
+	files := map[string]string{
+		IgnoreFile:             "# Temporary files\n*.tmp\n/build\ncache/*.bin\n",
+		"keep.txt":             "keep",
+		"notes.tmp":            "root tmp",
+		"sub/draft.tmp":        "nested tmp",
+		"build/output.bin":     "root build",
+		"sub/build/main.go":    "nested build",
+		"cache/data.bin":       "root cache",
+		"sub/cache/data.bin":   "nested cache",
+		"sub/cache/readme.txt": "cache readme",
+	}
+	for path, content := range files {
+		fullPath := filepath.Join(testDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	indexed := make(map[string]bool)
+	for _, fileInfos := range idx.FilesByContentHash {
+		for _, fi := range fileInfos {
+			indexed[filepath.ToSlash(fi.Path)] = true
+		}
+	}
+
+	for _, path := range []string{"keep.txt", "sub/build/main.go", "sub/cache/readme.txt"} {
+		if !indexed[path] {
+			t.Errorf("expected %s to be indexed", path)
+		}
+	}
+	for _, path := range []string{"notes.tmp", "sub/draft.tmp", "build/output.bin", "cache/data.bin", "sub/cache/data.bin"} {
+		if indexed[path] {
+			t.Errorf("expected %s to be excluded by %s", path, IgnoreFile)
+		}
+	}
+	if len(indexed) != 3 {
+		t.Errorf("expected 3 indexed files, got %v", indexed)
+	}
+}
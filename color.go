@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// ColorMode selects when the output is colored.
+type ColorMode string
+
+const (
+	ColorAuto   ColorMode = "auto"   // Colored if the output is a terminal.
+	ColorAlways ColorMode = "always" // Always colored, e.g. when piping to a pager supporting colors.
+	ColorNever  ColorMode = "never"  // Never colored.
+)
+
+// ANSI codes of the colors and styles used in the output.
+const (
+	colorRed    = "31"
+	colorGreen  = "32"
+	colorYellow = "33"
+	colorBlue   = "34"
+	styleBold   = "1"
+	styleDim    = "2"
+)
+
+// parseColorMode returns the color mode with the given name.
+func parseColorMode(name string) (ColorMode, error) {
+	switch mode := ColorMode(name); mode {
+	case ColorAuto, ColorAlways, ColorNever:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown color mode %q (expected 'always', 'auto' or 'never')", name)
+	}
+}
+
+// colorizer adds colors to the text written to an output, if enabled.
+type colorizer struct {
+	enabled bool
+}
+
+// newColorizer returns a colorizer for the output w, enabled according to the color mode.
+// In auto mode, colors are only enabled if w is a terminal.
+func newColorizer(w io.Writer, mode ColorMode) *colorizer {
+	switch mode {
+	case ColorAlways:
+		return &colorizer{enabled: true}
+	case ColorNever:
+		return &colorizer{enabled: false}
+	}
+
+	file, ok := w.(*os.File)
+	return &colorizer{enabled: ok && term.IsTerminal(int(file.Fd()))}
+}
+
+// colorize returns the text wrapped in the ANSI escape codes of the color if colors are enabled,
+// otherwise the text unchanged.
+func (c *colorizer) colorize(text, colorCode string) string {
+	if !c.enabled {
+		return text
+	}
+	return "\x1b[" + colorCode + "m" + text + "\x1b[0m"
+}
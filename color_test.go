@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestComparisonPrintColors(t *testing.T) {
+	comparison := &Comparison{
+		Added:          []string{"added.txt"},
+		Modified:       []string{"modified.txt"},
+		Deleted:        []string{"deleted.txt"},
+		RenamedOrMoved: []RenamedOrMovedFile{{OldPath: "old.txt", NewPath: "new.txt"}},
+		Truncated:      []TruncatedFile{{Path: "truncated.txt", OriginalSize: 10}},
+	}
+
+	tests := []struct {
+		name          string
+		mode          ColorMode
+		expectEscapes bool
+	}{
+		{"never", ColorNever, false},
+		// The buffer is not a terminal.
+		{"auto", ColorAuto, false},
+		{"always", ColorAlways, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			comparison.Print(&buf, tt.mode)
+
+			hasEscapes := bytes.IndexByte(buf.Bytes(), 0x1b) >= 0
+			if hasEscapes != tt.expectEscapes {
+				t.Errorf("expected ANSI escape sequences: %v, got output %q", tt.expectEscapes, buf.String())
+			}
+			if !strings.Contains(buf.String(), "added.txt") {
+				t.Errorf("expected added.txt in output, got %q", buf.String())
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	comparison.Print(&buf, ColorAlways)
+	if !strings.Contains(buf.String(), "\x1b[32m+ added.txt\x1b[0m") {
+		t.Errorf("expected added file in green, got %q", buf.String())
+	}
+}
+
+func TestParseColorMode(t *testing.T) {
+	for _, name := range []string{"always", "auto", "never"} {
+		if mode, err := parseColorMode(name); err != nil || string(mode) != name {
+			t.Errorf("parseColorMode(%q) = %q, %v", name, mode, err)
+		}
+	}
+
+	if _, err := parseColorMode("sometimes"); err == nil {
+		t.Error("expected error for an unknown color mode, got nil")
+	}
+}
@@ -1,61 +1,428 @@
 package main
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
 
 // Comparison contains the results of comparing two different indexes of a directory,
 // at different times for example.
 type Comparison struct {
-	Added          []string
-	Modified       []string
-	Deleted        []string
-	RenamedOrMoved []RenamedOrMovedFile
+	Added              []string             `json:"added"`
+	Modified           []string             `json:"modified"`
+	Deleted            []string             `json:"deleted"`
+	RenamedOrMoved     []RenamedOrMovedFile `json:"renamed_or_moved"`
+	Truncated          []TruncatedFile      `json:"truncated"`                     // Files emptied since the index, not included in Modified.
+	PermissionsChanged []PermissionChange   `json:"permissions_changed,omitempty"` // Files with the same content but other permissions, only when they are tracked.
+	AddedDirs          []string             `json:"added_dirs,omitempty"`          // Empty directories, only when they are tracked.
+	DeletedDirs        []string             `json:"deleted_dirs,omitempty"`        // Empty directories, only when they are tracked.
+	Unstable           []string             `json:"unstable,omitempty"`            // Modified files whose content changed during the scan, only with a stability check.
+	MtimeOnly          []string             `json:"mtime_only,omitempty"`          // Files with the same content but another modification time, not counted as changes.
+	Consistency        bool                 `json:"consistency"`                   // Whether no file was detected to change during the scan.
+	IndexAbsPath       string               `json:"index_abs_path"`                // Path of the compared directory.
+	SavedAt            time.Time            `json:"saved_at,omitempty"`            // Only set for comparisons saved to a file.
 }
 
 type RenamedOrMovedFile struct {
-	OldPath string
-	NewPath string
+	OldPath string `json:"old_path"`
+	NewPath string `json:"new_path"`
 }
 
-// hasChanges returns true if there are any changes.
-func (c *Comparison) hasChanges() bool {
-	return len(c.Added) > 0 || len(c.Modified) > 0 || len(c.Deleted) > 0 || len(c.RenamedOrMoved) > 0
+// TruncatedFile is a file that was not empty in the index but is now, which may be a sign of data loss.
+type TruncatedFile struct {
+	Path         string `json:"path"`
+	OriginalSize int64  `json:"original_size"`
 }
 
-// Print outputs the comparison in a readable format.
-func (c *Comparison) Print() {
-	if !c.hasChanges() {
-		fmt.Println("No changes detected")
+// PermissionChange is a file whose permission bits changed but not its content.
+type PermissionChange struct {
+	Path    string      `json:"path"`
+	OldMode os.FileMode `json:"old_mode"`
+	NewMode os.FileMode `json:"new_mode"`
+}
+
+// Types of change events.
+const (
+	ChangeAdded          = "added"
+	ChangeModified       = "modified"
+	ChangeDeleted        = "deleted"
+	ChangeRenamedOrMoved = "renamed"
+	ChangeTruncated      = "truncated"
+	ChangePermissions    = "permissions"
+	ChangeAddedDir       = "added_dir"
+	ChangeDeletedDir     = "deleted_dir"
+	ChangeMtimeOnly      = "mtime_only"
+)
+
+// ChangeEvent represents a single change detected when comparing two states of a directory.
+type ChangeEvent struct {
+	Type    string      `json:"type"`
+	Path    string      `json:"path"`               // New path for renamed or moved files.
+	OldPath string      `json:"old_path,omitempty"` // Only set for renamed or moved files.
+	OldSize int64       `json:"old_size,omitempty"` // Only set for truncated files.
+	OldMode os.FileMode `json:"old_mode,omitempty"` // Only set for permission changes.
+	NewMode os.FileMode `json:"new_mode,omitempty"` // Only set for permission changes.
+}
+
+// addEvent adds the change to the comparison.
+func (c *Comparison) addEvent(event ChangeEvent) {
+	switch event.Type {
+	case ChangeAdded:
+		c.Added = append(c.Added, event.Path)
+	case ChangeModified:
+		c.Modified = append(c.Modified, event.Path)
+	case ChangeDeleted:
+		c.Deleted = append(c.Deleted, event.Path)
+	case ChangeRenamedOrMoved:
+		c.RenamedOrMoved = append(c.RenamedOrMoved, RenamedOrMovedFile{OldPath: event.OldPath, NewPath: event.Path})
+	case ChangeTruncated:
+		c.Truncated = append(c.Truncated, TruncatedFile{Path: event.Path, OriginalSize: event.OldSize})
+	case ChangePermissions:
+		c.PermissionsChanged = append(c.PermissionsChanged, PermissionChange{Path: event.Path, OldMode: event.OldMode, NewMode: event.NewMode})
+	case ChangeAddedDir:
+		c.AddedDirs = append(c.AddedDirs, event.Path)
+	case ChangeDeletedDir:
+		c.DeletedDirs = append(c.DeletedDirs, event.Path)
+	case ChangeMtimeOnly:
+		c.MtimeOnly = append(c.MtimeOnly, event.Path)
+	}
+}
+
+// HasChanges returns true if there are any changes.
+func (c *Comparison) HasChanges() bool {
+	return c.TotalChanges() > 0
+}
+
+// IsClean returns true if there are no changes.
+func (c *Comparison) IsClean() bool {
+	return c.TotalChanges() == 0
+}
+
+// TotalChanges returns the number of changes of all kinds, including truncated and unstable files, permission changes
+// and empty directories.
+func (c *Comparison) TotalChanges() int {
+	return len(c.Added) + len(c.Modified) + len(c.Deleted) + len(c.RenamedOrMoved) +
+		len(c.Truncated) + len(c.Unstable) + len(c.PermissionsChanged) + len(c.AddedDirs) + len(c.DeletedDirs)
+}
+
+// ChangeRate returns the ratio of changes out of the given number of files, between 0 and 1
+// unless there are more changes than files, or 0 if there are no files.
+func (c *Comparison) ChangeRate(totalFiles int) float64 {
+	if totalFiles == 0 {
+		return 0
+	}
+	return float64(c.TotalChanges()) / float64(totalFiles)
+}
+
+// RefineRenames returns a copy of the comparison where only the files renamed or moved within a directory, given
+// relative to the compared one, are still reported as such. The other renames, e.g. of a file deleted in a directory
+// and a file with the same content created in another one, are reported as a deleted file and an added file.
+func (c *Comparison) RefineRenames(prefix string) *Comparison {
+	refined := *c
+	refined.Added = append([]string{}, c.Added...)
+	refined.Deleted = append([]string{}, c.Deleted...)
+	refined.RenamedOrMoved = []RenamedOrMovedFile{}
+
+	for _, file := range c.RenamedOrMoved {
+		if isUnderDir(file.OldPath, prefix) && isUnderDir(file.NewPath, prefix) {
+			refined.RenamedOrMoved = append(refined.RenamedOrMoved, file)
+			continue
+		}
+		refined.Deleted = append(refined.Deleted, file.OldPath)
+		refined.Added = append(refined.Added, file.NewPath)
+	}
+
+	sort.Strings(refined.Added)
+	sort.Strings(refined.Deleted)
+	return &refined
+}
+
+// Equals returns true if the comparison has the same added, modified, deleted and renamed or moved files as the other
+// one, in any order. The other kinds of changes are not compared.
+func (c *Comparison) Equals(other *Comparison) bool {
+	return c.Diff(other) == ""
+}
+
+// Diff returns the discrepancies between the comparison and the expected other one, one per line, e.g.
+// "added: unexpected new.txt" for a file only in the comparison or "deleted: missing old.txt" for a file only in the
+// other one, or an empty string if they are equal according to Equals.
+func (c *Comparison) Diff(other *Comparison) string {
+	var sb strings.Builder
+
+	diffPaths := func(kind string, actual, expected []string) {
+		actualSet := make(map[string]bool, len(actual))
+		for _, path := range actual {
+			actualSet[path] = true
+		}
+		expectedSet := make(map[string]bool, len(expected))
+		for _, path := range expected {
+			expectedSet[path] = true
+		}
+
+		for _, path := range sortedPaths(expected) {
+			if !actualSet[path] {
+				fmt.Fprintf(&sb, "%s: missing %s\n", kind, path)
+			}
+		}
+		for _, path := range sortedPaths(actual) {
+			if !expectedSet[path] {
+				fmt.Fprintf(&sb, "%s: unexpected %s\n", kind, path)
+			}
+		}
+	}
+	renames := func(files []RenamedOrMovedFile) []string {
+		paths := make([]string, len(files))
+		for i, file := range files {
+			paths[i] = file.OldPath + " -> " + file.NewPath
+		}
+		return paths
+	}
+
+	diffPaths(ChangeAdded, c.Added, other.Added)
+	diffPaths(ChangeModified, c.Modified, other.Modified)
+	diffPaths(ChangeDeleted, c.Deleted, other.Deleted)
+	diffPaths(ChangeRenamedOrMoved, renames(c.RenamedOrMoved), renames(other.RenamedOrMoved))
+
+	return sb.String()
+}
+
+// Print outputs the comparison in a readable format to w, colored according to the color mode.
+func (c *Comparison) Print(w io.Writer, color ColorMode) {
+	c.PrintWithStats(w, color, 0, 0)
+}
+
+// PrintWithStats is like Print, but also outputs the number of files and bytes indexed when there are no changes,
+// unless both are zero.
+func (c *Comparison) PrintWithStats(w io.Writer, color ColorMode, totalFiles int, totalBytes int64) {
+	c.printText(w, color, totalFiles, totalBytes, false)
+}
+
+// PrintVerbose is like PrintWithStats, but also lists the files whose modification time changed but not their content.
+func (c *Comparison) PrintVerbose(w io.Writer, color ColorMode, totalFiles int, totalBytes int64) {
+	c.printText(w, color, totalFiles, totalBytes, true)
+}
+
+// printText outputs the comparison for Print, PrintWithStats and PrintVerbose.
+func (c *Comparison) printText(w io.Writer, color ColorMode, totalFiles int, totalBytes int64, verbose bool) {
+	colors := newColorizer(w, color)
+
+	if !c.HasChanges() {
+		if totalFiles == 0 && totalBytes == 0 {
+			fmt.Fprintln(w, "No changes detected")
+		} else {
+			fmt.Fprintf(w, "No changes detected (%d files, %s indexed)\n", totalFiles, FormatSize(totalBytes))
+		}
+		if verbose {
+			c.printMtimeOnly(w)
+		}
 		return
 	}
 
+	if len(c.Unstable) > 0 {
+		fmt.Fprintln(w, colors.colorize(fmt.Sprintf("⚠ %d files were unstable during scan (concurrent modification detected)", len(c.Unstable)), colorYellow))
+		for _, path := range c.Unstable {
+			fmt.Fprintf(w, "  %s\n", colors.colorize("? "+path, colorYellow))
+		}
+	}
+
+	if len(c.Truncated) > 0 {
+		fmt.Fprintln(w, "\nTruncated:")
+		for _, file := range c.Truncated {
+			fmt.Fprintf(w, "  %s (was %s)\n", colors.colorize("⚠ TRUNCATED "+file.Path, colorRed), FormatSize(file.OriginalSize))
+		}
+	}
+
 	if len(c.Added) > 0 {
-		fmt.Println("\nAdded:")
+		fmt.Fprintln(w, "\nAdded:")
 		for _, path := range c.Added {
-			fmt.Println("  +", path)
+			fmt.Fprintf(w, "  %s\n", colors.colorize("+ "+path, colorGreen))
 		}
 	}
 
 	if len(c.Modified) > 0 {
-		fmt.Println("\nModified:")
+		fmt.Fprintln(w, "\nModified:")
 		for _, path := range c.Modified {
-			fmt.Println("  ~", path)
+			fmt.Fprintf(w, "  %s\n", colors.colorize("~ "+path, colorYellow))
+		}
+	}
+
+	if verbose {
+		c.printMtimeOnly(w)
+	}
+
+	if len(c.PermissionsChanged) > 0 {
+		fmt.Fprintln(w, "\nPermissions changed:")
+		for _, change := range c.PermissionsChanged {
+			fmt.Fprintf(w, "  %s (was %o)\n", colors.colorize(fmt.Sprintf("chmod %o %s", change.NewMode, change.Path), colorYellow), change.OldMode)
 		}
 	}
 
 	if len(c.RenamedOrMoved) > 0 {
-		fmt.Println("\nRenamed/Moved:")
+		fmt.Fprintln(w, "\nRenamed/Moved:")
 		for _, file := range c.RenamedOrMoved {
-			fmt.Printf("  → %s -> %s\n", file.OldPath, file.NewPath)
+			fmt.Fprintf(w, "  %s\n", colors.colorize("→ "+file.OldPath+" -> "+file.NewPath, colorBlue))
 		}
 	}
 
 	if len(c.Deleted) > 0 {
-		fmt.Println("\nDeleted:")
+		fmt.Fprintln(w, "\nDeleted:")
 		for _, path := range c.Deleted {
-			fmt.Println("  -", path)
+			fmt.Fprintf(w, "  %s\n", colors.colorize("- "+path, colorRed))
+		}
+	}
+
+	if len(c.AddedDirs) > 0 {
+		fmt.Fprintln(w, "\nAdded empty directories:")
+		for _, dir := range c.AddedDirs {
+			fmt.Fprintf(w, "  %s\n", colors.colorize("+ "+dir+"/", colorGreen))
+		}
+	}
+
+	if len(c.DeletedDirs) > 0 {
+		fmt.Fprintln(w, "\nDeleted empty directories:")
+		for _, dir := range c.DeletedDirs {
+			fmt.Fprintf(w, "  %s\n", colors.colorize("- "+dir+"/", colorRed))
 		}
 	}
 
-	fmt.Printf("\n%d added, %d modified, %d renamed/moved, %d deleted\n",
+	fmt.Fprintf(w, "\n%d added, %d modified, %d renamed/moved, %d deleted",
 		len(c.Added), len(c.Modified), len(c.RenamedOrMoved), len(c.Deleted))
+	if len(c.Truncated) > 0 {
+		fmt.Fprintf(w, ", %d truncated", len(c.Truncated))
+	}
+	if len(c.Unstable) > 0 {
+		fmt.Fprintf(w, ", %d unstable", len(c.Unstable))
+	}
+	if len(c.PermissionsChanged) > 0 {
+		fmt.Fprintf(w, ", %d permissions changed", len(c.PermissionsChanged))
+	}
+	if len(c.AddedDirs) > 0 || len(c.DeletedDirs) > 0 {
+		fmt.Fprintf(w, ", %d empty directories added, %d deleted", len(c.AddedDirs), len(c.DeletedDirs))
+	}
+	fmt.Fprintln(w)
+}
+
+// printMtimeOnly outputs the files whose modification time changed but not their content, if any.
+func (c *Comparison) printMtimeOnly(w io.Writer) {
+	if len(c.MtimeOnly) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "\nModification time only:")
+	for _, path := range c.MtimeOnly {
+		fmt.Fprintf(w, "  ~ %s (mtime only)\n", path)
+	}
+}
+
+// SaveToFile saves the comparison as a JSON file, setting the time it was saved at.
+// The file is written atomically, so that a previous comparison saved at the same path is kept if it fails.
+func (c *Comparison) SaveToFile(path string) error {
+	c.SavedAt = time.Now()
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal comparison: %w", err)
+	}
+
+	if err := atomicWriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write comparison: %w", err)
+	}
+
+	return nil
+}
+
+// LoadComparison loads a comparison saved with SaveToFile.
+func LoadComparison(path string) (*Comparison, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read comparison: %w", err)
+	}
+
+	c := &Comparison{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse comparison: %w", err)
+	}
+
+	return c, nil
+}
+
+// ToGitStatus returns the comparison in the format of `git status --porcelain`, one change per line,
+// with "A", "M", "R" and "D" status codes. Truncated and unstable files and permission changes are reported
+// as modified. Paths containing spaces or special characters are quoted like git does, see gitQuotePath.
+func (c *Comparison) ToGitStatus() string {
+	var sb strings.Builder
+
+	for _, path := range sortedPaths(c.Added) {
+		fmt.Fprintf(&sb, "A  %s\n", gitQuotePath(path))
+	}
+	modified := append([]string{}, c.Modified...)
+	for _, file := range c.Truncated {
+		modified = append(modified, file.Path)
+	}
+	modified = append(modified, c.Unstable...)
+	for _, change := range c.PermissionsChanged {
+		modified = append(modified, change.Path)
+	}
+	for _, path := range sortedPaths(modified) {
+		fmt.Fprintf(&sb, "M  %s\n", gitQuotePath(path))
+	}
+
+	renamedOrMoved := append([]RenamedOrMovedFile{}, c.RenamedOrMoved...)
+	sort.Slice(renamedOrMoved, func(i, j int) bool {
+		return renamedOrMoved[i].NewPath < renamedOrMoved[j].NewPath
+	})
+	for _, file := range renamedOrMoved {
+		fmt.Fprintf(&sb, "R  %s -> %s\n", gitQuotePath(file.OldPath), gitQuotePath(file.NewPath))
+	}
+
+	for _, path := range sortedPaths(c.Deleted) {
+		fmt.Fprintf(&sb, "D  %s\n", gitQuotePath(path))
+	}
+
+	return sb.String()
+}
+
+// sortedPaths returns a sorted copy of the given paths.
+func sortedPaths(paths []string) []string {
+	sorted := append([]string{}, paths...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// gitEscapes are the characters that git writes with a letter escape in quoted paths, the others are written
+// as octal escapes.
+var gitEscapes = map[byte]byte{
+	'\a': 'a', '\b': 'b', '\t': 't', '\n': 'n', '\v': 'v', '\f': 'f', '\r': 'r', '"': '"', '\\': '\\',
+}
+
+// gitQuotePath quotes a path the way git porcelain output does (with core.quotePath, the default) when it contains
+// spaces, quotes, backslashes, control characters or non-ASCII bytes: the path is written between double quotes with
+// C-style escapes, and the bytes outside of printable ASCII, including the bytes of UTF-8 characters, as \ooo octal.
+func gitQuotePath(path string) string {
+	quoted := false
+	var sb strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if escape, ok := gitEscapes[c]; ok {
+			sb.WriteByte('\\')
+			sb.WriteByte(escape)
+			quoted = true
+		} else if c < 0x20 || c >= 0x7f {
+			fmt.Fprintf(&sb, "\\%03o", c)
+			quoted = true
+		} else {
+			sb.WriteByte(c)
+			quoted = quoted || c == ' '
+		}
+	}
+
+	if !quoted {
+		return path
+	}
+	return `"` + sb.String() + `"`
 }
@@ -1,6 +1,12 @@
 package main
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
 	"testing"
 )
 
@@ -12,36 +18,360 @@ func TestHasChanges(t *testing.T) {
 	}{
 		{
 			"no_changes",
-			&Comparison{[]string{}, []string{}, []string{}, []RenamedOrMovedFile{}},
+			&Comparison{Added: []string{}, Modified: []string{}, Deleted: []string{}, RenamedOrMoved: []RenamedOrMovedFile{}},
 			false,
 		},
 		{
 			"added",
-			&Comparison{[]string{"file.txt"}, []string{}, []string{}, []RenamedOrMovedFile{}},
+			&Comparison{Added: []string{"file.txt"}, Modified: []string{}, Deleted: []string{}, RenamedOrMoved: []RenamedOrMovedFile{}},
 			true,
 		},
 		{
 			"modified",
-			&Comparison{[]string{}, []string{"file.txt"}, []string{}, []RenamedOrMovedFile{}},
+			&Comparison{Added: []string{}, Modified: []string{"file.txt"}, Deleted: []string{}, RenamedOrMoved: []RenamedOrMovedFile{}},
 			true,
 		},
 		{
 			"renamed_or_moved",
-			&Comparison{[]string{}, []string{}, []string{}, []RenamedOrMovedFile{{OldPath: "old.txt", NewPath: "new.txt"}}},
+			&Comparison{Added: []string{}, Modified: []string{}, Deleted: []string{}, RenamedOrMoved: []RenamedOrMovedFile{{OldPath: "old.txt", NewPath: "new.txt"}}},
 			true,
 		},
 		{
 			"deleted",
-			&Comparison{[]string{}, []string{}, []string{"file.txt"}, []RenamedOrMovedFile{}},
+			&Comparison{Added: []string{}, Modified: []string{}, Deleted: []string{"file.txt"}, RenamedOrMoved: []RenamedOrMovedFile{}},
+			true,
+		},
+		{
+			"added_dir",
+			&Comparison{AddedDirs: []string{"empty"}},
+			true,
+		},
+		{
+			"deleted_dir",
+			&Comparison{DeletedDirs: []string{"empty"}},
 			true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if tt.comp.hasChanges() != tt.expected {
-				t.Errorf("hasChanges() = %v, want %v", tt.comp.hasChanges(), tt.expected)
+			if tt.comp.HasChanges() != tt.expected {
+				t.Errorf("HasChanges() = %v, want %v", tt.comp.HasChanges(), tt.expected)
 			}
 		})
 	}
 }
+
+func TestToGitStatus(t *testing.T) {
+	comp := &Comparison{
+		Added:          []string{"new.txt", "dir/with space.txt"},
+		Modified:       []string{"changed.txt"},
+		Deleted:        []string{"gone.txt"},
+		RenamedOrMoved: []RenamedOrMovedFile{{OldPath: "old.txt", NewPath: "sub/old.txt"}},
+	}
+
+	output := comp.ToGitStatus()
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+
+	expected := []struct {
+		prefix string
+		rest   string
+	}{
+		{"A ", `"dir/with space.txt"`},
+		{"A ", "new.txt"},
+		{"M ", "changed.txt"},
+		{"R ", "old.txt -> sub/old.txt"},
+		{"D ", "gone.txt"},
+	}
+
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %d: %q", len(expected), len(lines), output)
+	}
+
+	for i, line := range lines {
+		if prefix := line[:2]; prefix != expected[i].prefix {
+			t.Errorf("line %d: expected prefix %q, got %q", i, expected[i].prefix, prefix)
+		}
+		if rest := line[3:]; rest != expected[i].rest {
+			t.Errorf("line %d: expected path %q, got %q", i, expected[i].rest, rest)
+		}
+	}
+
+	empty := &Comparison{}
+	if output := empty.ToGitStatus(); output != "" {
+		t.Errorf("expected empty output for no changes, got %q", output)
+	}
+}
+
+func TestGitQuotePath(t *testing.T) {
+	// The expected outputs are the ones of `git status --porcelain` with the default core.quotePath.
+	tests := map[string]string{
+		"plain.txt":        "plain.txt",
+		"sp ace":           `"sp ace"`,
+		"café.txt":         `"caf\303\251.txt"`,
+		"tab\there":        `"tab\there"`,
+		`quo"te`:           `"quo\"te"`,
+		`back\slash`:       `"back\\slash"`,
+		"del\x7f":          `"del\177"`,
+		"bell\x07\x01.txt": `"bell\a\001.txt"`,
+	}
+	for path, expected := range tests {
+		if quoted := gitQuotePath(path); quoted != expected {
+			t.Errorf("gitQuotePath(%q): expected %s, got %s", path, expected, quoted)
+		}
+	}
+}
+
+func TestSaveAndLoadComparison(t *testing.T) {
+	comp := &Comparison{
+		Added:          []string{"new.txt"},
+		Modified:       []string{"changed.txt"},
+		Deleted:        []string{"gone.txt"},
+		RenamedOrMoved: []RenamedOrMovedFile{{OldPath: "old.txt", NewPath: "new/old.txt"}},
+		IndexAbsPath:   "/data",
+	}
+
+	path := filepath.Join(t.TempDir(), "diff.json")
+	if err := comp.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile() failed: %v", err)
+	}
+
+	if comp.SavedAt.IsZero() {
+		t.Error("expected SavedAt to be set")
+	}
+
+	loaded, err := LoadComparison(path)
+	if err != nil {
+		t.Fatalf("LoadComparison() failed: %v", err)
+	}
+
+	if !loaded.SavedAt.Equal(comp.SavedAt) {
+		t.Errorf("expected SavedAt %v, got %v", comp.SavedAt, loaded.SavedAt)
+	}
+	loaded.SavedAt = comp.SavedAt
+
+	if !reflect.DeepEqual(loaded, comp) {
+		t.Errorf("loaded comparison differs:\ngot:  %+v\nwant: %+v", loaded, comp)
+	}
+
+	if _, err := LoadComparison(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing file, got nil")
+	}
+}
+
+func TestSaveComparisonKeepsPreviousOnFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "diff.json")
+	if err := (&Comparison{Added: []string{"first.txt"}}).SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile() failed: %v", err)
+	}
+
+	rename = func(oldPath, newPath string) error {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: syscall.EIO}
+	}
+	defer func() { rename = os.Rename }()
+
+	if err := (&Comparison{Added: []string{"second.txt"}}).SaveToFile(path); err == nil {
+		t.Fatal("expected an error when the file cannot be moved into place")
+	}
+
+	loaded, err := LoadComparison(path)
+	if err != nil {
+		t.Fatalf("expected the previous comparison to be kept, got %v", err)
+	}
+	if len(loaded.Added) != 1 || loaded.Added[0] != "first.txt" {
+		t.Errorf("expected the previous comparison to be kept, got %v", loaded.Added)
+	}
+}
+
+func TestComparisonTotalChanges(t *testing.T) {
+	tests := []struct {
+		name          string
+		comp          *Comparison
+		expectedTotal int
+		expectedRate  float64
+	}{
+		{"no_changes", &Comparison{Added: []string{}, Modified: []string{}}, 0, 0},
+		{
+			"all_kinds",
+			&Comparison{
+				Added:          []string{"a.txt", "b.txt"},
+				Modified:       []string{"c.txt"},
+				Deleted:        []string{"d.txt"},
+				RenamedOrMoved: []RenamedOrMovedFile{{OldPath: "e.txt", NewPath: "f.txt"}},
+				Truncated:      []TruncatedFile{{Path: "g.txt", OriginalSize: 10}},
+				AddedDirs:      []string{"empty"},
+				DeletedDirs:    []string{"gone"},
+			},
+			8,
+			0.08,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if total := tt.comp.TotalChanges(); total != tt.expectedTotal {
+				t.Errorf("TotalChanges() = %d, want %d", total, tt.expectedTotal)
+			}
+			if rate := tt.comp.ChangeRate(100); rate != tt.expectedRate {
+				t.Errorf("ChangeRate(100) = %v, want %v", rate, tt.expectedRate)
+			}
+			if rate := tt.comp.ChangeRate(0); rate != 0 {
+				t.Errorf("ChangeRate(0) = %v, want 0", rate)
+			}
+			if tt.comp.IsClean() != (tt.expectedTotal == 0) {
+				t.Errorf("IsClean() = %v, want %v", tt.comp.IsClean(), tt.expectedTotal == 0)
+			}
+			if tt.comp.HasChanges() == tt.comp.IsClean() {
+				t.Error("expected HasChanges() to be the opposite of IsClean()")
+			}
+		})
+	}
+}
+
+func TestPrintWithStats(t *testing.T) {
+	tests := []struct {
+		name       string
+		comparison *Comparison
+		totalFiles int
+		totalBytes int64
+		expected   string
+	}{
+		{"no_changes", &Comparison{}, 1247, 45300 << 20, "No changes detected (1247 files, 44.2 GB indexed)\n"},
+		{"no_stats", &Comparison{}, 0, 0, "No changes detected\n"},
+		{"empty_files", &Comparison{}, 3, 0, "No changes detected (3 files, 0 B indexed)\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.comparison.PrintWithStats(&buf, ColorNever, tt.totalFiles, tt.totalBytes)
+			if buf.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	(&Comparison{Added: []string{"new.txt"}}).PrintWithStats(&buf, ColorNever, 10, 100)
+	if strings.Contains(buf.String(), "No changes") || !strings.Contains(buf.String(), "+ new.txt") {
+		t.Errorf("expected the changes to be printed, got %q", buf.String())
+	}
+}
+
+func TestRefineRenames(t *testing.T) {
+	testDir := t.TempDir()
+	for _, dir := range []string{"photos", "music", "docs"} {
+		if err := os.Mkdir(filepath.Join(testDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile := func(path, content string) {
+		if err := os.WriteFile(filepath.Join(testDir, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removeFile := func(path string) {
+		if err := os.Remove(filepath.Join(testDir, path)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile(filepath.Join("photos", "old.jpg"), "same content")
+	writeFile(filepath.Join("docs", "a.txt"), "document")
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	// An unrelated file with the same content is created in another directory.
+	removeFile(filepath.Join("photos", "old.jpg"))
+	writeFile(filepath.Join("music", "new.jpg"), "same content")
+	removeFile(filepath.Join("docs", "a.txt"))
+	writeFile(filepath.Join("docs", "b.txt"), "document")
+
+	comparison, err := idx.Compare()
+	if err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+	if len(comparison.RenamedOrMoved) != 2 {
+		t.Fatalf("expected 2 renames without refining, got %v", comparison.RenamedOrMoved)
+	}
+
+	refined := comparison.RefineRenames("docs")
+
+	expectedRenames := []RenamedOrMovedFile{{OldPath: filepath.Join("docs", "a.txt"), NewPath: filepath.Join("docs", "b.txt")}}
+	if !reflect.DeepEqual(refined.RenamedOrMoved, expectedRenames) {
+		t.Errorf("expected renames %v, got %v", expectedRenames, refined.RenamedOrMoved)
+	}
+	if expected := []string{filepath.Join("photos", "old.jpg")}; !reflect.DeepEqual(refined.Deleted, expected) {
+		t.Errorf("expected deleted files %v, got %v", expected, refined.Deleted)
+	}
+	if expected := []string{filepath.Join("music", "new.jpg")}; !reflect.DeepEqual(refined.Added, expected) {
+		t.Errorf("expected added files %v, got %v", expected, refined.Added)
+	}
+	if len(comparison.RenamedOrMoved) != 2 {
+		t.Errorf("expected the original comparison not to be modified")
+	}
+}
+
+func TestComparisonEqualsAndDiff(t *testing.T) {
+	testDir := t.TempDir()
+	for name, content := range map[string]string{"kept.txt": "kept", "old.txt": "old", "moved.txt": "moved"} {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(testDir, "new.txt"), []byte("new"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.Remove(filepath.Join(testDir, "old.txt")); err != nil {
+		t.Fatalf("failed to delete file: %v", err)
+	}
+	if err := os.Rename(filepath.Join(testDir, "moved.txt"), filepath.Join(testDir, "renamed.txt")); err != nil {
+		t.Fatalf("failed to rename file: %v", err)
+	}
+
+	comparison, err := idx.Compare()
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	expectedPath := filepath.Join(t.TempDir(), "expected.json")
+	if err := comparison.SaveToFile(expectedPath); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+	expected, err := LoadComparison(expectedPath)
+	if err != nil {
+		t.Fatalf("LoadComparison failed: %v", err)
+	}
+
+	actual, err := idx.Compare()
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if !actual.Equals(expected) {
+		t.Errorf("expected the comparison to match the saved one, got:\n%s", actual.Diff(expected))
+	}
+
+	if err := os.WriteFile(filepath.Join(testDir, "other.txt"), []byte("other"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	actual, err = idx.Compare()
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if actual.Equals(expected) {
+		t.Error("expected the comparison not to match the saved one after adding a file")
+	}
+	if diff := actual.Diff(expected); diff != "added: unexpected other.txt\n" {
+		t.Errorf("unexpected diff: %q", diff)
+	}
+	if diff := expected.Diff(actual); diff != "added: missing other.txt\n" {
+		t.Errorf("unexpected reverse diff: %q", diff)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvHeader is the header row of the CSV format of the indexed files.
+var csvHeader = []string{"hash", "path", "size", "mod_time"}
+
+// CSVRowError is a problem with a row of a CSV file, which is skipped when importing it.
+type CSVRowError struct {
+	Line int
+	Err  error
+}
+
+func (e *CSVRowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *CSVRowError) Unwrap() error {
+	return e.Err
+}
+
+// csvChangeHeader is the header row of the CSV format of the changes of a comparison.
+var csvChangeHeader = []string{"type", "path", "old_path"}
+
+// WriteCSV writes the indexed files to w as CSV with a header row and the columns hash, path, size and
+// mod_time (RFC 3339), sorted by hash then path. Paths use forward slashes.
+// The index must be loaded before calling this method.
+func (idx *Index) WriteCSV(w io.Writer) error {
+	return writeCSV(w, csvHeader, func(write func([]string) error) error {
+		return idx.WalkFiles(func(fi *FileInfo, hash string) error {
+			return write(fileCSVRecord(hash, fi))
+		})
+	})
+}
+
+// WriteDuplicatesCSV writes the files of the groups of duplicates to w in the CSV format of Index.WriteCSV, one row
+// per file, sorted by hash then path, e.g. for a spreadsheet.
+func WriteDuplicatesCSV(w io.Writer, duplicates map[string][]*FileInfo) error {
+	hashes := make([]string, 0, len(duplicates))
+	for hash := range duplicates {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	return writeCSV(w, csvHeader, func(write func([]string) error) error {
+		for _, hash := range hashes {
+			files := append([]*FileInfo{}, duplicates[hash]...)
+			sort.Slice(files, func(i, j int) bool {
+				return files[i].Path < files[j].Path
+			})
+			for _, file := range files {
+				if err := write(fileCSVRecord(hash, file)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// WriteCSV writes the changes to w as CSV with a header row and the columns type, path and old_path, the latter
+// only set for renamed or moved files. The types are the ones of the change events, e.g. "added", and the changes
+// are grouped by type, sorted by path. Unstable files are written as modified. Paths use forward slashes.
+func (c *Comparison) WriteCSV(w io.Writer) error {
+	var events []ChangeEvent
+	addPaths := func(changeType string, paths []string) {
+		for _, path := range sortedPaths(paths) {
+			events = append(events, ChangeEvent{Type: changeType, Path: path})
+		}
+	}
+
+	addPaths(ChangeAdded, c.Added)
+	addPaths(ChangeModified, append(append([]string{}, c.Modified...), c.Unstable...))
+	renamedOrMoved := append([]RenamedOrMovedFile{}, c.RenamedOrMoved...)
+	sort.Slice(renamedOrMoved, func(i, j int) bool {
+		return renamedOrMoved[i].NewPath < renamedOrMoved[j].NewPath
+	})
+	for _, file := range renamedOrMoved {
+		events = append(events, ChangeEvent{Type: ChangeRenamedOrMoved, Path: file.NewPath, OldPath: file.OldPath})
+	}
+	truncated := []string{}
+	for _, file := range c.Truncated {
+		truncated = append(truncated, file.Path)
+	}
+	addPaths(ChangeTruncated, truncated)
+	permissions := []string{}
+	for _, change := range c.PermissionsChanged {
+		permissions = append(permissions, change.Path)
+	}
+	addPaths(ChangePermissions, permissions)
+	addPaths(ChangeDeleted, c.Deleted)
+	addPaths(ChangeAddedDir, c.AddedDirs)
+	addPaths(ChangeDeletedDir, c.DeletedDirs)
+	addPaths(ChangeMtimeOnly, c.MtimeOnly)
+
+	return writeCSV(w, csvChangeHeader, func(write func([]string) error) error {
+		for _, event := range events {
+			if err := write([]string{event.Type, filepath.ToSlash(event.Path), filepath.ToSlash(event.OldPath)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeCSV writes the header row then the rows passed by rows to its callback to w as CSV.
+func writeCSV(w io.Writer, header []string, rows func(write func([]string) error) error) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	if err := rows(writer.Write); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+// fileCSVRecord returns the CSV row of a file with the given hash, in the format of Index.WriteCSV.
+func fileCSVRecord(hash string, fi *FileInfo) []string {
+	return []string{
+		hash,
+		filepath.ToSlash(fi.Path),
+		strconv.FormatInt(fi.Size, 10),
+		fi.ModTime.Format(time.RFC3339Nano),
+	}
+}
+
+// ReadCSV adds to the index the files of CSV data in the format of WriteCSV, e.g. produced by another tool.
+// The header row is optional. Paths can be relative to the indexed directory or absolute inside of it.
+// Rows that cannot be imported are skipped, and returned as *CSVRowError joined in a single error once all
+// the valid rows are imported. Other errors stop the import.
+func (idx *Index) ReadCSV(r io.Reader) error {
+	hasher, err := newHasher(idx.HashAlgorithm)
+	if err != nil {
+		return err
+	}
+	hashLength := hex.EncodedLen(hasher.Size())
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // The column count is checked for each row, to skip the invalid ones.
+
+	var rowErrors []error
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if line == 1 && len(record) > 0 && record[0] == csvHeader[0] {
+			continue
+		}
+
+		hash, file, err := idx.parseCSVRecord(record, hashLength)
+		if err != nil {
+			rowErrors = append(rowErrors, &CSVRowError{Line: line, Err: err})
+			continue
+		}
+
+		// The last row wins if a path is listed several times.
+		idx.removePath(file.Path)
+		idx.FilesByContentHash[hash] = append(idx.FilesByContentHash[hash], file)
+	}
+
+	return errors.Join(rowErrors...)
+}
+
+// parseCSVRecord returns the hash and the file of a CSV row, with the path relative to the indexed directory.
+func (idx *Index) parseCSVRecord(record []string, hashLength int) (string, *FileInfo, error) {
+	if len(record) != len(csvHeader) {
+		return "", nil, fmt.Errorf("expected %d columns (%s), got %d", len(csvHeader), strings.Join(csvHeader, ","), len(record))
+	}
+
+	hash := strings.ToLower(record[0])
+	if _, err := hex.DecodeString(hash); err != nil || len(hash) != hashLength {
+		return "", nil, fmt.Errorf("invalid %s hash %q", idx.HashAlgorithm, record[0])
+	}
+
+	relPath := filepath.Clean(filepath.FromSlash(record[1]))
+	if filepath.IsAbs(relPath) {
+		rel, err := filepath.Rel(idx.AbsPath, relPath)
+		if err != nil {
+			return "", nil, fmt.Errorf("path %s is not in %s", record[1], idx.AbsPath)
+		}
+		relPath = rel
+	}
+	if record[1] == "" || relPath == "." || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", nil, fmt.Errorf("path %q is not in %s", record[1], idx.AbsPath)
+	}
+
+	size, err := strconv.ParseInt(record[2], 10, 64)
+	if err != nil || size < 0 {
+		return "", nil, fmt.Errorf("invalid size %q", record[2])
+	}
+
+	modTime, err := time.Parse(time.RFC3339, record[3])
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid modification time %q", record[3])
+	}
+
+	return hash, &FileInfo{Path: relPath, Size: size, ModTime: modTime}, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 15, 4, 5, 123, time.UTC)
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		computeHash([]byte("a")): {{Path: "a.txt", Size: 1, ModTime: modTime}, {Path: filepath.Join("dir", "copy, of a.txt"), Size: 1, ModTime: modTime}},
+		computeHash([]byte("b")): {{Path: "b.txt", Size: 1, ModTime: modTime}},
+	}
+
+	var buf bytes.Buffer
+	if err := idx.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+
+	imported := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+	if err := imported.ReadCSV(&buf); err != nil {
+		t.Fatalf("ReadCSV failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(imported.AllPaths(), idx.AllPaths()) {
+		t.Errorf("expected paths %v, got %v", idx.AllPaths(), imported.AllPaths())
+	}
+	if !reflect.DeepEqual(imported.FilesByContentHash, idx.FilesByContentHash) {
+		t.Errorf("expected the imported files to match the exported ones")
+	}
+}
+
+func TestReadCSVMalformedRows(t *testing.T) {
+	hash := computeHash([]byte("a"))
+	data := strings.Join([]string{
+		"hash,path,size,mod_time",
+		hash + ",a.txt,1,2024-01-02T15:04:05Z",
+		hash + ",missing-column.txt,1",
+		"not-a-hash,b.txt,1,2024-01-02T15:04:05Z",
+		hash + ",c.txt,-1,2024-01-02T15:04:05Z",
+		hash + ",../outside.txt,1,2024-01-02T15:04:05Z",
+		hash + ",/data/sub/d.txt,1,2024-01-02T15:04:05Z",
+	}, "\n")
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+	err := idx.ReadCSV(strings.NewReader(data))
+
+	var rowErr *CSVRowError
+	if !errors.As(err, &rowErr) {
+		t.Fatalf("expected row errors, got %v", err)
+	}
+	if rowErr.Line != 3 {
+		t.Errorf("expected the first error on line 3, got line %d", rowErr.Line)
+	}
+	if count := len(err.(interface{ Unwrap() []error }).Unwrap()); count != 4 {
+		t.Errorf("expected 4 invalid rows, got %d: %v", count, err)
+	}
+
+	expected := []string{"a.txt", filepath.Join("sub", "d.txt")}
+	if !reflect.DeepEqual(idx.AllPaths(), expected) {
+		t.Errorf("expected the valid rows %v to be imported, got %v", expected, idx.AllPaths())
+	}
+}
+
+func TestWriteDuplicatesCSV(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	duplicates := map[string][]*FileInfo{
+		"bbbb": {{Path: filepath.Join("dir", "y.txt"), Size: 20, ModTime: modTime}, {Path: "x.txt", Size: 20, ModTime: modTime}},
+		"aaaa": {{Path: "b.txt", Size: 10, ModTime: modTime}, {Path: "a, copy.txt", Size: 10, ModTime: modTime}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDuplicatesCSV(&buf, duplicates); err != nil {
+		t.Fatalf("WriteDuplicatesCSV failed: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	expected := [][]string{
+		{"hash", "path", "size", "mod_time"},
+		{"aaaa", "a, copy.txt", "10", "2024-01-02T15:04:05Z"},
+		{"aaaa", "b.txt", "10", "2024-01-02T15:04:05Z"},
+		{"bbbb", "dir/y.txt", "20", "2024-01-02T15:04:05Z"},
+		{"bbbb", "x.txt", "20", "2024-01-02T15:04:05Z"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected %v, got %v", expected, records)
+	}
+}
+
+func TestComparisonWriteCSV(t *testing.T) {
+	comparison := &Comparison{
+		Added:          []string{"z.txt", "a.txt"},
+		Modified:       []string{"m.txt"},
+		Deleted:        []string{"d.txt"},
+		RenamedOrMoved: []RenamedOrMovedFile{{OldPath: "old.txt", NewPath: filepath.Join("dir", "new.txt")}},
+		Truncated:      []TruncatedFile{{Path: "empty.txt", OriginalSize: 42}},
+	}
+
+	var buf bytes.Buffer
+	if err := comparison.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	expected := [][]string{
+		{"type", "path", "old_path"},
+		{ChangeAdded, "a.txt", ""},
+		{ChangeAdded, "z.txt", ""},
+		{ChangeModified, "m.txt", ""},
+		{ChangeRenamedOrMoved, "dir/new.txt", "old.txt"},
+		{ChangeTruncated, "empty.txt", ""},
+		{ChangeDeleted, "d.txt", ""},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected %v, got %v", expected, records)
+	}
+}
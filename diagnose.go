@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FindOrphanedHashes returns the hashes whose files all no longer exist on disk, sorted, without modifying
+// the index (unlike Prune). Files that cannot be checked for another reason are considered to exist.
+// The index must be loaded before calling this method.
+func (idx *Index) FindOrphanedHashes() []string {
+	orphaned := []string{}
+
+	for hash, files := range idx.FilesByContentHash {
+		allMissing := true
+		for _, file := range files {
+			_, err := os.Stat(longPath(filepath.Join(idx.AbsPath, file.Path)))
+			if !os.IsNotExist(err) {
+				allMissing = false
+				break
+			}
+		}
+		if allMissing {
+			orphaned = append(orphaned, hash)
+		}
+	}
+
+	sort.Strings(orphaned)
+	return orphaned
+}
+
+// CollisionGroup is a hash shared by files of different sizes.
+type CollisionGroup struct {
+	Hash  string
+	Files []*FileInfo // Sorted by path.
+}
+
+// HashCollisionCheck returns the hashes whose files do not all have the same size, sorted by hash. Files with the same
+// content always have the same size, so this is a sign of corruption of the index or of a hash collision, whether
+// accidental or crafted. The files are not accessed.
+// The index must be loaded before calling this method.
+func (idx *Index) HashCollisionCheck() []CollisionGroup {
+	collisions := []CollisionGroup{}
+
+	for hash, files := range idx.FilesByContentHash {
+		sortedFiles := []*FileInfo{}
+		sizes := make(map[int64]bool)
+		for _, file := range files {
+			if file != nil { // Files without data are reported by Validate.
+				sortedFiles = append(sortedFiles, file)
+				sizes[file.Size] = true
+			}
+		}
+		if len(sizes) < 2 {
+			continue
+		}
+
+		sort.Slice(sortedFiles, func(i, j int) bool {
+			return sortedFiles[i].Path < sortedFiles[j].Path
+		})
+		collisions = append(collisions, CollisionGroup{Hash: hash, Files: sortedFiles})
+	}
+
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i].Hash < collisions[j].Hash
+	})
+	return collisions
+}
+
+// Validate checks the consistency of the index data, e.g. after the index file was edited manually,
+// and returns all the problems found joined in a single error, or nil if there are none.
+// The files are not accessed.
+// The index must be loaded before calling this method.
+func (idx *Index) Validate() error {
+	var problems []error
+
+	hashLength := 0
+	if hasher, err := newHasher(idx.HashAlgorithm); err != nil {
+		problems = append(problems, err)
+	} else {
+		hashLength = hex.EncodedLen(hasher.Size())
+	}
+
+	hashByPath := make(map[string]string)
+	for hash, files := range idx.FilesByContentHash {
+		if _, err := hex.DecodeString(hash); err != nil || (hashLength > 0 && len(hash) != hashLength) {
+			problems = append(problems, fmt.Errorf("invalid %s hash %q", idx.HashAlgorithm, hash))
+		}
+		if len(files) == 0 {
+			problems = append(problems, fmt.Errorf("hash %s has no files", hash))
+		}
+
+		for _, file := range files {
+			if file == nil || file.Path == "" {
+				problems = append(problems, fmt.Errorf("hash %s has a file without path", hash))
+				continue
+			}
+			if filepath.IsAbs(file.Path) || file.Path == ".." || strings.HasPrefix(file.Path, ".."+string(filepath.Separator)) {
+				problems = append(problems, fmt.Errorf("path %s is not relative to the indexed directory", file.Path))
+			}
+			if file.Size < 0 {
+				problems = append(problems, fmt.Errorf("file %s has a negative size", file.Path))
+			}
+			if otherHash, exists := hashByPath[file.Path]; exists {
+				problems = append(problems, fmt.Errorf("file %s is indexed with several hashes (%s and %s)", file.Path, otherHash, hash))
+			}
+			hashByPath[file.Path] = hash
+		}
+	}
+
+	// Map iteration order is random, sort the problems to report them consistently.
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].Error() < problems[j].Error()
+	})
+
+	return errors.Join(problems...)
+}
+
+// errDuplicatePaths is returned when loading an index with paths indexed several times, which can happen
+// when several processes write the index at the same time.
+var errDuplicatePaths = errors.New("the index has paths indexed several times, run 'bff gc' to repair it")
+
+// PathDuplicateError is a path indexed several times, under the given hashes.
+type PathDuplicateError struct {
+	Path   string
+	Hashes []string
+}
+
+func (e PathDuplicateError) Error() string {
+	return fmt.Sprintf("path %s is indexed several times (%s)", e.Path, strings.Join(e.Hashes, ", "))
+}
+
+// DetectPathDuplicates returns the paths indexed several times, sorted by path, with their hashes sorted.
+// The index must be loaded before calling this method.
+func (idx *Index) DetectPathDuplicates() []PathDuplicateError {
+	hashesByPath := make(map[string][]string)
+	for hash, files := range idx.FilesByContentHash {
+		for _, file := range files {
+			hashesByPath[file.Path] = append(hashesByPath[file.Path], hash)
+		}
+	}
+
+	duplicates := []PathDuplicateError{}
+	for path, hashes := range hashesByPath {
+		if len(hashes) > 1 {
+			sort.Strings(hashes)
+			duplicates = append(duplicates, PathDuplicateError{Path: path, Hashes: hashes})
+		}
+	}
+
+	sort.Slice(duplicates, func(i, j int) bool {
+		return duplicates[i].Path < duplicates[j].Path
+	})
+	return duplicates
+}
+
+// Repair removes the duplicate entries of the paths indexed several times, keeping the one with the most
+// recent modification time, and returns how many entries were removed. The index file is not saved.
+// The index must be loaded before calling this method.
+func (idx *Index) Repair() int {
+	latest := make(map[string]*FileInfo)
+	for _, files := range idx.FilesByContentHash {
+		for _, file := range files {
+			if kept, exists := latest[file.Path]; !exists || file.ModTime.After(kept.ModTime) {
+				latest[file.Path] = file
+			}
+		}
+	}
+
+	removed := 0
+	for hash, files := range idx.FilesByContentHash {
+		kept := files[:0]
+		for _, file := range files {
+			if latest[file.Path] != file {
+				removed++
+				continue
+			}
+			kept = append(kept, file)
+		}
+
+		if len(kept) == 0 {
+			delete(idx.FilesByContentHash, hash)
+		} else {
+			idx.FilesByContentHash[hash] = kept
+		}
+	}
+
+	return removed
+}
+
+// Compact removes the entries of the files indexed several times under the same hash with the same path, size and
+// modification time, e.g. after merging indexes, and returns how many were removed. Files with the same content but
+// other paths are kept. The index file is not saved.
+// The index must be loaded before calling this method.
+func (idx *Index) Compact() int {
+	type fileKey struct {
+		path    string
+		size    int64
+		modTime int64
+	}
+
+	removed := 0
+	for hash, files := range idx.FilesByContentHash {
+		seen := make(map[fileKey]bool, len(files))
+		kept := files[:0]
+		for _, file := range files {
+			key := fileKey{path: file.Path, size: file.Size, modTime: file.ModTime.UnixNano()}
+			if seen[key] {
+				removed++
+				continue
+			}
+			seen[key] = true
+			kept = append(kept, file)
+		}
+		idx.FilesByContentHash[hash] = kept
+	}
+
+	return removed
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindOrphanedHashes(t *testing.T) {
+	testDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(testDir, "exists.txt"), []byte("content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"hash1": {{Path: "exists.txt"}},
+		"hash2": {{Path: "missing.txt"}},
+		"hash3": {{Path: "missing-copy.txt"}, {Path: "exists.txt"}},
+		"hash4": {{Path: "gone/a.txt"}, {Path: "gone/b.txt"}},
+	}
+
+	orphaned := idx.FindOrphanedHashes()
+
+	expected := []string{"hash2", "hash4"}
+	if !reflect.DeepEqual(orphaned, expected) {
+		t.Errorf("expected orphaned hashes %v, got %v", expected, orphaned)
+	}
+	if len(idx.FilesByContentHash) != 4 {
+		t.Error("expected the index to be unchanged")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	validHash := computeHash([]byte("content"))
+	otherHash := computeHash([]byte("other"))
+
+	tests := []struct {
+		name             string
+		files            map[string][]*FileInfo
+		expectedProblems []string
+	}{
+		{"valid", map[string][]*FileInfo{validHash: {{Path: "a.txt"}, {Path: "b.txt"}}}, nil},
+		{"invalid_hash", map[string][]*FileInfo{"xyz": {{Path: "a.txt"}}}, []string{`invalid sha256 hash "xyz"`}},
+		{"no_files", map[string][]*FileInfo{validHash: {}}, []string{"has no files"}},
+		{"absolute_path", map[string][]*FileInfo{validHash: {{Path: filepath.Join(string(filepath.Separator), "a.txt")}}}, []string{"is not relative"}},
+		{
+			"several_hashes",
+			map[string][]*FileInfo{validHash: {{Path: "a.txt"}}, otherHash: {{Path: "a.txt"}}},
+			[]string{"a.txt is indexed with several hashes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+			idx.FilesByContentHash = tt.files
+
+			err := idx.Validate()
+			if len(tt.expectedProblems) == 0 {
+				if err != nil {
+					t.Errorf("expected no problems, got %v", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("expected problems, got nil")
+			}
+			for _, problem := range tt.expectedProblems {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("expected problem %q, got %v", problem, err)
+				}
+			}
+		})
+	}
+}
+
+func TestHashCollisionCheck(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/tmp"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"same":      {{Path: "a.txt", Size: 10}, {Path: "b.txt", Size: 10}},
+		"collision": {{Path: "z.bin", Size: 10}, {Path: "y.bin", Size: 2000}},
+		"single":    {{Path: "c.txt", Size: 5}},
+	}
+
+	collisions := idx.HashCollisionCheck()
+	if len(collisions) != 1 || collisions[0].Hash != "collision" {
+		t.Fatalf("expected a collision for the hash collision, got %v", collisions)
+	}
+	if paths := []string{collisions[0].Files[0].Path, collisions[0].Files[1].Path}; !reflect.DeepEqual(paths, []string{"y.bin", "z.bin"}) {
+		t.Errorf("expected the files sorted by path, got %v", paths)
+	}
+
+	delete(idx.FilesByContentHash, "collision")
+	if collisions := idx.HashCollisionCheck(); len(collisions) != 0 {
+		t.Errorf("expected no collisions, got %v", collisions)
+	}
+}
+
+func TestDetectPathDuplicatesAndRepair(t *testing.T) {
+	testDir := t.TempDir()
+	hash1 := computeHash([]byte("old"))
+	hash2 := computeHash([]byte("new"))
+	data := `{
+  "files_by_content_hash": {
+    "` + hash1 + `": [
+      {"path": "report.txt", "size": 3, "mod_time": "2024-01-01T00:00:00Z"},
+      {"path": "other.txt", "size": 3, "mod_time": "2024-01-01T00:00:00Z"}
+    ],
+    "` + hash2 + `": [
+      {"path": "report.txt", "size": 3, "mod_time": "2024-02-01T00:00:00Z"}
+    ]
+  },
+  "abs_path": "` + filepath.ToSlash(testDir) + `",
+  "hash_algorithm": "sha256"
+}`
+	if err := os.WriteFile(filepath.Join(testDir, IndexFile), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := idx.Load(); !errors.Is(err, errDuplicatePaths) {
+		t.Fatalf("expected Load to detect the duplicate path, got %v", err)
+	}
+
+	expected := []PathDuplicateError{{Path: "report.txt", Hashes: sortedPaths([]string{hash1, hash2})}}
+	if duplicates := idx.DetectPathDuplicates(); !reflect.DeepEqual(duplicates, expected) {
+		t.Errorf("expected %v, got %v", expected, duplicates)
+	}
+
+	if removed := idx.Repair(); removed != 1 {
+		t.Errorf("expected 1 entry removed, got %d", removed)
+	}
+	if duplicates := idx.DetectPathDuplicates(); len(duplicates) != 0 {
+		t.Errorf("expected no duplicate paths after repair, got %v", duplicates)
+	}
+	if hash, _ := idx.hashOf("report.txt"); hash != hash2 {
+		t.Errorf("expected the most recent entry to be kept, got hash %s", hash)
+	}
+	if hash, _ := idx.hashOf("other.txt"); hash != hash1 {
+		t.Errorf("expected other files to be kept, got hash %s", hash)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+	idx.FilesByContentHash["hash"] = []*FileInfo{
+		{Path: "a.txt", Size: 1, ModTime: modTime},
+		{Path: "copy.txt", Size: 1, ModTime: modTime},
+		{Path: "a.txt", Size: 1, ModTime: modTime},
+		{Path: "a.txt", Size: 1, ModTime: modTime.Add(time.Second)},
+	}
+
+	if removed := idx.Compact(); removed != 1 {
+		t.Errorf("expected 1 entry removed, got %d", removed)
+	}
+
+	paths := []string{}
+	for _, file := range idx.FilesByContentHash["hash"] {
+		paths = append(paths, file.Path)
+	}
+	if expected := []string{"a.txt", "copy.txt", "a.txt"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected the copies and the entries with other values to be kept, got %v", paths)
+	}
+
+	if removed := idx.Compact(); removed != 0 {
+		t.Errorf("expected nothing more to remove, got %d", removed)
+	}
+}
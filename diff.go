@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change in a unified diff.
+const diffContextLines = 3
+
+// maxDiffCells bounds the size of the LCS table (lines of A times lines of B) to keep memory usage reasonable.
+const maxDiffCells = 25_000_000
+
+// diffOp is a single line of a line-based diff: ' ' for an unchanged line, '-' for a deleted one and '+' for an inserted one.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// DiffFiles returns a unified diff between the current content of two files, given by their path relative to the indexed directory.
+// It returns an empty string if both files have identical content.
+func (idx *Index) DiffFiles(pathA, pathB string) (string, error) {
+	contentA, err := os.ReadFile(filepath.Join(idx.AbsPath, pathA))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", pathA, err)
+	}
+
+	contentB, err := os.ReadFile(filepath.Join(idx.AbsPath, pathB))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", pathB, err)
+	}
+
+	if bytes.Equal(contentA, contentB) {
+		return "", nil
+	}
+
+	if bytes.IndexByte(contentA, 0) >= 0 || bytes.IndexByte(contentB, 0) >= 0 {
+		return fmt.Sprintf("Binary files %s and %s differ\n", pathA, pathB), nil
+	}
+
+	linesA := splitLines(string(contentA))
+	linesB := splitLines(string(contentB))
+	if len(linesA)*len(linesB) > maxDiffCells {
+		return "", fmt.Errorf("files are too large to diff (%d and %d lines)", len(linesA), len(linesB))
+	}
+
+	return unifiedDiff(pathA, pathB, diffLines(linesA, linesB)), nil
+}
+
+// splitLines splits a text into lines, without the line terminators.
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines computes a minimal line-based diff between a and b using their longest common subsequence.
+func diffLines(a, b []string) []diffOp {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := []diffOp{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+
+	return ops
+}
+
+// unifiedDiff formats diff operations in the unified diff format, grouping changes into hunks.
+func unifiedDiff(nameA, nameB string, ops []diffOp) string {
+	// Line positions in A and B before each operation, used for the hunk headers.
+	posA := make([]int, len(ops)+1)
+	posB := make([]int, len(ops)+1)
+	for k, op := range ops {
+		posA[k+1], posB[k+1] = posA[k], posB[k]
+		if op.kind != '+' {
+			posA[k+1]++
+		}
+		if op.kind != '-' {
+			posB[k+1]++
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+
+	for k := 0; k < len(ops); k++ {
+		if ops[k].kind == ' ' {
+			continue
+		}
+
+		// Extend the hunk as long as the next change is close enough to share context lines.
+		last := k
+		for next := k + 1; next < len(ops) && next <= last+2*diffContextLines; next++ {
+			if ops[next].kind != ' ' {
+				last = next
+			}
+		}
+
+		start := max(0, k-diffContextLines)
+		end := min(len(ops), last+diffContextLines+1)
+
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n",
+			hunkRange(posA[start], posA[end]-posA[start]),
+			hunkRange(posB[start], posB[end]-posB[start]))
+		for _, op := range ops[start:end] {
+			fmt.Fprintf(&sb, "%c%s\n", op.kind, op.line)
+		}
+
+		k = end - 1
+	}
+
+	return sb.String()
+}
+
+// hunkRange formats a hunk range as "start,count" with a 1-based start line.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
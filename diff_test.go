@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffFiles(t *testing.T) {
+	testDir := t.TempDir()
+
+	files := map[string]string{
+		"old.txt":  "line1\nline2\nline3\n",
+		"new.txt":  "line1\nline2 modified\nline3\nline4\n",
+		"copy.txt": "line1\nline2\nline3\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+
+	diff, err := idx.DiffFiles("old.txt", "new.txt")
+	if err != nil {
+		t.Fatalf("DiffFiles() failed: %v", err)
+	}
+
+	expected := "--- old.txt\n+++ new.txt\n@@ -1,3 +1,4 @@\n line1\n-line2\n+line2 modified\n line3\n+line4\n"
+	if diff != expected {
+		t.Errorf("unexpected diff:\ngot:\n%s\nwant:\n%s", diff, expected)
+	}
+
+	diff, err = idx.DiffFiles("old.txt", "copy.txt")
+	if err != nil {
+		t.Fatalf("DiffFiles() failed: %v", err)
+	}
+	if diff != "" {
+		t.Errorf("expected empty diff for identical files, got %q", diff)
+	}
+
+	if _, err := idx.DiffFiles("old.txt", "nonexistent.txt"); err == nil {
+		t.Error("expected error for non-existent file, got nil")
+	}
+}
+
+func TestUnifiedDiffHunks(t *testing.T) {
+	a := []string{}
+	for i := 1; i <= 20; i++ {
+		a = append(a, strings.Repeat("x", i))
+	}
+	b := append([]string{}, a...)
+	b[1] = "changed"
+	b[18] = "changed"
+
+	diff := unifiedDiff("a", "b", diffLines(a, b))
+
+	if hunks := strings.Count(diff, "@@ -"); hunks != 2 {
+		t.Errorf("expected 2 hunks for distant changes, got %d:\n%s", hunks, diff)
+	}
+	if !strings.Contains(diff, "@@ -1,5 +1,5 @@") {
+		t.Errorf("expected first hunk header '@@ -1,5 +1,5 @@', got:\n%s", diff)
+	}
+	if !strings.Contains(diff, "@@ -16,5 +16,5 @@") {
+		t.Errorf("expected second hunk header '@@ -16,5 +16,5 @@', got:\n%s", diff)
+	}
+}
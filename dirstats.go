@@ -0,0 +1,160 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DirStats are the statistics of the files directly in a directory, not in its subdirectories.
+type DirStats struct {
+	FileCount      int   `json:"file_count"`
+	TotalSize      int64 `json:"total_size"`
+	DuplicateCount int   `json:"duplicate_count"` // Non-empty files with the same content as other files, anywhere in the index.
+	WastedBytes    int64 `json:"wasted_bytes"`    // Size of the duplicates, but the first copy by path of each group.
+}
+
+// DirStat is a directory, relative to the indexed one, with its statistics.
+type DirStat struct {
+	Dir string
+	*DirStats
+}
+
+// ComputeDirectoryStats returns the statistics of each directory containing files, by path relative to the indexed
+// directory ("." for the indexed directory itself). The wasted bytes of all the directories add up to the space wasted
+// by the duplicates of the index.
+// The index must be loaded before calling this method.
+func (idx *Index) ComputeDirectoryStats() map[string]*DirStats {
+	stats := make(map[string]*DirStats)
+
+	for _, files := range idx.FilesByContentHash {
+		// The first copy by path is the one not counted as wasted.
+		first := ""
+		for _, file := range files {
+			if first == "" || file.Path < first {
+				first = file.Path
+			}
+		}
+
+		for _, file := range files {
+			dir := filepath.Dir(file.Path)
+			dirStats, exists := stats[dir]
+			if !exists {
+				dirStats = &DirStats{}
+				stats[dir] = dirStats
+			}
+
+			dirStats.FileCount++
+			dirStats.TotalSize += file.Size
+			if len(files) > 1 && file.Size > 0 {
+				dirStats.DuplicateCount++
+				if file.Path != first {
+					dirStats.WastedBytes += file.Size
+				}
+			}
+		}
+	}
+
+	return stats
+}
+
+// TopDirectoriesByWaste returns the n directories of the stored statistics wasting the most space with duplicates,
+// then sorted by path, leaving out the ones without any. All of them are returned if n is not positive.
+func (idx *Index) TopDirectoriesByWaste(n int) []DirStat {
+	stats := idx.DirStats
+	if stats == nil {
+		stats = idx.ComputeDirectoryStats()
+	}
+
+	top := []DirStat{}
+	for dir, dirStats := range stats {
+		if dirStats.WastedBytes > 0 {
+			top = append(top, DirStat{Dir: dir, DirStats: dirStats})
+		}
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].WastedBytes != top[j].WastedBytes {
+			return top[i].WastedBytes > top[j].WastedBytes
+		}
+		return top[i].Dir < top[j].Dir
+	})
+
+	if n > 0 && n < len(top) {
+		top = top[:n]
+	}
+	return top
+}
+
+// DirSizeEntry is a top-level directory, relative to the indexed one, with the size and number of all its files,
+// including in its subdirectories.
+type DirSizeEntry struct {
+	Dir       string
+	TotalSize int64
+	FileCount int
+}
+
+// TopDirectoriesBySize returns the n top-level directories using the most space, like du --max-depth=1, then sorted
+// by path. The files directly in the indexed directory are counted under ".". All of them are returned if n is not
+// positive.
+// The index must be loaded before calling this method.
+func (idx *Index) TopDirectoriesBySize(n int) []DirSizeEntry {
+	entries := make(map[string]*DirSizeEntry)
+	_ = idx.WalkFiles(func(fi *FileInfo, hash string) error {
+		dir := "."
+		if i := strings.IndexRune(fi.Path, filepath.Separator); i >= 0 {
+			dir = fi.Path[:i]
+		}
+
+		entry, exists := entries[dir]
+		if !exists {
+			entry = &DirSizeEntry{Dir: dir}
+			entries[dir] = entry
+		}
+		entry.TotalSize += fi.Size
+		entry.FileCount++
+		return nil
+	})
+
+	top := make([]DirSizeEntry, 0, len(entries))
+	for _, entry := range entries {
+		top = append(top, *entry)
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].TotalSize != top[j].TotalSize {
+			return top[i].TotalSize > top[j].TotalSize
+		}
+		return top[i].Dir < top[j].Dir
+	})
+
+	if n > 0 && n < len(top) {
+		top = top[:n]
+	}
+	return top
+}
+
+// ExtensionStats are the statistics of the files with an extension.
+type ExtensionStats struct {
+	FileCount int
+	TotalSize int64
+}
+
+// GroupByExtension returns the statistics of the indexed files by extension, in lower case and without the dot,
+// e.g. "jpg" for photo.JPG. Files without extension are counted under "".
+// The index must be loaded before calling this method.
+func (idx *Index) GroupByExtension() map[string]*ExtensionStats {
+	stats := make(map[string]*ExtensionStats)
+	_ = idx.WalkFiles(func(fi *FileInfo, hash string) error {
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(fi.Path), "."))
+		extStats, exists := stats[ext]
+		if !exists {
+			extStats = &ExtensionStats{}
+			stats[ext] = extStats
+		}
+		extStats.FileCount++
+		extStats.TotalSize += fi.Size
+		return nil
+	})
+	return stats
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestComputeDirectoryStats(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"photo": {
+			{Path: filepath.Join("photos", "a.jpg"), Size: 100},
+			{Path: filepath.Join("photos", "b.jpg"), Size: 100},
+			{Path: filepath.Join("photos", "c.jpg"), Size: 100},
+			{Path: filepath.Join("photos", "d.jpg"), Size: 100},
+			{Path: filepath.Join("photos", "e.jpg"), Size: 100},
+		},
+		"doc":   {{Path: filepath.Join("docs", "report.pdf"), Size: 50}},
+		"notes": {{Path: "notes.txt", Size: 10}},
+		"empty": {{Path: filepath.Join("docs", "empty1"), Size: 0}, {Path: filepath.Join("docs", "empty2"), Size: 0}},
+	}
+
+	stats := idx.ComputeDirectoryStats()
+	expected := map[string]*DirStats{
+		"photos": {FileCount: 5, TotalSize: 500, DuplicateCount: 5, WastedBytes: 400},
+		"docs":   {FileCount: 3, TotalSize: 50},
+		".":      {FileCount: 1, TotalSize: 10},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		for dir, dirStats := range stats {
+			t.Logf("%s: %+v", dir, *dirStats)
+		}
+		t.Errorf("unexpected directory statistics")
+	}
+
+	top := idx.TopDirectoriesByWaste(10)
+	if len(top) != 1 || top[0].Dir != "photos" || top[0].WastedBytes != 400 {
+		t.Errorf("expected only photos to waste space, got %v", top)
+	}
+}
+
+func TestDirStatsStoredInIndex(t *testing.T) {
+	testDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(testDir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{"a.txt": "same", "b.txt": "same", filepath.Join("sub", "c.txt"): "other"} {
+		if err := os.WriteFile(filepath.Join(testDir, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	loaded := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	expected := map[string]*DirStats{
+		".":   {FileCount: 2, TotalSize: 8, DuplicateCount: 2, WastedBytes: 4},
+		"sub": {FileCount: 1, TotalSize: 5},
+	}
+	if !reflect.DeepEqual(loaded.DirStats, expected) {
+		t.Errorf("expected the statistics to be saved in the index file, got %v", loaded.DirStats)
+	}
+}
+
+func TestTopDirectoriesBySize(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"h1": {{Path: filepath.Join("a", "big.bin"), Size: 1000}, {Path: filepath.Join("b", "big.bin"), Size: 1000}},
+		"h2": {{Path: filepath.Join("a", "sub", "deep.txt"), Size: 500}},
+		"h3": {{Path: filepath.Join("b", "small.txt"), Size: 100}},
+		"h4": {{Path: "root.txt", Size: 50}},
+	}
+
+	expected := []DirSizeEntry{
+		{Dir: "a", TotalSize: 1500, FileCount: 2},
+		{Dir: "b", TotalSize: 1100, FileCount: 2},
+		{Dir: ".", TotalSize: 50, FileCount: 1},
+	}
+	if top := idx.TopDirectoriesBySize(0); !reflect.DeepEqual(top, expected) {
+		t.Errorf("expected %v, got %v", expected, top)
+	}
+	if top := idx.TopDirectoriesBySize(2); !reflect.DeepEqual(top, expected[:2]) {
+		t.Errorf("expected %v, got %v", expected[:2], top)
+	}
+}
+
+func TestGroupByExtension(t *testing.T) {
+	testDir := t.TempDir()
+	files := map[string]string{"main.go": "package main", "util.go": "package util", "photo.JPG": "jpeg", "a.jpg": "jpeg", "b.jpg": "other", "Makefile": "all:"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	expected := map[string]*ExtensionStats{
+		"go":  {FileCount: 2, TotalSize: 24},
+		"jpg": {FileCount: 3, TotalSize: 13},
+		"":    {FileCount: 1, TotalSize: 4},
+	}
+	if stats := idx.GroupByExtension(); !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected %v, got %v", expected, stats)
+	}
+}
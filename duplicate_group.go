@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// DuplicateGroup represents a group of files with identical content.
+type DuplicateGroup struct {
+	Hash        string      `json:"hash"`
+	Files       []*FileInfo `json:"files"`                   // Sorted by path.
+	WastedBytes int64       `json:"wasted_bytes"`            // Space used by all the copies but one.
+	FirstSeenAt *time.Time  `json:"first_seen_at,omitempty"` // Time of the earliest snapshot with the group, if known.
+	AgeWarning  string      `json:"age_warning,omitempty"`   // Set by MarkAgeDivergence if the copies have very different ages.
+}
+
+// AgeRange returns the time between the modification of the oldest and the newest files of the group.
+func (g *DuplicateGroup) AgeRange() time.Duration {
+	if len(g.Files) == 0 {
+		return 0
+	}
+
+	oldest, newest := g.Files[0].ModTime, g.Files[0].ModTime
+	for _, file := range g.Files[1:] {
+		if file.ModTime.Before(oldest) {
+			oldest = file.ModTime
+		}
+		if file.ModTime.After(newest) {
+			newest = file.ModTime
+		}
+	}
+	return newest.Sub(oldest)
+}
+
+// HasAgeDivergence returns true if the newest file of the group was modified more than threshold after the oldest
+// one. The newest copy may then be an updated version of the file that was reverted, and should not be deleted
+// without checking.
+func (g *DuplicateGroup) HasAgeDivergence(threshold time.Duration) bool {
+	return g.AgeRange() > threshold
+}
+
+// MarkAgeDivergence sets the age warning of the groups whose files have an age divergence over threshold,
+// e.g. "age divergence: 45d".
+func MarkAgeDivergence(groups []DuplicateGroup, threshold time.Duration) {
+	for i := range groups {
+		if groups[i].HasAgeDivergence(threshold) {
+			groups[i].AgeWarning = "age divergence: " + formatAge(groups[i].AgeRange())
+		}
+	}
+}
+
+// newDuplicateGroups converts duplicates to groups, sorted by wasted space descending (then by hash).
+func newDuplicateGroups(duplicates map[string][]*FileInfo) []DuplicateGroup {
+	groups := make([]DuplicateGroup, 0, len(duplicates))
+
+	for hash, files := range duplicates {
+		sortedFiles := append([]*FileInfo{}, files...)
+		sort.Slice(sortedFiles, func(i, j int) bool {
+			return sortedFiles[i].Path < sortedFiles[j].Path
+		})
+
+		groups = append(groups, DuplicateGroup{
+			Hash:        hash,
+			Files:       sortedFiles,
+			WastedBytes: files[0].Size * int64(len(files)-1),
+		})
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].WastedBytes != groups[j].WastedBytes {
+			return groups[i].WastedBytes > groups[j].WastedBytes
+		}
+		return groups[i].Hash < groups[j].Hash
+	})
+
+	return groups
+}
+
+// sortDuplicateGroupsByAge sets the time each group was first seen in the snapshots and sorts the groups oldest first.
+// The groups not found in any snapshot come last, keeping their order.
+func sortDuplicateGroupsByAge(groups []DuplicateGroup, snapshots []*Index) {
+	history := &ChangeHistory{}
+	for i := range groups {
+		groups[i].FirstSeenAt = history.FindGroupFirstSeen(groups[i].Hash, snapshots)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[j].FirstSeenAt == nil {
+			return groups[i].FirstSeenAt != nil
+		}
+		return groups[i].FirstSeenAt != nil && groups[i].FirstSeenAt.Before(*groups[j].FirstSeenAt)
+	})
+}
+
+// paginateDuplicateGroups returns at most limit groups starting at offset.
+// All the groups after the offset are returned when limit is 0 or negative.
+func paginateDuplicateGroups(groups []DuplicateGroup, offset int, limit int) []DuplicateGroup {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(groups) {
+		return []DuplicateGroup{}
+	}
+
+	groups = groups[offset:]
+	if limit > 0 && limit < len(groups) {
+		groups = groups[:limit]
+	}
+
+	return groups
+}
+
+// SortedDuplicateGroups returns all the groups of duplicate files, sorted by wasted space descending.
+// The index must be loaded before calling this method.
+func (idx *Index) SortedDuplicateGroups() []DuplicateGroup {
+	return newDuplicateGroups(idx.FindAllDuplicates())
+}
+
+// TopNDuplicateGroups returns the n groups of duplicate files wasting the most space.
+// All the groups are returned when n is 0 or negative.
+// The index must be loaded before calling this method.
+func (idx *Index) TopNDuplicateGroups(n int) []DuplicateGroup {
+	return paginateDuplicateGroups(idx.SortedDuplicateGroups(), 0, n)
+}
+
+// StreamDuplicates writes each group of duplicates to w as soon as it is found, as one JSON object per line with its
+// hash, files and wasted bytes, without building the list of all the groups first. The groups are in no
+// particular order. Empty files are left out with SkipZeroBytes, like with FindAllDuplicates.
+// The index must be loaded before calling this method.
+func (idx *Index) StreamDuplicates(ctx context.Context, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+
+	for hash, files := range idx.FilesByContentHash {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(files) < 2 || (idx.SkipZeroBytes && files[0].Size == 0) {
+			continue
+		}
+
+		group := newDuplicateGroups(map[string][]*FileInfo{hash: files})[0]
+		if err := encoder.Encode(group); err != nil {
+			return fmt.Errorf("failed to write group: %w", err)
+		}
+	}
+
+	return nil
+}
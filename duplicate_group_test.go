@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestDuplicatesIndex() *Index {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/tmp"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"small":  {{Path: "b.txt", Size: 10}, {Path: "a.txt", Size: 10}},
+		"large":  {{Path: "big1.bin", Size: 1000}, {Path: "big2.bin", Size: 1000}},
+		"many":   {{Path: "m1", Size: 100}, {Path: "m2", Size: 100}, {Path: "m3", Size: 100}},
+		"single": {{Path: "unique.txt", Size: 5000}},
+	}
+	return idx
+}
+
+func TestSortedDuplicateGroups(t *testing.T) {
+	groups := newTestDuplicatesIndex().SortedDuplicateGroups()
+
+	expectedHashes := []string{"large", "many", "small"}
+	expectedWasted := []int64{1000, 200, 10}
+	if len(groups) != len(expectedHashes) {
+		t.Fatalf("expected %d groups, got %d", len(expectedHashes), len(groups))
+	}
+	for i, group := range groups {
+		if group.Hash != expectedHashes[i] {
+			t.Errorf("group %d: expected hash %q, got %q", i, expectedHashes[i], group.Hash)
+		}
+		if group.WastedBytes != expectedWasted[i] {
+			t.Errorf("group %d: expected %d wasted bytes, got %d", i, expectedWasted[i], group.WastedBytes)
+		}
+	}
+
+	if groups[2].Files[0].Path != "a.txt" {
+		t.Errorf("expected files sorted by path, got %v first", groups[2].Files[0].Path)
+	}
+}
+
+func TestTopNDuplicateGroups(t *testing.T) {
+	idx := newTestDuplicatesIndex()
+
+	tests := []struct {
+		name           string
+		n              int
+		expectedGroups int
+	}{
+		{"limit_1", 1, 1},
+		{"limit_more_than_groups", 1000, 3},
+		{"zero_returns_all", 0, 3},
+		{"negative_returns_all", -1, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if groups := idx.TopNDuplicateGroups(tt.n); len(groups) != tt.expectedGroups {
+				t.Errorf("expected %d groups, got %d", tt.expectedGroups, len(groups))
+			}
+		})
+	}
+
+	if groups := idx.TopNDuplicateGroups(1); groups[0].Hash != "large" {
+		t.Errorf("expected top group to be the one wasting the most space, got %q", groups[0].Hash)
+	}
+}
+
+func TestPaginateDuplicateGroups(t *testing.T) {
+	groups := newTestDuplicatesIndex().SortedDuplicateGroups()
+
+	page := paginateDuplicateGroups(groups, 1, 1)
+	if len(page) != 1 || page[0].Hash != "many" {
+		t.Errorf("expected second group only, got %v", page)
+	}
+
+	if page := paginateDuplicateGroups(groups, 2, 10); len(page) != 1 {
+		t.Errorf("expected 1 group after offset 2, got %d", len(page))
+	}
+
+	if page := paginateDuplicateGroups(groups, 5, 1); len(page) != 0 {
+		t.Errorf("expected no group after the end, got %d", len(page))
+	}
+}
+
+func TestPrintDuplicateGroups(t *testing.T) {
+	groups := newTestDuplicatesIndex().SortedDuplicateGroups()
+
+	var buf bytes.Buffer
+	printDuplicateGroups(&buf, groups[:1], len(groups), fileDetails{})
+	if !strings.Contains(buf.String(), "(showing 1 of 3 groups)") {
+		t.Errorf("expected pagination summary, got %q", buf.String())
+	}
+
+	buf.Reset()
+	printDuplicateGroups(&buf, groups, len(groups), fileDetails{})
+	if strings.Contains(buf.String(), "showing") {
+		t.Errorf("expected no pagination summary when all groups are shown, got %q", buf.String())
+	}
+}
+
+func TestStreamDuplicates(t *testing.T) {
+	idx := newTestDuplicatesIndex()
+
+	var buf bytes.Buffer
+	if err := idx.StreamDuplicates(context.Background(), &buf); err != nil {
+		t.Fatalf("StreamDuplicates failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(idx.FindAllDuplicates()) {
+		t.Fatalf("expected %d groups, got %d: %q", len(idx.FindAllDuplicates()), len(lines), buf.String())
+	}
+	for _, line := range lines {
+		var group map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(line), &group); err != nil {
+			t.Fatalf("line %q is not valid JSON: %v", line, err)
+		}
+		if _, exists := group["hash"]; !exists {
+			t.Errorf("line %q has no hash", line)
+		}
+		if _, exists := group["files"]; !exists {
+			t.Errorf("line %q has no files", line)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := idx.StreamDuplicates(ctx, &bytes.Buffer{}); err == nil {
+		t.Error("expected an error with a cancelled context")
+	}
+}
+
+func TestAgeDivergence(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	groups := []DuplicateGroup{
+		{Hash: "diverging", Files: []*FileInfo{
+			{Path: "old.txt", ModTime: base},
+			{Path: "new.txt", ModTime: base.Add(45 * 24 * time.Hour)},
+			{Path: "middle.txt", ModTime: base.Add(24 * time.Hour)},
+		}},
+		{Hash: "close", Files: []*FileInfo{
+			{Path: "a.txt", ModTime: base},
+			{Path: "b.txt", ModTime: base.Add(2 * 24 * time.Hour)},
+		}},
+	}
+
+	if ageRange := groups[0].AgeRange(); ageRange != 45*24*time.Hour {
+		t.Errorf("expected an age range of 45 days, got %v", ageRange)
+	}
+	if !groups[0].HasAgeDivergence(30 * 24 * time.Hour) {
+		t.Error("expected an age divergence over 30 days")
+	}
+	if groups[1].HasAgeDivergence(30 * 24 * time.Hour) {
+		t.Error("expected no age divergence for files modified 2 days apart")
+	}
+
+	MarkAgeDivergence(groups, 30*24*time.Hour)
+	if groups[0].AgeWarning != "age divergence: 45d" || groups[1].AgeWarning != "" {
+		t.Errorf("expected a warning for the diverging group only, got %q and %q", groups[0].AgeWarning, groups[1].AgeWarning)
+	}
+
+	var buf bytes.Buffer
+	printDuplicateGroups(&buf, groups, len(groups), fileDetails{})
+	if strings.Count(buf.String(), "⚠ age divergence: 45d") != 1 {
+		t.Errorf("expected the warning to be printed once, got %q", buf.String())
+	}
+}
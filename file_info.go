@@ -1,46 +1,198 @@
 package main
 
 import (
+	"crypto/md5"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"os"
+	"sort"
+	"sync"
 	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Supported hash algorithms.
+const (
+	HashSHA256  = "sha256"
+	HashSHA512  = "sha512"  // Faster than SHA-256 on 64-bit CPUs without SHA extensions.
+	HashBLAKE2b = "blake2b" // BLAKE2b-256, cryptographic and faster than SHA-256 without SHA extensions.
+	HashMD5     = "md5"     // Broken for security, e.g. to compare with md5sum outputs.
+	HashXXHash  = "xxhash"  // Non-cryptographic but much faster, 64-bit.
 )
 
+// DefaultHashAlgorithm is the hash algorithm used when none is specified.
+const DefaultHashAlgorithm = HashSHA256
+
+var hashFactories = map[string]func() hash.Hash{
+	HashSHA256: sha256.New,
+	HashSHA512: sha512.New,
+	HashBLAKE2b: func() hash.Hash {
+		hasher, _ := blake2b.New256(nil) // Only fails with a key longer than 64 bytes.
+		return hasher
+	},
+	HashMD5:    md5.New,
+	HashXXHash: func() hash.Hash { return xxhash.New() },
+}
+
+// hasherPools reuses hashers across files to reduce allocations, with one pool per algorithm.
+var hasherPools = func() map[string]*sync.Pool {
+	pools := make(map[string]*sync.Pool, len(hashFactories))
+	for algorithm, factory := range hashFactories {
+		factory := factory
+		pools[algorithm] = &sync.Pool{New: func() any { return factory() }}
+	}
+	return pools
+}()
+
 // FileInfo represents info associated to a file.
 type FileInfo struct {
-	Path    string    `json:"path"`
-	Size    int64     `json:"size"`
-	ModTime time.Time `json:"mod_time"`
+	Path        string      `json:"path"`
+	Size        int64       `json:"size"`
+	ModTime     time.Time   `json:"mod_time"`
+	Mode        os.FileMode `json:"mode,omitempty"`         // Permission bits, only when they are tracked.
+	SuspectHash bool        `json:"suspect_hash,omitempty"` // Whether another hash was computed when reading the file again, see VerifyHash.
 }
 
-// ProcessFile processes a file by reading its content and returning its hash and FileInfo.
-func ProcessFile(absPath string, relPath string) (hash string, fileInfo *FileInfo, err error) {
-	info, err := os.Stat(absPath)
+// String returns a readable description of the file, e.g. "path=docs/report.pdf size=1.5 KB mtime=2024-01-02T15:04:05Z".
+func (fi *FileInfo) String() string {
+	if fi == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("path=%s size=%s mtime=%s", fi.Path, FormatSize(fi.Size), fi.ModTime.Format(time.RFC3339))
+}
+
+// GoString returns the file as a Go literal, with the modification time in UTC, for the %#v format.
+func (fi *FileInfo) GoString() string {
+	if fi == nil {
+		return "(*main.FileInfo)(nil)"
+	}
+	t := fi.ModTime.UTC()
+	return fmt.Sprintf("&main.FileInfo{Path: %q, Size: %d, ModTime: time.Date(%d, time.%s, %d, %d, %d, %d, %d, time.UTC), Mode: %#o, SuspectHash: %t}",
+		fi.Path, fi.Size, t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), uint32(fi.Mode), fi.SuspectHash)
+}
+
+// SupportedHashAlgorithms returns the names of the supported hash algorithms, sorted.
+func SupportedHashAlgorithms() []string {
+	algorithms := make([]string, 0, len(hashFactories))
+	for algorithm := range hashFactories {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	return algorithms
+}
+
+// newHasher returns a new hasher for the given algorithm.
+func newHasher(algorithm string) (hash.Hash, error) {
+	factory, exists := hashFactories[algorithm]
+	if !exists {
+		return nil, fmt.Errorf("unsupported hash algorithm %q (supported: %v)", algorithm, SupportedHashAlgorithms())
+	}
+	return factory(), nil
+}
+
+// getHasher returns a reset hasher for the given algorithm from the pool.
+// It must be given back with putHasher once done.
+func getHasher(algorithm string) (hash.Hash, error) {
+	pool, exists := hasherPools[algorithm]
+	if !exists {
+		return nil, fmt.Errorf("unsupported hash algorithm %q (supported: %v)", algorithm, SupportedHashAlgorithms())
+	}
+
+	hasher := pool.Get().(hash.Hash)
+	hasher.Reset()
+	return hasher, nil
+}
+
+// putHasher gives a hasher obtained with getHasher back to the pool.
+func putHasher(algorithm string, hasher hash.Hash) {
+	hasherPools[algorithm].Put(hasher)
+}
+
+// openFile opens the files to hash, and can be replaced in tests.
+var openFile = func(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
+// hashFile returns the hash of the content of the file computed with the given algorithm, reading it at most at the
+// given rate, unless it is nil.
+func hashFile(absPath string, algorithm string, rate *readRate) (string, error) {
+	hasher, err := getHasher(algorithm)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to stat file: %w", err)
+		return "", err
 	}
+	defer putHasher(algorithm, hasher)
+
+	return hashFileWith(absPath, hasher, rate)
+}
 
-	file, err := os.Open(absPath)
+// hashFileWith is like hashFile, but computes the hash with the given hasher, which must be reset.
+func hashFileWith(absPath string, hasher hash.Hash, rate *readRate) (string, error) {
+	file, err := openFile(absPath)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to open file: %w", err)
+		return "", fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
-	hasher := sha256.New()
-	if _, err := io.Copy(hasher, file); err != nil {
-		return "", nil, fmt.Errorf("failed to read file for hashing: %w", err)
+	var reader io.Reader = file
+	if rate != nil {
+		reader = newRateLimiter(file, rate)
 	}
 
-	hash = hex.EncodeToString(hasher.Sum(nil))
+	if _, err := io.Copy(hasher, reader); err != nil {
+		return "", fmt.Errorf("failed to read file for hashing: %w", err)
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// ProcessFile processes a file by reading its content and returning its hash (computed with the given algorithm) and FileInfo.
+func ProcessFile(absPath string, relPath string, algorithm string) (hash string, fileInfo *FileInfo, err error) {
+	return processFileWithRateLimit(absPath, relPath, algorithm, nil, false)
+}
+
+// ProcessFileWithHash is like ProcessFile, but computes the hash with a hasher returned by newHash, e.g. for an
+// algorithm that is not one of SupportedHashAlgorithms.
+func ProcessFileWithHash(absPath string, relPath string, newHash func() hash.Hash) (string, *FileInfo, error) {
+	return processFileWith(absPath, relPath, false, func() (string, error) {
+		return hashFileWith(absPath, newHash(), nil)
+	})
+}
+
+// processFileWithRateLimit is ProcessFile reading the file at most at the given rate, unless it is nil,
+// and also returning the permission bits of the file in its FileInfo if includeMode is set.
+func processFileWithRateLimit(absPath string, relPath string, algorithm string, rate *readRate, includeMode bool) (hash string, fileInfo *FileInfo, err error) {
+	return processFileWith(absPath, relPath, includeMode, func() (string, error) {
+		return hashFile(absPath, algorithm, rate)
+	})
+}
+
+// processFileWith returns the hash of the file computed by hashContent and its FileInfo, with its permission bits
+// if includeMode is set.
+func processFileWith(absPath string, relPath string, includeMode bool, hashContent func() (string, error)) (hash string, fileInfo *FileInfo, err error) {
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	hash, err = hashContent()
+	if err != nil {
+		return "", nil, err
+	}
 
 	fileInfo = &FileInfo{
 		Path:    relPath,
 		Size:    info.Size(),
 		ModTime: info.ModTime(),
 	}
+	if includeMode {
+		fileInfo.Mode = info.Mode().Perm()
+	}
 
 	return hash, fileInfo, nil
 }
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestProcessFile(t *testing.T) {
@@ -18,7 +22,7 @@ func TestProcessFile(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	hash, fileInfo, err := ProcessFile(absPath, relPath)
+	hash, fileInfo, err := ProcessFile(absPath, relPath, DefaultHashAlgorithm)
 	if err != nil {
 		t.Fatalf("ProcessFile() failed: %v", err)
 	}
@@ -38,7 +42,7 @@ func TestProcessFile(t *testing.T) {
 		t.Errorf("FileInfo not equal: got %v, want %v", *fileInfo, *expectedFileInfo)
 	}
 
-	hash2, _, err := ProcessFile(absPath, relPath)
+	hash2, _, err := ProcessFile(absPath, relPath, DefaultHashAlgorithm)
 	if err != nil {
 		t.Fatalf("ProcessFile() second call failed: %v", err)
 	}
@@ -47,3 +51,164 @@ func TestProcessFile(t *testing.T) {
 		t.Errorf("expected same hash for same file, got %s and %s", hash, hash2)
 	}
 }
+
+func TestProcessFileHashAlgorithms(t *testing.T) {
+	testDir := t.TempDir()
+	absPath := filepath.Join(testDir, "file.txt")
+
+	if err := os.WriteFile(absPath, []byte("Hello, World!"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		algorithm      string
+		expectedLength int
+	}{
+		{HashSHA256, 64},
+		{HashSHA512, 128},
+		{HashBLAKE2b, 64},
+		{HashMD5, 32},
+		{HashXXHash, 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			hash, _, err := ProcessFile(absPath, "file.txt", tt.algorithm)
+			if err != nil {
+				t.Fatalf("ProcessFile() failed: %v", err)
+			}
+			if len(hash) != tt.expectedLength {
+				t.Errorf("expected hash of length %d, got %q", tt.expectedLength, hash)
+			}
+		})
+	}
+
+	if _, _, err := ProcessFile(absPath, "file.txt", "unknown"); err == nil {
+		t.Error("expected error for unsupported hash algorithm, got nil")
+	}
+}
+
+func TestProcessFileWithHash(t *testing.T) {
+	absPath := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(absPath, []byte("Hello, World!"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	hash, fileInfo, err := ProcessFileWithHash(absPath, "file.txt", md5.New)
+	if err != nil {
+		t.Fatalf("ProcessFileWithHash() failed: %v", err)
+	}
+	if expected := "65a8e27d8879283831b664bd8b7f0ad4"; hash != expected {
+		t.Errorf("expected MD5 %s, got %s", expected, hash)
+	}
+	if fileInfo.Path != "file.txt" || fileInfo.Size != 13 {
+		t.Errorf("unexpected file info %v", fileInfo)
+	}
+
+	if algorithmHash, _, err := ProcessFile(absPath, "file.txt", HashMD5); err != nil || algorithmHash != hash {
+		t.Errorf("expected the same hash as with the md5 algorithm, got %s (%v)", algorithmHash, err)
+	}
+}
+
+func benchmarkHashAlgorithm(b *testing.B, algorithm string) {
+	data := make([]byte, 8<<20)
+	b.SetBytes(int64(len(data)))
+
+	for i := 0; i < b.N; i++ {
+		hasher, err := newHasher(algorithm)
+		if err != nil {
+			b.Fatalf("newHasher() failed: %v", err)
+		}
+		hasher.Write(data)
+		hasher.Sum(nil)
+	}
+}
+
+func BenchmarkSHA256(b *testing.B) {
+	benchmarkHashAlgorithm(b, HashSHA256)
+}
+
+func BenchmarkXXHash(b *testing.B) {
+	benchmarkHashAlgorithm(b, HashXXHash)
+}
+
+func TestProcessFileReusesHashersCorrectly(t *testing.T) {
+	testDir := t.TempDir()
+
+	contents := [][]byte{[]byte("first content"), []byte("second content"), []byte("")}
+	for i, content := range contents {
+		absPath := filepath.Join(testDir, fmt.Sprintf("file%d.txt", i))
+		if err := os.WriteFile(absPath, content, 0644); err != nil {
+			t.Fatalf("failed to create test file: %v", err)
+		}
+
+		// Hashers coming from the pool must give the same result as fresh ones.
+		for _, algorithm := range SupportedHashAlgorithms() {
+			hash, _, err := ProcessFile(absPath, "file.txt", algorithm)
+			if err != nil {
+				t.Fatalf("ProcessFile() failed: %v", err)
+			}
+
+			fresh, err := newHasher(algorithm)
+			if err != nil {
+				t.Fatalf("newHasher() failed: %v", err)
+			}
+			fresh.Write(content)
+
+			if expected := hex.EncodeToString(fresh.Sum(nil)); hash != expected {
+				t.Errorf("%s hash of %q: expected %s, got %s", algorithm, content, expected, hash)
+			}
+		}
+	}
+}
+
+func BenchmarkProcessFileSmallFiles(b *testing.B) {
+	testDir := b.TempDir()
+
+	const fileCount = 1000
+	paths := make([]string, fileCount)
+	for i := range paths {
+		paths[i] = filepath.Join(testDir, fmt.Sprintf("file%d.txt", i))
+		if err := os.WriteFile(paths[i], []byte(fmt.Sprintf("content %d", i)), 0644); err != nil {
+			b.Fatalf("failed to create test file: %v", err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err := ProcessFile(paths[i%fileCount], "file.txt", DefaultHashAlgorithm); err != nil {
+			b.Fatalf("ProcessFile() failed: %v", err)
+		}
+	}
+}
+
+func TestFileInfoFormatting(t *testing.T) {
+	fi := &FileInfo{
+		Path:    "docs/report.pdf",
+		Size:    1536,
+		ModTime: time.Date(2024, 1, 2, 15, 4, 5, 6, time.UTC),
+	}
+
+	tests := []struct {
+		format   string
+		value    any
+		expected string
+	}{
+		{"%v", fi, "path=docs/report.pdf size=1.5 KB mtime=2024-01-02T15:04:05Z"},
+		{"%s", fi, "path=docs/report.pdf size=1.5 KB mtime=2024-01-02T15:04:05Z"},
+		{"%#v", fi, `&main.FileInfo{Path: "docs/report.pdf", Size: 1536, ModTime: time.Date(2024, time.January, 2, 15, 4, 5, 6, time.UTC), Mode: 0, SuspectHash: false}`},
+		{"%#v", &FileInfo{Path: "run.sh", ModTime: fi.ModTime, Mode: 0755, SuspectHash: true},
+			`&main.FileInfo{Path: "run.sh", Size: 0, ModTime: time.Date(2024, time.January, 2, 15, 4, 5, 6, time.UTC), Mode: 0755, SuspectHash: true}`},
+		{"%v", []*FileInfo{fi}, "[path=docs/report.pdf size=1.5 KB mtime=2024-01-02T15:04:05Z]"},
+		{"%v", (*FileInfo)(nil), "<nil>"},
+		{"%#v", (*FileInfo)(nil), "(*main.FileInfo)(nil)"},
+	}
+
+	for _, tt := range tests {
+		if got := fmt.Sprintf(tt.format, tt.value); got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.format, tt.expected, got)
+		}
+	}
+}
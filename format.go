@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FormatSize returns a human readable representation of a size in bytes (e.g. "1.5 KB").
+func FormatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// ParseSize parses a human readable size, the opposite of FormatSize: a number of bytes optionally followed by a
+// unit, e.g. "500KB", "1.5 MB" or "2G". Units are powers of 1024 and case insensitive.
+func ParseSize(value string) (int64, error) {
+	number := strings.TrimSpace(strings.ToUpper(value))
+	number = strings.TrimSuffix(number, "B")
+
+	multiplier := int64(1)
+	if n := len(number); n > 0 {
+		if exp := strings.IndexByte("KMGTPE", number[n-1]); exp >= 0 {
+			multiplier = 1 << (10 * (exp + 1))
+			number = number[:n-1]
+		}
+	}
+
+	size, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || size < 0 || math.IsNaN(size) {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	// float64(math.MaxInt64) is rounded up to 2^63, which is already too large.
+	bytes := size * float64(multiplier)
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", value)
+	}
+	return int64(bytes), nil
+}
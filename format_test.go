@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		bytes    int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{5 << 20, "5.0 MB"},
+		{45 << 30, "45.0 GB"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatSize(tt.bytes); got != tt.expected {
+			t.Errorf("FormatSize(%d) = %q, want %q", tt.bytes, got, tt.expected)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int64
+	}{
+		{"0", 0},
+		{"100", 100},
+		{"100B", 100},
+		{"500KB", 500 << 10},
+		{"1MB", 1 << 20},
+		{"1.5 mb", 3 << 19},
+		{"2G", 2 << 30},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSize(tt.value)
+		if err != nil {
+			t.Errorf("ParseSize(%q) failed: %v", tt.value, err)
+		} else if got != tt.expected {
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.value, got, tt.expected)
+		}
+	}
+
+	for _, value := range []string{"", "MB", "-1KB", "1XB", "ten", "NaN", "1e30GB", "8EB", "Inf"} {
+		if _, err := ParseSize(value); err == nil {
+			t.Errorf("expected an error for %q", value)
+		}
+	}
+}
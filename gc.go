@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// GCResult contains information about a garbage collection of the index.
+type GCResult struct {
+	Repaired           int   // Duplicate entries removed for paths indexed several times, see Repair.
+	Removed            int   // Entries removed because their file no longer exists.
+	Refreshed          int   // Entries whose hash was recomputed because their file was modified.
+	IndexSizeReduction int64 // Difference between the index file size before and after, in bytes.
+}
+
+// Prune removes the entries of the files that no longer exist from the index, and returns how many were removed.
+// The index file is not saved.
+// The index must be loaded before calling this method.
+func (idx *Index) Prune() (int, error) {
+	if idx.isArchive() {
+		return 0, fmt.Errorf("cannot prune the index of %s: %w", idx.AbsPath, errArchiveIndex)
+	}
+
+	removed := 0
+
+	for hash, files := range idx.FilesByContentHash {
+		kept := files[:0]
+		for _, file := range files {
+			_, err := os.Stat(longPath(filepath.Join(idx.AbsPath, file.Path)))
+			if os.IsNotExist(err) {
+				removed++
+				continue
+			}
+			if err != nil {
+				return removed, fmt.Errorf("failed to stat %s: %w", file.Path, err)
+			}
+			kept = append(kept, file)
+		}
+
+		if len(kept) == 0 {
+			delete(idx.FilesByContentHash, hash)
+		} else {
+			idx.FilesByContentHash[hash] = kept
+		}
+	}
+
+	return removed, nil
+}
+
+// GC repairs the paths indexed several times, removes the entries of the files that no longer exist, recomputes the hashes of the files
+// whose modification time changed since they were indexed, then saves the index file.
+// The index must be loaded before calling this method.
+func (idx *Index) GC() (*GCResult, error) {
+	dataBefore, err := idx.marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &GCResult{}
+
+	result.Repaired = idx.Repair()
+	result.Removed, err = idx.Prune()
+	if err != nil {
+		return nil, err
+	}
+
+	// The .bffignore file is read like for a scan, so that the modified files it now excludes are dropped.
+	if err := idx.LoadIgnoreFile(); err != nil {
+		return nil, err
+	}
+
+	modifiedPaths := []string{}
+	for _, files := range idx.FilesByContentHash {
+		for _, file := range files {
+			info, err := os.Stat(longPath(filepath.Join(idx.AbsPath, file.Path)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to stat %s: %w", file.Path, err)
+			}
+			if !info.ModTime().Equal(file.ModTime) {
+				modifiedPaths = append(modifiedPaths, file.Path)
+			}
+		}
+	}
+
+	for _, path := range modifiedPaths {
+		if err := idx.updatePath(path, nil); err != nil {
+			return nil, err
+		}
+		// The file is dropped instead if it is now hidden, ignored or not selected.
+		if _, indexed := idx.hashOf(path); indexed {
+			result.Refreshed++
+		}
+	}
+
+	if err := idx.save(); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(idx.indexPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat index: %w", err)
+	}
+	result.IndexSizeReduction = int64(len(dataBefore)) - info.Size()
+
+	return result, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPrune(t *testing.T) {
+	testDir := t.TempDir()
+
+	for _, name := range []string{"keep.txt", "delete1.txt", "delete2.txt"} {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	for _, name := range []string{"delete1.txt", "delete2.txt"} {
+		if err := os.Remove(filepath.Join(testDir, name)); err != nil {
+			t.Fatalf("failed to delete file: %v", err)
+		}
+	}
+
+	removed, err := idx.Prune()
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("expected 2 entries removed, got %d", removed)
+	}
+	if len(idx.FilesByContentHash) != 1 {
+		t.Errorf("expected 1 hash left, got %d", len(idx.FilesByContentHash))
+	}
+}
+
+func TestGC(t *testing.T) {
+	testDir := t.TempDir()
+
+	for _, name := range []string{"unchanged.txt", "modified.txt", "deleted.txt"} {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	modifiedPath := filepath.Join(testDir, "modified.txt")
+	if err := os.WriteFile(modifiedPath, []byte("new content"), 0644); err != nil {
+		t.Fatalf("failed to modify file: %v", err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(modifiedPath, future, future); err != nil {
+		t.Fatalf("failed to change modification time: %v", err)
+	}
+	if err := os.Remove(filepath.Join(testDir, "deleted.txt")); err != nil {
+		t.Fatalf("failed to delete file: %v", err)
+	}
+
+	loaded := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	indexPath := filepath.Join(testDir, IndexFile)
+	before, err := os.Stat(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := loaded.GC()
+	if err != nil {
+		t.Fatalf("GC() failed: %v", err)
+	}
+
+	if result.Removed != 1 {
+		t.Errorf("expected 1 entry removed, got %d", result.Removed)
+	}
+	if result.Refreshed != 1 {
+		t.Errorf("expected 1 hash refreshed, got %d", result.Refreshed)
+	}
+	after, err := os.Stat(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IndexSizeReduction <= 0 || result.IndexSizeReduction != before.Size()-after.Size() {
+		t.Errorf("expected the index file to be %d bytes smaller, got %d", before.Size()-after.Size(), result.IndexSizeReduction)
+	}
+
+	saved := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := saved.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	comparison, err := saved.Compare()
+	if err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+	if comparison.HasChanges() {
+		t.Errorf("expected index to be accurate after GC, got changes: %+v", comparison)
+	}
+}
+
+func TestGCModifiedFileNowIgnored(t *testing.T) {
+	testDir := t.TempDir()
+	ignoredPath := filepath.Join(testDir, "ignored.txt")
+	for path, content := range map[string]string{ignoredPath: "content", filepath.Join(testDir, "kept.txt"): "kept"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(ignoredPath, future, future); err != nil {
+		t.Fatalf("failed to change modification time: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, IgnoreFile), []byte("ignored.txt\n"), 0644); err != nil {
+		t.Fatalf("failed to create ignore file: %v", err)
+	}
+
+	loaded := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	result, err := loaded.GC()
+	if err != nil {
+		t.Fatalf("GC() failed: %v", err)
+	}
+	if result.Refreshed != 0 {
+		t.Errorf("expected the ignored file not to be counted as refreshed, got %d", result.Refreshed)
+	}
+	if _, indexed := loaded.hashOf("ignored.txt"); indexed {
+		t.Error("expected the ignored file to be dropped from the index")
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// GitignoreFile is the name of the files containing git exclusion patterns.
+const GitignoreFile = ".gitignore"
+
+// gitignoreRule is a single pattern of a .gitignore file.
+type gitignoreRule struct {
+	base     string   // Directory containing the .gitignore file, relative to the root ("" for the root itself).
+	segments []string // Pattern split on slashes.
+	negate   bool     // Pattern starting with "!", re-including previously excluded paths.
+	dirOnly  bool     // Pattern ending with "/", only matching directories.
+	anchored bool     // Pattern containing a slash, matched against the path relative to base instead of the name.
+}
+
+// gitignoreParser applies the patterns of the .gitignore files found in a directory tree.
+// It supports "*", "?", "[...]" and "**" wildcards, "!" negations and directory-only patterns ending with "/".
+type gitignoreParser struct {
+	rules []gitignoreRule
+}
+
+// load reads all the .gitignore files under the root path, parents before their subdirectories
+// so that the rules of nested files take precedence.
+func (p *gitignoreParser) load(rootPath string) error {
+	return filepath.Walk(rootPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("walk error at %s: %w", filePath, err)
+		}
+
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || info.Name() != GitignoreFile {
+			return nil
+		}
+
+		relDir, err := filepath.Rel(rootPath, filepath.Dir(filePath))
+		if err != nil {
+			return fmt.Errorf("failed to get relative path for %s: %w", filePath, err)
+		}
+		if relDir == "." {
+			relDir = ""
+		}
+
+		return p.loadFile(filePath, filepath.ToSlash(relDir))
+	})
+}
+
+// loadFile parses the .gitignore file at the given path, located in the base directory.
+func (p *gitignoreParser) loadFile(filePath string, base string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseGitignoreLine(scanner.Text(), base); ok {
+			p.rules = append(p.rules, rule)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+
+	return nil
+}
+
+// parseGitignoreLine parses a line of a .gitignore file, returning false for blank lines and comments.
+func parseGitignoreLine(line string, base string) (gitignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return gitignoreRule{}, false
+	}
+
+	rule := gitignoreRule{base: base}
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		// Escaped leading "#" or "!".
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	if line == "" {
+		return gitignoreRule{}, false
+	}
+
+	rule.segments = strings.Split(line, "/")
+
+	return rule, true
+}
+
+// matches returns true if the path, relative to the root, is excluded by the .gitignore files,
+// either directly or because one of its parent directories is.
+func (p *gitignoreParser) matches(relPath string, isDir bool) bool {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+
+	for i := 1; i < len(parts); i++ {
+		if p.isExcluded(parts[:i], true) {
+			return true
+		}
+	}
+
+	return p.isExcluded(parts, isDir)
+}
+
+// isExcluded returns true if the last rule matching the path is not a negation.
+func (p *gitignoreParser) isExcluded(parts []string, isDir bool) bool {
+	excluded := false
+	for _, rule := range p.rules {
+		if rule.match(parts, isDir) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
+
+// match returns true if the rule matches the path given as its slash-separated parts.
+func (r gitignoreRule) match(parts []string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	if r.base != "" {
+		baseParts := strings.Split(r.base, "/")
+		if len(parts) <= len(baseParts) {
+			return false
+		}
+		for i, basePart := range baseParts {
+			if parts[i] != basePart {
+				return false
+			}
+		}
+		parts = parts[len(baseParts):]
+	}
+
+	if !r.anchored {
+		matched, _ := path.Match(r.segments[0], parts[len(parts)-1])
+		return matched
+	}
+
+	return matchGitignoreSegments(r.segments, parts)
+}
+
+// matchGitignoreSegments matches path parts against pattern segments, where "**" matches any number of parts.
+func matchGitignoreSegments(segments []string, parts []string) bool {
+	if len(segments) == 0 {
+		return len(parts) == 0
+	}
+
+	if segments[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchGitignoreSegments(segments[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(parts) == 0 {
+		return false
+	}
+
+	matched, _ := path.Match(segments[0], parts[0])
+	return matched && matchGitignoreSegments(segments[1:], parts[1:])
+}
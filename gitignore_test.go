@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitignoreParserMatches(t *testing.T) {
+	testDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(testDir, GitignoreFile), []byte("# comment\n*.log\nbuild/\n!important.log\n/root-only.txt\ndocs/**/*.tmp\n"), 0644); err != nil {
+		t.Fatalf("failed to create %s: %v", GitignoreFile, err)
+	}
+	if err := os.MkdirAll(filepath.Join(testDir, "sub"), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "sub", GitignoreFile), []byte("secret?.txt\n"), 0644); err != nil {
+		t.Fatalf("failed to create %s: %v", GitignoreFile, err)
+	}
+
+	parser := &gitignoreParser{}
+	if err := parser.load(testDir); err != nil {
+		t.Fatalf("load() failed: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"debug.log", false, true},
+		{"sub/debug.log", false, true},
+		{"important.log", false, false},
+		{"main.go", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"build/output.bin", false, true},
+		{"sub/build", true, true},
+		{"root-only.txt", false, true},
+		{"sub/root-only.txt", false, false},
+		{"docs/a/b/file.tmp", false, true},
+		{"docs/file.tmp", false, true},
+		{"other/file.tmp", false, false},
+		{"sub/secret1.txt", false, true},
+		{"secret1.txt", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := parser.matches(tt.path, tt.isDir); got != tt.expected {
+			t.Errorf("matches(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.expected)
+		}
+	}
+}
+
+func TestIndexUseGitignore(t *testing.T) {
+	testDir := t.TempDir()
+
+	files := map[string]string{
+		GitignoreFile:        "*.log\nbuild/\n!important.log\n",
+		"main.go":            "package main",
+		"debug.log":          "debug",
+		"important.log":      "important",
+		"build/output.bin":   "binary",
+		"src/nested/app.log": "app",
+	}
+	for path, content := range files {
+		absPath := filepath.Join(testDir, path)
+		if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(absPath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	idx.UseGitignore = true
+	result, err := idx.IndexWithResult()
+	if err != nil {
+		t.Fatalf("IndexWithResult() failed: %v", err)
+	}
+
+	if result.FileCount != 2 {
+		t.Errorf("expected 2 files indexed, got %d", result.FileCount)
+	}
+	for _, path := range []string{"main.go", "important.log"} {
+		if _, found := idx.hashOf(path); !found {
+			t.Errorf("expected %q to be indexed", path)
+		}
+	}
+
+	loaded := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if !loaded.UseGitignore {
+		t.Error("expected gitignore setting to be persisted")
+	}
+}
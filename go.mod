@@ -1,3 +1,12 @@
 module bff
 
 go 1.21
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/fsnotify/fsnotify v1.7.0
+	golang.org/x/crypto v0.5.0
+	golang.org/x/term v0.4.0
+)
+
+require golang.org/x/sys v0.4.0 // indirect
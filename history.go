@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// SnapshotPattern is the name pattern of the index snapshots loaded by LoadSnapshots.
+const SnapshotPattern = "bff-*.json"
+
+// ChangeHistory tracks how many times each file was modified across successive snapshots of an index.
+type ChangeHistory struct {
+	Modifications map[string]int // Number of modifications by relative path.
+}
+
+// PathCount is a path along with a number of occurrences, e.g. of modifications.
+type PathCount struct {
+	Path  string
+	Count int
+}
+
+// LoadSnapshots loads all the index snapshots of a directory, i.e. the files matching SnapshotPattern.
+func LoadSnapshots(dir string) ([]*Index, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, SnapshotPattern))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	snapshots := make([]*Index, 0, len(paths))
+	for _, path := range paths {
+		snapshot := NewIndex("", false)
+		if err := snapshot.loadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+// Analyze diffs the consecutive snapshots in chronological order of their update time and returns the number
+// of times each path was modified. Files truncated to zero bytes count as modified.
+func (h *ChangeHistory) Analyze(snapshots []*Index) map[string]int {
+	sorted := append([]*Index{}, snapshots...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].UpdatedAt.Before(sorted[j].UpdatedAt)
+	})
+
+	h.Modifications = make(map[string]int)
+	for i := 1; i < len(sorted); i++ {
+		comparison := sorted[i-1].Diff(sorted[i])
+		for _, path := range comparison.Modified {
+			h.Modifications[path]++
+		}
+		for _, file := range comparison.Truncated {
+			h.Modifications[file.Path]++
+		}
+	}
+
+	return h.Modifications
+}
+
+// FindGroupFirstSeen returns the update time of the earliest snapshot in which at least two files had the given hash,
+// or nil if the files were never duplicated in any of the snapshots.
+func (h *ChangeHistory) FindGroupFirstSeen(hash string, snapshots []*Index) *time.Time {
+	var firstSeen *time.Time
+	for _, snapshot := range snapshots {
+		if len(snapshot.FilesByContentHash[hash]) < 2 {
+			continue
+		}
+		if firstSeen == nil || snapshot.UpdatedAt.Before(*firstSeen) {
+			updatedAt := snapshot.UpdatedAt
+			firstSeen = &updatedAt
+		}
+	}
+	return firstSeen
+}
+
+// TopChanged returns the n most modified paths of the last analysis, the most modified first,
+// then sorted by path. All of them are returned if n is not positive.
+func (h *ChangeHistory) TopChanged(n int) []PathCount {
+	counts := make([]PathCount, 0, len(h.Modifications))
+	for path, count := range h.Modifications {
+		counts = append(counts, PathCount{Path: path, Count: count})
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Path < counts[j].Path
+	})
+
+	if n > 0 && n < len(counts) {
+		counts = counts[:n]
+	}
+	return counts
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChangeHistoryAnalyze(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	snapshot := func(updatedAt time.Time, files map[string]string) *Index {
+		idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+		idx.UpdatedAt = updatedAt
+		for path, hash := range files {
+			idx.FilesByContentHash[hash] = append(idx.FilesByContentHash[hash], &FileInfo{Path: path, Size: 1})
+		}
+		return idx
+	}
+
+	first := snapshot(start, map[string]string{"often.txt": "a1", "once.txt": "b1", "never.txt": "c1"})
+	second := snapshot(start.Add(time.Hour), map[string]string{"often.txt": "a2", "once.txt": "b1", "never.txt": "c1"})
+	third := snapshot(start.Add(2*time.Hour), map[string]string{"often.txt": "a3", "once.txt": "b2", "never.txt": "c1"})
+
+	history := &ChangeHistory{}
+	// Snapshots are analyzed in chronological order whatever the order they are given in.
+	counts := history.Analyze([]*Index{third, first, second})
+
+	expected := map[string]int{"often.txt": 2, "once.txt": 1}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("expected %v, got %v", expected, counts)
+	}
+
+	top := history.TopChanged(1)
+	if !reflect.DeepEqual(top, []PathCount{{Path: "often.txt", Count: 2}}) {
+		t.Errorf("expected often.txt to be the most changed file, got %v", top)
+	}
+
+	if all := history.TopChanged(0); len(all) != 2 {
+		t.Errorf("expected all 2 changed files, got %v", all)
+	}
+}
+
+func TestLoadSnapshots(t *testing.T) {
+	snapshotsDir := t.TempDir()
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+	idx.FilesByContentHash["hash1"] = []*FileInfo{{Path: "file.txt"}}
+	data, err := idx.marshal()
+	if err != nil {
+		t.Fatalf("marshal() failed: %v", err)
+	}
+
+	for _, name := range []string{"bff-1.json", "bff-2.json", "other.json"} {
+		if err := os.WriteFile(filepath.Join(snapshotsDir, name), data, 0644); err != nil {
+			t.Fatalf("failed to write snapshot: %v", err)
+		}
+	}
+
+	snapshots, err := LoadSnapshots(snapshotsDir)
+	if err != nil {
+		t.Fatalf("LoadSnapshots() failed: %v", err)
+	}
+	if len(snapshots) != 2 {
+		t.Errorf("expected 2 snapshots, got %d", len(snapshots))
+	}
+
+	if err := os.WriteFile(filepath.Join(snapshotsDir, "bff-invalid.json"), []byte("not json"), 0644); err != nil {
+		t.Fatalf("failed to write snapshot: %v", err)
+	}
+	if _, err := LoadSnapshots(snapshotsDir); err == nil {
+		t.Error("expected error with an invalid snapshot, got nil")
+	}
+}
+
+func TestFindGroupFirstSeen(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	snapshot := func(updatedAt time.Time, paths ...string) *Index {
+		idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+		idx.UpdatedAt = updatedAt
+		for _, path := range paths {
+			idx.FilesByContentHash["dup"] = append(idx.FilesByContentHash["dup"], &FileInfo{Path: path, Size: 1})
+		}
+		return idx
+	}
+
+	snapshots := []*Index{
+		snapshot(start.Add(3*time.Hour), "a.txt", "b.txt", "c.txt"),
+		snapshot(start, "a.txt"),
+		snapshot(start.Add(2*time.Hour), "a.txt", "b.txt"),
+		snapshot(start.Add(time.Hour), "a.txt"),
+	}
+
+	history := &ChangeHistory{}
+	firstSeen := history.FindGroupFirstSeen("dup", snapshots)
+	if firstSeen == nil || !firstSeen.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("expected the group to be first seen at %v, got %v", start.Add(2*time.Hour), firstSeen)
+	}
+
+	if firstSeen := history.FindGroupFirstSeen("unknown", snapshots); firstSeen != nil {
+		t.Errorf("expected an unknown group not to be seen, got %v", firstSeen)
+	}
+
+	groups := []DuplicateGroup{{Hash: "new"}, {Hash: "recent"}, {Hash: "old"}}
+	old := snapshot(start, "a.txt", "b.txt")
+	old.FilesByContentHash["old"] = old.FilesByContentHash["dup"]
+	recent := snapshot(start.Add(time.Hour), "a.txt", "b.txt")
+	recent.FilesByContentHash["old"] = recent.FilesByContentHash["dup"]
+	recent.FilesByContentHash["recent"] = recent.FilesByContentHash["dup"]
+
+	sortDuplicateGroupsByAge(groups, []*Index{recent, old})
+	for i, hash := range []string{"old", "recent", "new"} {
+		if groups[i].Hash != hash {
+			t.Errorf("group %d: expected %q, got %q", i, hash, groups[i].Hash)
+		}
+	}
+	if !groups[0].FirstSeenAt.Equal(start) || groups[2].FirstSeenAt != nil {
+		t.Errorf("expected the first seen times of the groups to be set, got %v and %v", groups[0].FirstSeenAt, groups[2].FirstSeenAt)
+	}
+}
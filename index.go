@@ -1,11 +1,21 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 const IndexFile = "bff.json"
@@ -14,50 +24,618 @@ const IndexFile = "bff.json"
 type Index struct {
 	FilesByContentHash map[string][]*FileInfo `json:"files_by_content_hash"`
 	AbsPath            string                 `json:"abs_path"`
-	IncludeHidden      bool                   `json:"include_hidden"` // Whether hidden files are included.
+	IncludeHidden      bool                   `json:"include_hidden"`             // Whether hidden files are included.
+	IncludePatterns    []string               `json:"include_patterns,omitempty"` // If not empty, only files matching one of these patterns are included.
+	ExcludePatterns    []string               `json:"exclude_patterns,omitempty"` // Files and directories matching one of these patterns are excluded.
+	MaxDepth           int                    `json:"max_depth,omitempty"`        // Maximum depth of the indexed files (1 for the directory itself), unlimited if 0.
+	MinSize            int64                  `json:"min_size,omitempty"`         // Files smaller than this size are excluded.
+	MaxSize            int64                  `json:"max_size,omitempty"`         // Files larger than this size are excluded, unless it is 0.
+	HashAlgorithm      string                 `json:"hash_algorithm"`             // Algorithm used to compute the content hashes.
+	StoredInXDG        bool                   `json:"stored_in_xdg"`              // Whether the index file is stored in the XDG data directory instead of the indexed one.
+	UseGitignore       bool                   `json:"use_gitignore"`              // Whether the patterns of .gitignore files are excluded.
+	IncludeEmptyDirs   bool                   `json:"include_empty_dirs"`         // Whether empty directories are tracked.
+	IncludePermissions bool                   `json:"include_permissions"`        // Whether the permission bits of the files are tracked.
+	SymlinkMode        string                 `json:"symlink_mode,omitempty"`     // How symbolic links are indexed, DefaultSymlinkMode if empty.
+	EmptyDirs          []string               `json:"empty_dirs,omitempty"`       // Relative paths of the empty directories, if tracked.
+	UpdatedAt          time.Time              `json:"updated_at,omitempty"`       // When the index file was last written.
+	Tag                string                 `json:"tag,omitempty"`              // Label of the index, e.g. the name of the machine.
+	SourceType         string                 `json:"source_type,omitempty"`      // Where the files are, SourceFilesystem or SourceTar (AbsPath is then the archive).
+	AutoSuffix         bool                   `json:"auto_suffix,omitempty"`      // Whether the index file name has a suffix based on AbsPath, see autoIndexSuffix.
+	DirStats           map[string]*DirStats   `json:"dir_stats,omitempty"`        // Statistics by directory, see ComputeDirectoryStats, updated when the index file is written.
+	SkipZeroBytes      bool                   `json:"-"`                          // Whether empty files are excluded from duplicates.
+	DebounceDelay      time.Duration          `json:"-"`                          // How long a path must be stable before Watch processes it.
+	TempDir            string                 `json:"-"`                          // Directory of the temp file used to write the index, if not the index directory.
+	DryRun             bool                   `json:"-"`                          // Whether changes to the indexed files are only reported.
+	RateLimitMBps      float64                `json:"-"`                          // Maximum rate at which files are read for hashing, in MB/s, unlimited if 0.
+	StableCheck        bool                   `json:"-"`                          // Whether Compare hashes the modified files twice to detect concurrent modifications.
+	IgnoreMtime        bool                   `json:"-"`                          // Whether Compare skips the files whose modification time changed but not their content.
+	VerifyAfter        bool                   `json:"-"`                          // Whether the scan hashes each file a second time to detect read errors, see VerifyHash.
+	Benchmark          *HashBenchmark         `json:"-"`                          // Records the time taken to hash each file during the scan, unless nil.
+	RemoteAuth         string                 `json:"-"`                          // "user:password" for the basic authentication of LoadFromHTTP, if any.
+	IgnorePatterns     []string               `json:"-"`                          // Patterns of the .bffignore file, see LoadIgnoreFile.
+	SkipUniqueSizes    bool                   `json:"-"`                          // Whether the scans leave out the files of a size no other file has, see FindDuplicatesFast.
+	Workers            int                    `json:"-"`                          // Number of files hashed in parallel by the scans, see workerCount.
+
+	rate *readRate // Read rate shared by the files hashed at RateLimitMBps, see readRate.
+}
+
+// IndexOptions contains the settings of a new index. Zero values select the defaults.
+type IndexOptions struct {
+	RootPath        string
+	IncludeHidden   bool
+	Workers         int    // The number of CPUs if 0, see workerCount.
+	HashAlgorithm   string // DefaultHashAlgorithm if empty.
+	ExcludePatterns []string
+	MaxDepth        int
+	MinSize         int64
+	MaxSize         int64
 }
 
-// NewIndex initializes a new empty index for the given root path.
+// NewIndex initializes a new empty index for the given root path, with the default settings otherwise.
 func NewIndex(rootPath string, includeHidden bool) *Index {
+	return NewIndexWithOptions(IndexOptions{RootPath: rootPath, IncludeHidden: includeHidden})
+}
+
+// NewIndexWithOptions initializes a new empty index with the given settings.
+func NewIndexWithOptions(opts IndexOptions) *Index {
+	hashAlgorithm := opts.HashAlgorithm
+	if hashAlgorithm == "" {
+		hashAlgorithm = DefaultHashAlgorithm
+	}
+
 	return &Index{
 		FilesByContentHash: make(map[string][]*FileInfo),
-		AbsPath:            rootPath,
-		IncludeHidden:      includeHidden,
+		AbsPath:            opts.RootPath,
+		IncludeHidden:      opts.IncludeHidden,
+		Workers:            opts.Workers,
+		ExcludePatterns:    opts.ExcludePatterns,
+		MaxDepth:           opts.MaxDepth,
+		MinSize:            opts.MinSize,
+		MaxSize:            opts.MaxSize,
+		HashAlgorithm:      hashAlgorithm,
+		SourceType:         SourceFilesystem,
+		DebounceDelay:      DefaultDebounceDelay,
+	}
+}
+
+// Clone returns a deep copy of the index, which can be modified without affecting the original.
+func (idx *Index) Clone() *Index {
+	clone := *idx
+
+	clone.FilesByContentHash = make(map[string][]*FileInfo, len(idx.FilesByContentHash))
+	for hash, files := range idx.FilesByContentHash {
+		clonedFiles := make([]*FileInfo, len(files))
+		for i, file := range files {
+			clonedFile := *file
+			clonedFiles[i] = &clonedFile
+		}
+		clone.FilesByContentHash[hash] = clonedFiles
+	}
+
+	if idx.IncludePatterns != nil {
+		clone.IncludePatterns = append([]string{}, idx.IncludePatterns...)
+	}
+	if idx.ExcludePatterns != nil {
+		clone.ExcludePatterns = append([]string{}, idx.ExcludePatterns...)
+	}
+	if idx.EmptyDirs != nil {
+		clone.EmptyDirs = append([]string{}, idx.EmptyDirs...)
+	}
+	if idx.DirStats != nil {
+		clone.DirStats = make(map[string]*DirStats, len(idx.DirStats))
+		for dir, stats := range idx.DirStats {
+			clonedStats := *stats
+			clone.DirStats[dir] = &clonedStats
+		}
+	}
+
+	return &clone
+}
+
+// FileCount returns the number of files in the index.
+func (idx *Index) FileCount() int {
+	count := 0
+	for _, files := range idx.FilesByContentHash {
+		count += len(files)
+	}
+	return count
+}
+
+// TotalIndexedBytes returns the total size of the indexed files, duplicates included.
+func (idx *Index) TotalIndexedBytes() int64 {
+	var total int64
+	for _, files := range idx.FilesByContentHash {
+		for _, file := range files {
+			total += file.Size
+		}
+	}
+	return total
+}
+
+// WalkFiles calls fn for each indexed file with its content hash, sorted by hash then by path.
+// If fn returns an error, the iteration stops and WalkFiles returns it.
+// The index must be loaded before calling this method.
+func (idx *Index) WalkFiles(fn func(fi *FileInfo, hash string) error) error {
+	hashes := make([]string, 0, len(idx.FilesByContentHash))
+	for hash := range idx.FilesByContentHash {
+		hashes = append(hashes, hash)
 	}
+	sort.Strings(hashes)
+
+	for _, hash := range hashes {
+		files := append([]*FileInfo{}, idx.FilesByContentHash[hash]...)
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].Path < files[j].Path
+		})
+
+		for _, file := range files {
+			if err := fn(file, hash); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// AllPaths returns the relative paths of all the indexed files, sorted.
+// The index must be loaded before calling this method.
+func (idx *Index) AllPaths() []string {
+	paths := make([]string, 0, idx.FileCount())
+	// The callback never fails, neither can the walk.
+	_ = idx.WalkFiles(func(fi *FileInfo, hash string) error {
+		paths = append(paths, fi.Path)
+		return nil
+	})
+
+	sort.Strings(paths)
+	return paths
+}
+
+// FindByPath returns the indexed file at the given relative path and its content hash,
+// or a nil FileInfo if it is not indexed.
+// The index must be loaded before calling this method.
+func (idx *Index) FindByPath(path string) (*FileInfo, string) {
+	for hash, files := range idx.FilesByContentHash {
+		for _, file := range files {
+			if file.Path == path {
+				return file, hash
+			}
+		}
+	}
+
+	return nil, ""
+}
+
+// IndexResult contains information about an indexing run.
+type IndexResult struct {
+	FileCount int
+	BytesRead int64 // Total size of the files that were hashed.
+	Reused    int   // Files whose hash was reused without hashing them, see IndexOnlyChanged and IndexWithHashCache.
+	Duration  time.Duration
+	Errors    []error // Files that could not be processed and were skipped.
 }
 
 // Index scans the directory and saves the index file as a JSON (creates it if it doesn't exist).
 // It also returns the number of indexed files.
+//
+// Deprecated: use IndexWithResult, which also reports the amount of data read and the duration.
 func (idx *Index) Index() (int, error) {
-	indexedFilesCount, err := idx.scan()
+	result, err := idx.IndexWithResult()
+	if err != nil {
+		return 0, err
+	}
+
+	return result.FileCount, nil
+}
+
+// IndexWithResult scans the directory and saves the index file as a JSON (creates it if it doesn't exist).
+// It returns information about the indexing run.
+func (idx *Index) IndexWithResult() (*IndexResult, error) {
+	return idx.IndexWithContext(context.Background())
+}
+
+// IndexWithContext is like IndexWithResult, but stops scanning when the context is cancelled,
+// in which case the index file is not saved and the context error is returned.
+func (idx *Index) IndexWithContext(ctx context.Context) (*IndexResult, error) {
+	return idx.IndexWithProgress(ctx, nil)
+}
+
+// IndexWithProgress is like IndexWithContext, but also reports the progress of the scan to the printer
+// unless it is nil.
+func (idx *Index) IndexWithProgress(ctx context.Context, printer *ProgressPrinter) (*IndexResult, error) {
+	start := time.Now()
+
+	var progress scanProgress
+	if printer != nil {
+		progress = func(fi *FileInfo, hash string, indexed, total int) error {
+			printer.MaybePrint(indexed, total, fi.Path)
+			return nil
+		}
+	}
+
+	result, err := idx.scanWithProgress(ctx, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := idx.save(); err != nil {
+		return nil, err
+	}
+
+	result.Duration = time.Since(start)
+
+	return result, nil
+}
+
+// indexEvent is written by IndexStream for each indexed file.
+type indexEvent struct {
+	Path    string    `json:"path"`
+	Hash    string    `json:"hash"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// indexSummary is written by IndexStream once the index is saved.
+type indexSummary struct {
+	Count      int      `json:"count"`
+	DurationMs int64    `json:"duration_ms"`
+	Errors     []string `json:"errors,omitempty"` // Files that could not be processed.
+}
+
+// IndexStream is like IndexWithContext, but writes each file to w as soon as it is indexed, as one JSON object
+// per line, followed by a summary line {"summary":{"count":N,"duration_ms":M}} once the index file is saved.
+// It returns the number of files indexed.
+func (idx *Index) IndexStream(ctx context.Context, w io.Writer) (int, error) {
+	start := time.Now()
+	encoder := json.NewEncoder(w)
+
+	result, err := idx.scanWithProgress(ctx, func(fi *FileInfo, hash string, indexed, total int) error {
+		if err := encoder.Encode(indexEvent{Path: fi.Path, Hash: hash, Size: fi.Size, ModTime: fi.ModTime}); err != nil {
+			return fmt.Errorf("failed to write event: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
 		return 0, err
 	}
 
+	if err := idx.save(); err != nil {
+		return 0, err
+	}
+
+	summary := indexSummary{Count: result.FileCount, DurationMs: time.Since(start).Milliseconds()}
+	for _, err := range result.Errors {
+		summary.Errors = append(summary.Errors, err.Error())
+	}
+	if err := encoder.Encode(map[string]indexSummary{"summary": summary}); err != nil {
+		return 0, fmt.Errorf("failed to write summary: %w", err)
+	}
+
+	return result.FileCount, nil
+}
+
+// marshal returns the content of the index file.
+func (idx *Index) marshal() ([]byte, error) {
 	data, err := json.MarshalIndent(idx, "", "  ")
 	if err != nil {
-		return 0, fmt.Errorf("failed to marshal index: %w", err)
+		return nil, fmt.Errorf("failed to marshal index: %w", err)
+	}
+	return data, nil
+}
+
+// PrintChecksums scans the directory and writes the hash of each file to w, without saving the index file,
+// in the format of sha256sum: "<hash>  <relative path>", one file per line sorted by path.
+// The files that could not be processed are skipped and reported in the returned error.
+// Scanning stops when the context is cancelled.
+func (idx *Index) PrintChecksums(ctx context.Context, w io.Writer) error {
+	result, err := idx.scan(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range idx.sortedChecksums() {
+		if _, err := fmt.Fprintf(w, "%s  %s\n", c.hash, c.path); err != nil {
+			return fmt.Errorf("failed to write checksum: %w", err)
+		}
+	}
+
+	// Files that could not be processed are reported after the others were written.
+	return errors.Join(result.Errors...)
+}
+
+// Checksum returns a fingerprint of the indexed state of the directory, which only changes if files
+// are added, deleted, renamed or modified. It is the SHA-256 hash of the output of PrintChecksums
+// for the indexed files, so it does not depend on the order of the files in the index.
+// The index must be loaded before calling this method.
+func (idx *Index) Checksum() string {
+	hasher := sha256.New()
+	for _, c := range idx.sortedChecksums() {
+		fmt.Fprintf(hasher, "%s  %s\n", c.hash, c.path)
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// fileChecksum is the content hash of a file, with its relative path using forward slashes.
+type fileChecksum struct {
+	hash string
+	path string
+}
+
+// sortedChecksums returns the checksums of the indexed files, sorted by path.
+func (idx *Index) sortedChecksums() []fileChecksum {
+	checksums := []fileChecksum{}
+	_ = idx.WalkFiles(func(fi *FileInfo, hash string) error {
+		checksums = append(checksums, fileChecksum{hash, filepath.ToSlash(fi.Path)})
+		return nil
+	})
+	sort.Slice(checksums, func(i, j int) bool {
+		return checksums[i].path < checksums[j].path
+	})
+	return checksums
+}
+
+// save writes the index file as a JSON, atomically unless the temp directory is on another device.
+// The time of the update is saved in the index.
+func (idx *Index) save() error {
+	idx.UpdatedAt = time.Now()
+	idx.DirStats = idx.ComputeDirectoryStats()
+
+	data, err := idx.marshal()
+	if err != nil {
+		return err
+	}
+
+	if idx.StoredInXDG {
+		if err := os.MkdirAll(filepath.Dir(idx.indexPath()), 0755); err != nil {
+			return fmt.Errorf("failed to create index directory: %w", err)
+		}
+	}
+
+	return idx.writeVerified(data)
+}
+
+// verifiedNewPath returns the path where the file at path is written by writeVerified before being checked.
+func verifiedNewPath(path string) string {
+	return path + ".new"
+}
+
+// writeVerified writes the index file in two phases: the data is first written next to it with a ".new" suffix,
+// then read back and parsed to check that it was written correctly, and only then renamed to the index file.
+// If the check fails, the new file is deleted and the previous index file is kept as it was.
+// The temp file is created in TempDir if set. Otherwise it is created in the index directory rather than in
+// os.TempDir(), which is usually on another filesystem: renaming it would fail with EXDEV and fall back to copying
+// the whole index a second time, and /tmp is often a small tmpfs that a large index may not fit in.
+func (idx *Index) writeVerified(data []byte) error {
+	indexPath := idx.indexPath()
+	newPath := verifiedNewPath(indexPath)
+
+	var err error
+	if idx.TempDir != "" {
+		err = atomicWriteFileTo(newPath, idx.TempDir, data, 0644)
+	} else {
+		err = writeAtomic(newPath, data, 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	written, err := os.ReadFile(newPath)
+	if err == nil {
+		err = json.Unmarshal(written, &Index{})
+	}
+	if err != nil {
+		os.Remove(newPath)
+		return fmt.Errorf("failed to verify the written index: %w", err)
+	}
+
+	if err := rename(newPath, indexPath); err != nil {
+		os.Remove(newPath)
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	return nil
+}
+
+// writeAtomic writes data to the file at path through a temp file in the same directory, which is only renamed to
+// path once fully written, so that the previous file is kept if the process is killed while writing.
+func writeAtomic(path string, data []byte, perm fs.FileMode) error {
+	return atomicWriteFileTo(path, filepath.Dir(path), data, perm)
+}
+
+// IndexOnlyChanged updates the loaded index by only hashing the files whose size or modification time changed since
+// they were indexed, or that are new, keeping the entries of the other files as they are, then saves the index file.
+// Files whose content changed without a change of size or modification time are not detected.
+// The index must be loaded before calling this method.
+func (idx *Index) IndexOnlyChanged(ctx context.Context) (*IndexResult, error) {
+	start := time.Now()
+
+	previous := make(map[string]*FileInfo)
+	hashes := make(map[string]string)
+	_ = idx.WalkFiles(func(fi *FileInfo, hash string) error {
+		previous[fi.Path] = fi
+		hashes[fi.Path] = hash
+		return nil
+	})
+	cached := func(relPath string, info os.FileInfo) (string, bool) {
+		prev, exists := previous[relPath]
+		if !exists || prev.Size != info.Size() || !prev.ModTime.Equal(info.ModTime()) {
+			return "", false
+		}
+		return hashes[relPath], true
+	}
+
+	saved := idx.FilesByContentHash
+	idx.FilesByContentHash = make(map[string][]*FileInfo)
+	result, err := idx.scanFiles(ctx, nil, cached)
+	if err != nil {
+		idx.FilesByContentHash = saved
+		return nil, err
+	}
+
+	if err := idx.save(); err != nil {
+		return nil, err
+	}
+
+	result.Duration = time.Since(start)
+
+	return result, nil
+}
+
+// IndexWithHashCache is like IndexWithResult, but reuses the hashes of the files of the cache, e.g. the index of the
+// original of a copied directory tree, for the files with the same size and modification time, and only hashes the
+// other ones. It returns the number of files whose hash was reused.
+func (idx *Index) IndexWithHashCache(cache *Index) (int, error) {
+	result, err := idx.IndexWithHashCacheContext(context.Background(), cache)
+	if err != nil {
+		return 0, err
+	}
+	return result.Reused, nil
+}
+
+// IndexWithHashCacheContext is like IndexWithHashCache, but stops scanning when the context is cancelled,
+// and returns the result of the indexing, with the number of reused hashes in Reused.
+// Files of the cache with the same size and modification time but different hashes are not reused.
+func (idx *Index) IndexWithHashCacheContext(ctx context.Context, cache *Index) (*IndexResult, error) {
+	if idx.HashAlgorithm != cache.HashAlgorithm {
+		return nil, fmt.Errorf("cannot reuse the hashes of an index using another hash algorithm (%s instead of %s)", cache.HashAlgorithm, idx.HashAlgorithm)
+	}
+
+	start := time.Now()
+
+	type cacheKey struct {
+		size    int64
+		modTime int64
+	}
+	hashes := make(map[cacheKey]string)
+	_ = cache.WalkFiles(func(fi *FileInfo, hash string) error {
+		key := cacheKey{fi.Size, fi.ModTime.UnixNano()}
+		if cachedHash, exists := hashes[key]; exists && cachedHash != hash {
+			hash = "" // Ambiguous, the file is hashed.
+		}
+		hashes[key] = hash
+		return nil
+	})
+	cached := func(relPath string, info os.FileInfo) (string, bool) {
+		hash := hashes[cacheKey{info.Size(), info.ModTime().UnixNano()}]
+		return hash, hash != ""
+	}
+
+	saved := idx.FilesByContentHash
+	idx.FilesByContentHash = make(map[string][]*FileInfo)
+	result, err := idx.scanFiles(ctx, nil, cached)
+	if err != nil {
+		idx.FilesByContentHash = saved
+		return nil, err
 	}
 
-	if err := os.WriteFile(idx.indexPath(), data, 0644); err != nil {
-		return 0, fmt.Errorf("failed to write index: %w", err)
+	if err := idx.save(); err != nil {
+		return nil, err
 	}
 
-	return indexedFilesCount, nil
+	result.Duration = time.Since(start)
+
+	return result, nil
 }
 
+// cachedHash returns the known hash of the file at the given relative path, if any, so that it is not hashed again.
+type cachedHash func(relPath string, info os.FileInfo) (string, bool)
+
 // scan walks through the directory and indexes all files (including in subdirectories).
-// It also returns the total number of files indexed.
-func (idx *Index) scan() (int, error) {
-	var indexedFilesCount int
+// Files that cannot be processed are skipped and reported in the result errors.
+func (idx *Index) scan(ctx context.Context) (*IndexResult, error) {
+	return idx.scanWithProgress(ctx, nil)
+}
+
+// scanProgress is called for each file once it is indexed, with the number of files indexed so far
+// and the total number of files to index.
+type scanProgress func(fi *FileInfo, hash string, indexed, total int) error
+
+// scanWithProgress is like scan, but also calls progress, unless it is nil, for each file once it is indexed.
+// If progress returns an error, the scan stops and returns it.
+func (idx *Index) scanWithProgress(ctx context.Context, progress scanProgress) (*IndexResult, error) {
+	return idx.scanFiles(ctx, progress, nil)
+}
+
+// SetHashAlgorithm sets the algorithm used to compute the content hashes, one of SupportedHashAlgorithms. It is saved
+// in the index file, so that the other commands use it too. The hashes already in the index are not computed again.
+func (idx *Index) SetHashAlgorithm(algorithm string) error {
+	if _, err := newHasher(algorithm); err != nil {
+		return err
+	}
+	idx.HashAlgorithm = algorithm
+	return nil
+}
+
+// loadGitignore returns the patterns of the .gitignore files of the directory, or none if UseGitignore is not set.
+func (idx *Index) loadGitignore() (*gitignoreParser, error) {
+	gitignore := &gitignoreParser{}
+	if idx.UseGitignore {
+		if err := gitignore.load(idx.AbsPath); err != nil {
+			return nil, fmt.Errorf("failed to load %s files: %w", GitignoreFile, err)
+		}
+	}
+	return gitignore, nil
+}
+
+// workerCount returns the number of files hashed in parallel by the scans: Workers if set, otherwise the number of
+// CPUs, or only one with a rate limit, since the reads of all the workers share it.
+func (idx *Index) workerCount() int {
+	switch {
+	case idx.Workers > 0:
+		return idx.Workers
+	case idx.RateLimitMBps > 0:
+		return 1
+	default:
+		return runtime.NumCPU()
+	}
+}
+
+// scanFiles is like scanWithProgress, but uses the hashes returned by cached, unless it is nil,
+// instead of hashing the files again.
+func (idx *Index) scanFiles(ctx context.Context, progress scanProgress, cached cachedHash) (*IndexResult, error) {
+	if idx.isArchive() {
+		return nil, fmt.Errorf("cannot scan %s: %w", idx.AbsPath, errArchiveIndex)
+	}
+	if _, err := newHasher(idx.HashAlgorithm); err != nil {
+		return nil, err
+	}
+
+	gitignore, err := idx.loadGitignore()
+	if err != nil {
+		return nil, err
+	}
+	if err := idx.LoadIgnoreFile(); err != nil {
+		return nil, err
+	}
+
+	result := &IndexResult{}
+
+	// A directory is empty if none of its children is kept (hidden and ignored ones don't count).
+	dirs := []string{}
+	nonEmptyDirs := make(map[string]bool)
+
+	// The files are listed before being processed, to know how many there are to report the progress.
+	type pendingFile struct {
+		path, relPath string
+		info          os.FileInfo
+	}
+	pending := []pendingFile{}
+
+	// Directories already visited, by inode, when following symlinks, to not loop on symlinks to a parent directory.
+	visitedDirs := make(map[uint64]bool)
 
-	err := filepath.Walk(idx.AbsPath, func(path string, info os.FileInfo, err error) error {
+	var visit filepath.WalkFunc
+	visit = func(path string, info os.FileInfo, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if err != nil {
 			return fmt.Errorf("walk error at %s: %w", path, err)
 		}
 
 		// Ignore the index file voluntarily.
-		if path == idx.indexPath() {
+		if idx.isIndexFile(path) {
 			return nil
 		}
 
@@ -68,100 +646,915 @@ func (idx *Index) scan() (int, error) {
 			return nil
 		}
 
-		if info.IsDir() {
-			return nil
-		}
-
 		relPath, err := filepath.Rel(idx.AbsPath, path)
 		if err != nil {
 			return fmt.Errorf("failed to get relative path for %s: %w", path, err)
 		}
 
-		hash, fileInfo, err := ProcessFile(path, relPath)
-		if err != nil {
-			return fmt.Errorf("failed to process %s: %w", path, err)
+		// Directories beyond the maximum depth could only contain files that are too deep.
+		tooDeep := info.IsDir() && idx.MaxDepth > 0 && pathDepth(relPath) >= idx.MaxDepth
+		if path != idx.AbsPath && (gitignore.matches(relPath, info.IsDir()) || idx.isIgnored(relPath) || idx.isExcluded(relPath) || tooDeep) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			switch idx.SymlinkMode {
+			case SymlinkSkip:
+				return nil
+			case SymlinkFollow:
+				target, err := os.Stat(path)
+				if err != nil {
+					result.Errors = append(result.Errors, fmt.Errorf("failed to follow symlink %s: %w", path, err))
+					return nil
+				}
+				if target.IsDir() {
+					if _, ok := fileID(path, target); !ok {
+						return nil // Loops could not be detected.
+					}
+					// With a trailing separator, the walk starts from the target directory.
+					return filepath.Walk(path+string(filepath.Separator), visit)
+				}
+				info = target
+			}
+		}
+
+		if info.IsDir() && idx.SymlinkMode == SymlinkFollow {
+			if id, ok := fileID(path, info); ok {
+				if visitedDirs[id] {
+					return filepath.SkipDir
+				}
+				visitedDirs[id] = true
+			}
+		}
+
+		if path != idx.AbsPath {
+			nonEmptyDirs[filepath.Dir(relPath)] = true
 		}
 
-		idx.FilesByContentHash[hash] = append(idx.FilesByContentHash[hash], fileInfo)
+		if info.IsDir() {
+			if path != idx.AbsPath {
+				dirs = append(dirs, relPath)
+			}
+			return nil
+		}
 
-		indexedFilesCount++
+		if !idx.isSelected(relPath, info) {
+			return nil
+		}
 
+		pending = append(pending, pendingFile{path: path, relPath: relPath, info: info})
 		return nil
-	})
+	}
+	err = filepath.Walk(idx.AbsPath, visit)
 
 	if err != nil {
-		return 0, fmt.Errorf("scan failed: %w", err)
+		return nil, fmt.Errorf("scan failed: %w", err)
+	}
+
+	// Files of different sizes cannot have the same content, so the files of a unique size are not hashed.
+	if idx.SkipUniqueSizes {
+		sizes := make(map[int64]int)
+		for _, file := range pending {
+			sizes[file.info.Size()]++
+		}
+		sameSize := pending[:0]
+		for _, file := range pending {
+			if sizes[file.info.Size()] > 1 {
+				sameSize = append(sameSize, file)
+			}
+		}
+		pending = sameSize
+	}
+
+	// The files are hashed by a pool of workers, and their results are added to the index in the order of the walk,
+	// by this goroutine only, so that the index and the progress are the same as when hashing them one by one.
+	type hashedFile struct {
+		hash      string
+		fileInfo  *FileInfo
+		reused    bool
+		duration  time.Duration
+		err       error
+		verifyErr error
+	}
+	hashed := make([]hashedFile, len(pending))
+	done := make(chan int, len(pending)) // Never blocks, even if the results are no longer read.
+
+	workerCtx, cancel := context.WithCancel(ctx)
+	var workers sync.WaitGroup
+	defer func() {
+		cancel()
+		workers.Wait()
+	}()
+
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i, file := range pending {
+			if cached != nil {
+				if hash, reused := cached(file.relPath, file.info); reused {
+					hashed[i] = hashedFile{hash: hash, reused: true}
+					done <- i
+					continue
+				}
+			}
+			select {
+			case jobs <- i:
+			case <-workerCtx.Done():
+				return
+			}
+		}
+	}()
+
+	idx.readRate() // Shared by the workers, so created before them.
+	for w := 0; w < idx.workerCount(); w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range jobs {
+				if workerCtx.Err() != nil {
+					continue
+				}
+
+				file := pending[i]
+				start := time.Now()
+				hash, fileInfo, err := idx.processFile(longPath(file.path), file.relPath)
+				h := hashedFile{hash: hash, fileInfo: fileInfo, duration: time.Since(start), err: err}
+				if err == nil && idx.VerifyAfter {
+					var verified bool
+					verified, h.verifyErr = idx.VerifyHash(longPath(file.path), hash)
+					fileInfo.SuspectHash = !verified
+				}
+				hashed[i] = h
+				done <- i
+			}
+		}()
+	}
+
+	finished := make([]bool, len(pending))
+	for next := 0; next < len(pending); {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("scan failed: %w", ctx.Err())
+		case i := <-done:
+			finished[i] = true
+		}
+
+		for ; next < len(pending) && finished[next]; next++ {
+			if err := ctx.Err(); err != nil {
+				return nil, fmt.Errorf("scan failed: %w", err)
+			}
+
+			file, h := pending[next], hashed[next]
+			hash, fileInfo := h.hash, h.fileInfo
+			if h.reused {
+				fileInfo = &FileInfo{Path: file.relPath, Size: file.info.Size(), ModTime: file.info.ModTime()}
+				if idx.IncludePermissions {
+					fileInfo.Mode = file.info.Mode().Perm()
+				}
+				result.Reused++
+			} else {
+				if h.err != nil {
+					result.Errors = append(result.Errors, fmt.Errorf("failed to process %s: %w", file.path, h.err))
+					continue
+				}
+				result.BytesRead += fileInfo.Size
+				if idx.Benchmark != nil {
+					idx.Benchmark.Record(file.relPath, h.duration, fileInfo.Size)
+				}
+				if h.verifyErr != nil {
+					result.Errors = append(result.Errors, fmt.Errorf("failed to verify %s: %w", file.path, h.verifyErr))
+				}
+			}
+
+			idx.FilesByContentHash[hash] = append(idx.FilesByContentHash[hash], fileInfo)
+
+			result.FileCount++
+
+			if progress != nil {
+				if err := progress(fileInfo, hash, next+1, len(pending)); err != nil {
+					return nil, fmt.Errorf("scan failed: %w", err)
+				}
+			}
+		}
+	}
+
+	idx.EmptyDirs = nil
+	if idx.IncludeEmptyDirs {
+		idx.EmptyDirs = []string{}
+		for _, dir := range dirs {
+			if !nonEmptyDirs[dir] {
+				idx.EmptyDirs = append(idx.EmptyDirs, dir)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// isSelected returns true if the file at the given relative path is to be indexed according to the
+// include and exclude patterns, the maximum depth and the size limits. Hidden and ignored files are not checked.
+func (idx *Index) isSelected(relPath string, info os.FileInfo) bool {
+	if !idx.isIncluded(relPath) || idx.isExcluded(relPath) {
+		return false
+	}
+	if idx.MaxDepth > 0 && pathDepth(relPath) > idx.MaxDepth {
+		return false
+	}
+	if info.Size() < idx.MinSize || (idx.MaxSize > 0 && info.Size() > idx.MaxSize) {
+		return false
+	}
+	return true
+}
+
+// isIncluded returns true if the file at the given relative path matches the include patterns, if any.
+func (idx *Index) isIncluded(relPath string) bool {
+	return len(idx.IncludePatterns) == 0 || matchesAnyPattern(idx.IncludePatterns, relPath)
+}
+
+// isExcluded returns true if the file or directory at the given relative path, or one of its parent directories,
+// matches one of the exclude patterns.
+func (idx *Index) isExcluded(relPath string) bool {
+	if len(idx.ExcludePatterns) == 0 {
+		return false
+	}
+
+	for path := relPath; path != "." && path != string(filepath.Separator); path = filepath.Dir(path) {
+		if matchesAnyPattern(idx.ExcludePatterns, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAnyPattern returns true if the relative path matches one of the glob patterns.
+// A pattern without path separator is matched against the file name, otherwise against the whole relative path.
+func matchesAnyPattern(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		name := relPath
+		if !strings.ContainsRune(pattern, filepath.Separator) {
+			name = filepath.Base(relPath)
+		}
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pathDepth returns the number of elements of a relative path, e.g. 1 for a file of the indexed directory.
+func pathDepth(relPath string) int {
+	return strings.Count(relPath, string(filepath.Separator)) + 1
+}
+
+// indexPath returns the full path to the index file.
+func (idx *Index) indexPath() string {
+	if idx.StoredInXDG {
+		return idx.xdgIndexPath()
+	}
+	if idx.isArchive() {
+		return idx.AbsPath + ArchiveIndexSuffix
+	}
+	if idx.AutoSuffix {
+		return idx.autoSuffixIndexPath()
+	}
+	return filepath.Join(idx.AbsPath, IndexFile)
+}
+
+// autoSuffixIndexPath returns the path to the index file named after the indexed directory path, bff-<suffix>.json.
+func (idx *Index) autoSuffixIndexPath() string {
+	return filepath.Join(idx.AbsPath, "bff-"+autoIndexSuffix(idx.AbsPath)+".json")
+}
+
+// autoIndexSuffix returns a short suffix identifying an indexed directory path, the first 8 hex characters of its SHA-256,
+// so that the indexes of several directories can be stored in the same place without colliding.
+func autoIndexSuffix(absPath string) string {
+	sum := sha256.Sum256([]byte(absPath))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// isIndexFile returns true if path is the index file or one of the temp files used to write it.
+func (idx *Index) isIndexFile(path string) bool {
+	newPath := verifiedNewPath(idx.indexPath())
+	return path == idx.indexPath() || isTempFileOf(path, idx.indexPath()) || path == newPath || isTempFileOf(path, newPath)
+}
+
+// xdgIndexPath returns the path to the index file in the XDG data directory ($XDG_DATA_HOME/bff,
+// or $HOME/.local/share/bff as a fallback), named after the escaped indexed directory path.
+func (idx *Index) xdgIndexPath() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = os.TempDir()
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+
+	escapedPath := strings.ReplaceAll(filepath.ToSlash(idx.AbsPath), "/", "_")
+
+	return filepath.Join(dataHome, "bff", escapedPath+".json")
+}
+
+// errIndexNotFound is returned when loading an index that does not exist.
+var errIndexNotFound = errors.New("index not found")
+
+// Load loads an existing index from the JSON file into the current Index struct.
+// If the index file is not found in the indexed directory, it is looked up with an automatic suffix (see AutoSuffix),
+// then in the XDG data directory.
+// If paths are indexed several times, the index is loaded but an error wrapping errDuplicatePaths is returned.
+// An index using a hash algorithm that is not supported is not loaded.
+func (idx *Index) Load() error {
+	indexPath, err := idx.existingIndexPath()
+	if err != nil {
+		return err
+	}
+
+	if err := idx.loadFile(indexPath); err != nil {
+		return err
+	}
+
+	if duplicates := idx.DetectPathDuplicates(); len(duplicates) > 0 {
+		return fmt.Errorf("%w: %v", errDuplicatePaths, duplicates[0])
+	}
+
+	return nil
+}
+
+// LoadFrom is like Load, but loads the index file at the given path instead, e.g. a snapshot of the index of the
+// directory. AbsPath is kept, so that the directory is compared with the loaded files even if the index file was
+// written for another one, e.g. a copy of the directory.
+func (idx *Index) LoadFrom(indexPath string) error {
+	absPath := idx.AbsPath
+	if err := idx.loadFile(indexPath); err != nil {
+		return err
+	}
+	idx.AbsPath = absPath
+
+	if duplicates := idx.DetectPathDuplicates(); len(duplicates) > 0 {
+		return fmt.Errorf("%w: %v", errDuplicatePaths, duplicates[0])
+	}
+
+	return nil
+}
+
+// existingIndexPath returns the path of the index file loaded by Load, or an error wrapping errIndexNotFound.
+func (idx *Index) existingIndexPath() (string, error) {
+	indexPath := idx.indexPath()
+	if _, err := os.Stat(indexPath); err == nil || !os.IsNotExist(err) {
+		return indexPath, nil
+	}
+	if idx.StoredInXDG {
+		return "", fmt.Errorf("%w at %s", errIndexNotFound, indexPath)
+	}
+
+	for _, fallbackPath := range []string{idx.autoSuffixIndexPath(), idx.xdgIndexPath()} {
+		if _, err := os.Stat(fallbackPath); err == nil {
+			return fallbackPath, nil
+		}
+	}
+	return "", fmt.Errorf("%w at %s", errIndexNotFound, indexPath)
+}
+
+// IsFresh tells whether the index file was written less than maxAge ago, e.g. to skip indexing again in a cron job.
+// Only the metadata of the index file is decoded, and UpdatedAt is set from it; the files of the index are left unchanged.
+// An index file written before UpdatedAt was recorded is never fresh.
+func (idx *Index) IsFresh(maxAge time.Duration) (bool, error) {
+	indexPath, err := idx.existingIndexPath()
+	if err != nil {
+		return false, err
+	}
+
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read index: %w", err)
+	}
+	var metadata struct {
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return false, fmt.Errorf("failed to parse index: %w", err)
+	}
+
+	idx.UpdatedAt = metadata.UpdatedAt
+	if metadata.UpdatedAt.IsZero() {
+		return false, nil
+	}
+	return time.Since(metadata.UpdatedAt) < maxAge, nil
+}
+
+// LoadWithParents is like Load, but if the directory has no index, it looks for the index of one of its parent
+// directories, up to maxLevels levels above (unlimited if 0), and returns it restricted to the directory with SubIndex.
+// The settings of the index are kept in the returned one.
+func (idx *Index) LoadWithParents(maxLevels int) (*Index, error) {
+	err := idx.Load()
+	if !errors.Is(err, errIndexNotFound) {
+		return idx, err
+	}
+
+	parentIndexPath, parentErr := idx.findIndexInParents(maxLevels)
+	if parentErr != nil {
+		return nil, fmt.Errorf("%w, %v", err, parentErr)
+	}
+
+	parent := idx.Clone()
+	parent.AbsPath = filepath.Dir(parentIndexPath)
+	parent.FilesByContentHash = make(map[string][]*FileInfo)
+	parent.StoredInXDG = false
+	parent.AutoSuffix = filepath.Base(parentIndexPath) != IndexFile
+	if err := parent.Load(); err != nil {
+		return nil, err
+	}
+
+	subdir, err := filepath.Rel(parent.AbsPath, idx.AbsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the path of %s in %s: %w", idx.AbsPath, parent.AbsPath, err)
+	}
+	return parent.SubIndex(subdir), nil
+}
+
+// findIndexInParents returns the path to the index file of the closest parent directory having one in it,
+// up to maxLevels levels above the indexed directory (unlimited if 0).
+func (idx *Index) findIndexInParents(maxLevels int) (string, error) {
+	dir := idx.AbsPath
+	for level := 1; maxLevels == 0 || level <= maxLevels; level++ {
+		parentDir := filepath.Dir(dir)
+		if parentDir == dir {
+			break
+		}
+		dir = parentDir
+
+		for _, name := range []string{IndexFile, "bff-" + autoIndexSuffix(dir) + ".json"} {
+			indexPath := filepath.Join(dir, name)
+			if _, err := os.Stat(indexPath); err == nil {
+				return indexPath, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("no index found in the parent directories of %s", idx.AbsPath)
+}
+
+// SubIndex returns a copy of the index restricted to the files of a subdirectory, given relative to the indexed directory,
+// which becomes the indexed directory of the copy, with the paths relative to it.
+// The index must be loaded before calling this method.
+func (idx *Index) SubIndex(subdir string) *Index {
+	subdir = filepath.Clean(subdir)
+
+	sub := idx.FilteredView(func(fi *FileInfo, hash string) bool {
+		_, ok := pathUnderDir(fi.Path, subdir)
+		return ok
+	}).Clone()
+	sub.AbsPath = filepath.Join(idx.AbsPath, subdir)
+	sub.StoredInXDG = false
+	sub.AutoSuffix = false
+
+	for _, files := range sub.FilesByContentHash {
+		for _, file := range files {
+			file.Path, _ = pathUnderDir(file.Path, subdir)
+		}
+	}
+
+	sub.EmptyDirs = nil
+	for _, dir := range idx.EmptyDirs {
+		if relPath, ok := pathUnderDir(dir, subdir); ok {
+			sub.EmptyDirs = append(sub.EmptyDirs, relPath)
+		}
+	}
+
+	if idx.DirStats != nil {
+		sub.DirStats = sub.ComputeDirectoryStats()
+	}
+
+	return sub
+}
+
+// Rebase updates the index after a directory was moved, without indexing it again. If oldRoot is the indexed directory,
+// newRoot becomes the indexed directory. Otherwise oldRoot and newRoot must be in the indexed directory, and the paths
+// of the files and empty directories of oldRoot are moved to newRoot. The paths can be absolute or relative to the
+// indexed directory. The index file is not saved.
+// The index must be loaded before calling this method.
+func (idx *Index) Rebase(oldRoot, newRoot string) error {
+	absPath := func(path string) string {
+		if filepath.IsAbs(path) {
+			return filepath.Clean(path)
+		}
+		return filepath.Join(idx.AbsPath, path)
+	}
+	oldAbsPath, newAbsPath := absPath(oldRoot), absPath(newRoot)
+
+	if oldAbsPath == filepath.Clean(idx.AbsPath) {
+		idx.AbsPath = newAbsPath
+		return nil
+	}
+
+	relOldRoot, err := idx.relPathInRoot(oldAbsPath)
+	if err != nil {
+		return err
+	}
+	relNewRoot, err := idx.relPathInRoot(newAbsPath)
+	if err != nil {
+		return err
+	}
+
+	rebased := func(path string) (string, bool) {
+		relPath, ok := pathUnderDir(path, relOldRoot)
+		if !ok {
+			return path, false
+		}
+		return filepath.Join(relNewRoot, relPath), true
+	}
+
+	// Moved files must not take the place of files that did not move.
+	kept := make(map[string]bool)
+	for _, path := range idx.AllPaths() {
+		if _, moved := rebased(path); !moved {
+			kept[path] = true
+		}
+	}
+	for _, path := range idx.AllPaths() {
+		if newPath, moved := rebased(path); moved && kept[newPath] {
+			return fmt.Errorf("cannot rebase %s to %s: %s is already indexed", relOldRoot, relNewRoot, newPath)
+		}
+	}
+
+	for _, files := range idx.FilesByContentHash {
+		for _, file := range files {
+			file.Path, _ = rebased(file.Path)
+		}
+	}
+	for i, dir := range idx.EmptyDirs {
+		idx.EmptyDirs[i], _ = rebased(dir)
+	}
+
+	return nil
+}
+
+// relPathInRoot returns the path relative to the indexed directory of an absolute path strictly inside of it.
+func (idx *Index) relPathInRoot(absPath string) (string, error) {
+	relPath, err := filepath.Rel(idx.AbsPath, absPath)
+	if err != nil || relPath == "." || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not a subdirectory of %s", absPath, idx.AbsPath)
+	}
+	return relPath, nil
+}
+
+// ExtractSubtree saves to destIndexPath an index of the files of a subdirectory, given relative to the indexed
+// directory, without scanning it again. The subdirectory becomes the indexed directory of the new index.
+// The index must be loaded before calling this method.
+func (idx *Index) ExtractSubtree(subdir, destIndexPath string) error {
+	subdir = filepath.Clean(subdir)
+	if filepath.IsAbs(subdir) || subdir == "." || subdir == ".." || strings.HasPrefix(subdir, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is not a subdirectory of %s", subdir, idx.AbsPath)
+	}
+
+	data, err := idx.SubIndex(subdir).marshal()
+	if err != nil {
+		return err
+	}
+	if err := writeAtomic(destIndexPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	return nil
+}
+
+// pathUnderDir returns the path relative to dir of a relative path located under dir, and whether it is.
+func pathUnderDir(path string, dir string) (string, bool) {
+	if !isUnderDir(path, dir) {
+		return "", false
+	}
+	if dir == "." {
+		return path, true
+	}
+	return strings.TrimPrefix(filepath.Clean(path), dir+string(filepath.Separator)), true
+}
+
+// loadFile loads the index file at the given path into the current Index struct.
+func (idx *Index) loadFile(indexPath string) error {
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+
+	if err := json.Unmarshal(data, idx); err != nil {
+		return fmt.Errorf("failed to parse index: %w", err)
+	}
+	// The files would all be hashed again with another algorithm, and reported as modified.
+	if _, err := newHasher(idx.HashAlgorithm); err != nil {
+		return fmt.Errorf("failed to load index: %w", err)
+	}
+
+	return nil
+}
+
+// DiffIndexFiles compares two saved index files, without accessing the indexed directories.
+// The relative paths are compared as-is, even if the indexes were created for different directories.
+func DiffIndexFiles(pathA, pathB string) (*Comparison, error) {
+	idxA := NewIndex("", false)
+	if err := idxA.loadFile(pathA); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", pathA, err)
+	}
+
+	idxB := NewIndex("", false)
+	if err := idxB.loadFile(pathB); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", pathB, err)
+	}
+
+	return idxA.Diff(idxB), nil
+}
+
+// Compare compares the loaded index with the current state of the directory.
+// The index is left unchanged.
+// The index must be loaded before calling this method.
+func (idx *Index) Compare() (*Comparison, error) {
+	return idx.CompareWithContext(context.Background())
+}
+
+// CompareWithContext is like Compare, but stops scanning when the context is cancelled.
+// The index must be loaded before calling this method.
+func (idx *Index) CompareWithContext(ctx context.Context) (*Comparison, error) {
+	result := &Comparison{
+		Added:          []string{},
+		Modified:       []string{},
+		Deleted:        []string{},
+		RenamedOrMoved: []RenamedOrMovedFile{},
+		Truncated:      []TruncatedFile{},
+		AddedDirs:      []string{},
+		DeletedDirs:    []string{},
+		Unstable:       []string{},
+		Consistency:    true,
+		IndexAbsPath:   idx.AbsPath,
+	}
+
+	current, err := idx.compare(ctx, func(event ChangeEvent) error {
+		result.addEvent(event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if idx.StableCheck {
+		if err := current.checkStability(ctx, result); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// processFile hashes a file with the settings of the index, and returns its hash and FileInfo, with its permission
+// bits only if they are tracked.
+func (idx *Index) processFile(absPath string, relPath string) (string, *FileInfo, error) {
+	return processFileWithRateLimit(absPath, relPath, idx.HashAlgorithm, idx.readRate(), idx.IncludePermissions)
+}
+
+// readRate returns the read rate shared by all the files hashed with the settings of the index, so that RateLimitMBps
+// applies to all of them together even when they are hashed in parallel, or nil if the rate is unlimited.
+// It must be called once before hashing files in parallel, so that the workers don't create it concurrently.
+func (idx *Index) readRate() *readRate {
+	if idx.RateLimitMBps <= 0 {
+		return nil
+	}
+	if idx.rate == nil || idx.rate.mbps != idx.RateLimitMBps {
+		idx.rate = newReadRate(idx.RateLimitMBps)
+	}
+	return idx.rate
+}
+
+// VerifyHash hashes the file at absPath again and returns true if its hash is the expected one, e.g. to detect
+// read errors that went unnoticed while indexing it.
+func (idx *Index) VerifyHash(absPath, expectedHash string) (bool, error) {
+	hash, err := hashFile(absPath, idx.HashAlgorithm, idx.readRate())
+	if err != nil {
+		return false, err
+	}
+	return hash == expectedHash, nil
+}
+
+// SuspectFiles returns the indexed files whose hash was different when they were read again after being hashed,
+// sorted by path. They are only detected when indexing with VerifyAfter.
+// The index must be loaded before calling this method.
+func (idx *Index) SuspectFiles() []*FileInfo {
+	return idx.filterFiles(func(fi *FileInfo) bool {
+		return fi.SuspectHash
+	})
+}
+
+// checkStability hashes the modified files of the comparison a second time, and moves the ones whose hash
+// changed since they were scanned, i.e. files being written during the scan, to the unstable files.
+// The index must be the one scanned for the comparison.
+func (idx *Index) checkStability(ctx context.Context, c *Comparison) error {
+	modified := c.Modified[:0]
+	for _, path := range c.Modified {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		scannedHash, _ := idx.hashOf(path)
+		hash, _, err := idx.processFile(longPath(filepath.Join(idx.AbsPath, path)), path)
+		if err != nil || hash != scannedHash {
+			c.Unstable = append(c.Unstable, path)
+			continue
+		}
+		modified = append(modified, path)
+	}
+
+	c.Modified = modified
+	c.Consistency = len(c.Unstable) == 0
+	return nil
+}
+
+// Diff compares the index with another state of the directory given by the other index,
+// considered as the newer state.
+func (idx *Index) Diff(other *Index) *Comparison {
+	result := &Comparison{
+		Added:          []string{},
+		Modified:       []string{},
+		Deleted:        []string{},
+		RenamedOrMoved: []RenamedOrMovedFile{},
+		Truncated:      []TruncatedFile{},
+		AddedDirs:      []string{},
+		DeletedDirs:    []string{},
+		Unstable:       []string{},
+		Consistency:    true,
+		IndexAbsPath:   other.AbsPath,
+	}
+
+	addEvent := func(event ChangeEvent) error {
+		result.addEvent(event)
+		return nil
+	}
+	// The callback never fails, neither can the diffs.
+	_ = diffFilesByContentHash(idx.FilesByContentHash, other.FilesByContentHash, addEvent)
+	_ = diffEmptyDirs(idx.EmptyDirs, other.EmptyDirs, addEvent)
+
+	return result
+}
+
+// Intersection returns a new index containing only the files of the index whose content
+// is also present in the other index, for example to know which files are backed up.
+// The paths in the result are those of the index.
+func (idx *Index) Intersection(other *Index) *Index {
+	return idx.filterByHash(func(hash string) bool {
+		_, ok := other.FilesByContentHash[hash]
+		return ok
+	})
+}
+
+// Difference returns a new index containing only the files of the index whose content
+// is not present in the other index.
+func (idx *Index) Difference(other *Index) *Index {
+	return idx.filterByHash(func(hash string) bool {
+		_, ok := other.FilesByContentHash[hash]
+		return !ok
+	})
+}
+
+// filterByHash returns a copy of the index keeping only the files whose hash satisfies keep.
+func (idx *Index) filterByHash(keep func(hash string) bool) *Index {
+	result := idx.Clone()
+	for hash := range result.FilesByContentHash {
+		if !keep(hash) {
+			delete(result.FilesByContentHash, hash)
+		}
+	}
+	return result
+}
+
+// CompareStream compares the loaded index with the current state of the directory, like Compare,
+// but writes each change to w as soon as it is detected, as one JSON object per line. Files whose modification time
+// changed but not their content are not written.
+// The index must be loaded before calling this method.
+func (idx *Index) CompareStream(ctx context.Context, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	return indexedFilesCount, nil
-}
+	encoder := json.NewEncoder(w)
 
-// indexPath returns the full path to the index file.
-func (idx *Index) indexPath() string {
-	return filepath.Join(idx.AbsPath, IndexFile)
+	_, err := idx.compare(ctx, func(event ChangeEvent) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if event.Type == ChangeMtimeOnly {
+			return nil
+		}
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("failed to write event: %w", err)
+		}
+		return nil
+	})
+	return err
 }
 
-// Load loads an existing index from the JSON file into the current Index struct.
-func (idx *Index) Load() error {
-	indexPath := idx.indexPath()
-
-	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
-		return fmt.Errorf("index not found at %s", indexPath)
+// compare rescans the directory and calls emit for each difference with the loaded index.
+// It returns the index of the current state of the directory; the index itself is left unchanged.
+func (idx *Index) compare(ctx context.Context, emit func(ChangeEvent) error) (*Index, error) {
+	current := idx.Clone()
+	current.FilesByContentHash = make(map[string][]*FileInfo)
+	if _, err := current.scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to rescan current directory: %w", err)
 	}
 
-	data, err := os.ReadFile(indexPath)
-	if err != nil {
-		return fmt.Errorf("failed to read index: %w", err)
+	if idx.IgnoreMtime {
+		emitChange := emit
+		emit = func(event ChangeEvent) error {
+			if event.Type == ChangeMtimeOnly {
+				return nil
+			}
+			return emitChange(event)
+		}
 	}
 
-	if err := json.Unmarshal(data, idx); err != nil {
-		return fmt.Errorf("failed to parse index: %w", err)
+	if err := diffFilesByContentHash(idx.FilesByContentHash, current.FilesByContentHash, emit); err != nil {
+		return nil, err
 	}
 
-	return nil
+	if err := diffEmptyDirs(idx.EmptyDirs, current.EmptyDirs, emit); err != nil {
+		return nil, err
+	}
+	return current, nil
 }
 
-// Compare compares the loaded index with the current state of the directory.
-// The index must be loaded before calling this method.
-func (idx *Index) Compare() (*Comparison, error) {
-	savedIndex := Index{
-		FilesByContentHash: idx.FilesByContentHash,
+// diffEmptyDirs calls emit for each empty directory that was added or deleted between two states of a directory.
+func diffEmptyDirs(saved, current []string, emit func(ChangeEvent) error) error {
+	savedDirs := make(map[string]bool)
+	for _, dir := range saved {
+		savedDirs[dir] = true
 	}
 
-	idx.FilesByContentHash = make(map[string][]*FileInfo)
-	if _, err := idx.scan(); err != nil {
-		return nil, fmt.Errorf("failed to rescan current directory: %w", err)
+	currentDirs := make(map[string]bool)
+	for _, dir := range current {
+		currentDirs[dir] = true
+		if !savedDirs[dir] {
+			if err := emit(ChangeEvent{Type: ChangeAddedDir, Path: dir}); err != nil {
+				return err
+			}
+		}
 	}
 
-	result := &Comparison{
-		Added:          []string{},
-		Modified:       []string{},
-		Deleted:        []string{},
-		RenamedOrMoved: []RenamedOrMovedFile{},
+	for _, dir := range saved {
+		if !currentDirs[dir] {
+			if err := emit(ChangeEvent{Type: ChangeDeletedDir, Path: dir}); err != nil {
+				return err
+			}
+		}
 	}
 
+	return nil
+}
+
+// diffFilesByContentHash calls emit for each difference between two states of the files of a directory.
+func diffFilesByContentHash(saved, current map[string][]*FileInfo, emit func(ChangeEvent) error) error {
 	savedHashByPath := make(map[string]string)
-	for hash, files := range savedIndex.FilesByContentHash {
+	savedFileByPath := make(map[string]*FileInfo)
+	for hash, files := range saved {
 		for _, file := range files {
 			savedHashByPath[file.Path] = hash
+			savedFileByPath[file.Path] = file
 		}
 	}
 
 	currentHashByPath := make(map[string]string)
-	for hash, files := range idx.FilesByContentHash {
+	currentFileByPath := make(map[string]*FileInfo)
+	for hash, files := range current {
 		for _, file := range files {
 			currentHashByPath[file.Path] = hash
+			currentFileByPath[file.Path] = file
 		}
 	}
 
 	processedCurrent := make(map[string]bool)
 	processedSaved := make(map[string]bool)
 
-	// Check for modified files (same path, different hashes).
+	// Check for modified files (same path, different hashes), reporting the ones that were emptied as truncated.
 	for path, currentHash := range currentHashByPath {
 		if savedHash, exists := savedHashByPath[path]; exists {
 			if currentHash != savedHash {
-				result.Modified = append(result.Modified, path)
+				event := ChangeEvent{Type: ChangeModified, Path: path}
+				if savedSize := savedFileByPath[path].Size; savedSize > 0 && currentFileByPath[path].Size == 0 {
+					event = ChangeEvent{Type: ChangeTruncated, Path: path, OldSize: savedSize}
+				}
+				if err := emit(event); err != nil {
+					return err
+				}
+			} else {
+				savedFile, currentFile := savedFileByPath[path], currentFileByPath[path]
+				if oldMode, newMode := savedFile.Mode, currentFile.Mode; oldMode != 0 && newMode != 0 && oldMode != newMode {
+					if err := emit(ChangeEvent{Type: ChangePermissions, Path: path, OldMode: oldMode, NewMode: newMode}); err != nil {
+						return err
+					}
+				}
+				if !savedFile.ModTime.IsZero() && !currentFile.ModTime.IsZero() && !savedFile.ModTime.Equal(currentFile.ModTime) {
+					if err := emit(ChangeEvent{Type: ChangeMtimeOnly, Path: path}); err != nil {
+						return err
+					}
+				}
 			}
 			processedCurrent[path] = true
 			processedSaved[path] = true
@@ -173,16 +1566,15 @@ func (idx *Index) Compare() (*Comparison, error) {
 		if processedCurrent[currentPath] {
 			continue
 		}
-		if savedFiles, exists := savedIndex.FilesByContentHash[currentHash]; exists {
+		if savedFiles, exists := saved[currentHash]; exists {
 			for _, savedFile := range savedFiles {
 				if processedSaved[savedFile.Path] {
 					continue
 				}
 
-				result.RenamedOrMoved = append(result.RenamedOrMoved, RenamedOrMovedFile{
-					OldPath: savedFile.Path,
-					NewPath: currentPath,
-				})
+				if err := emit(ChangeEvent{Type: ChangeRenamedOrMoved, Path: currentPath, OldPath: savedFile.Path}); err != nil {
+					return err
+				}
 				processedCurrent[currentPath] = true
 				processedSaved[savedFile.Path] = true
 				break
@@ -193,27 +1585,78 @@ func (idx *Index) Compare() (*Comparison, error) {
 	// Remaining current files are added.
 	for path := range currentHashByPath {
 		if !processedCurrent[path] {
-			result.Added = append(result.Added, path)
+			if err := emit(ChangeEvent{Type: ChangeAdded, Path: path}); err != nil {
+				return err
+			}
 		}
 	}
 
 	// Remaining saved files are deleted.
 	for path := range savedHashByPath {
 		if !processedSaved[path] {
-			result.Deleted = append(result.Deleted, path)
+			if err := emit(ChangeEvent{Type: ChangeDeleted, Path: path}); err != nil {
+				return err
+			}
 		}
 	}
 
-	return result, nil
+	return nil
 }
 
-// FindAllDuplicates returns a map of content hashes to lists of FileInfo for files that have duplicate content.
+// FindAllDuplicates returns a map of content hashes to lists of FileInfo for files that have duplicate content,
+// each list sorted by path. Empty files are left out when SkipZeroBytes is set.
 // The index must be loaded before calling this method.
 func (idx *Index) FindAllDuplicates() map[string][]*FileInfo {
 	duplicates := make(map[string][]*FileInfo)
 
 	for hash, files := range idx.FilesByContentHash {
-		if len(files) > 1 {
+		if len(files) < 2 {
+			continue
+		}
+		// Empty files all share the same hash but have nothing meaningful in common.
+		if idx.SkipZeroBytes && files[0].Size == 0 {
+			continue
+		}
+
+		group := append([]*FileInfo{}, files...)
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].Path < group[j].Path
+		})
+		duplicates[hash] = group
+	}
+
+	return duplicates
+}
+
+// FindDuplicatesFast is like FindAllDuplicates, but scans the directory again instead of using the indexed hashes:
+// the files are first grouped by size, and only the ones of the same size as another file are hashed, since the
+// others cannot be duplicates. This requires access to the files on disk, and reading the ones of the same size,
+// but finds the current duplicates without hashing all the files, e.g. when the index is outdated.
+// The index is not modified, only its settings are used.
+func (idx *Index) FindDuplicatesFast(ctx context.Context) (map[string][]*FileInfo, error) {
+	current := idx.Clone()
+	current.FilesByContentHash = make(map[string][]*FileInfo)
+	current.SkipUniqueSizes = true
+	if _, err := current.scan(ctx); err != nil {
+		return nil, err
+	}
+	return current.FindAllDuplicates(), nil
+}
+
+// FindDuplicatesWithin returns the duplicate groups whose files are all located under the given directory.
+// The index must be loaded before calling this method.
+func (idx *Index) FindDuplicatesWithin(dir string) map[string][]*FileInfo {
+	duplicates := make(map[string][]*FileInfo)
+
+	for hash, files := range idx.FindAllDuplicates() {
+		allWithin := true
+		for _, file := range files {
+			if !isUnderDir(file.Path, dir) {
+				allWithin = false
+				break
+			}
+		}
+		if allWithin {
 			duplicates[hash] = files
 		}
 	}
@@ -221,34 +1664,426 @@ func (idx *Index) FindAllDuplicates() map[string][]*FileInfo {
 	return duplicates
 }
 
-// FindDuplicates searches for all files that have the same content hash as the one of the provided path.
-// It includes the target file path itself in the results.
+// FindDuplicatesOverlapping returns the duplicate groups with at least one file located under the given directory,
+// which includes files duplicated between this directory and the rest of the tree.
 // The index must be loaded before calling this method.
-func (idx *Index) FindDuplicates(targetPath string) ([]string, error) {
-	var targetHash string
+func (idx *Index) FindDuplicatesOverlapping(dir string) map[string][]*FileInfo {
+	duplicates := make(map[string][]*FileInfo)
+
+	for hash, files := range idx.FindAllDuplicates() {
+		for _, file := range files {
+			if isUnderDir(file.Path, dir) {
+				duplicates[hash] = files
+				break
+			}
+		}
+	}
+
+	return duplicates
+}
+
+// FindDuplicatesForPrefix returns, for each indexed file under the given directory, the paths of the files with
+// the same content outside of it, sorted, e.g. to know which files of the directory are already backed up elsewhere.
+// Files without duplicates outside of the directory are included with no paths. The prefix can also be the path of
+// a single file.
+// The index must be loaded before calling this method.
+func (idx *Index) FindDuplicatesForPrefix(prefix string) map[string][]string {
+	duplicates := make(map[string][]string)
+
+	inPrefix := func(path string) bool {
+		return filepath.Clean(path) == filepath.Clean(prefix) || isUnderDir(path, prefix)
+	}
 
+	hashes := make(map[string]string)
 	for hash, files := range idx.FilesByContentHash {
 		for _, file := range files {
-			if file.Path == targetPath {
-				targetHash = hash
+			hashes[file.Path] = hash
+		}
+	}
+
+	for path, hash := range hashes {
+		if !inPrefix(path) {
+			continue
+		}
+
+		outside := []string{}
+		for _, file := range idx.FilesByContentHash[hash] {
+			if !inPrefix(file.Path) {
+				outside = append(outside, file.Path)
+			}
+		}
+		sort.Strings(outside)
+		duplicates[path] = outside
+	}
+
+	return duplicates
+}
+
+// FindSameDirectoryDuplicates returns the duplicate groups whose files are all located in the same directory,
+// e.g. a file and its copy next to it.
+// The index must be loaded before calling this method.
+func (idx *Index) FindSameDirectoryDuplicates() map[string][]*FileInfo {
+	duplicates := make(map[string][]*FileInfo)
+
+	for hash, files := range idx.FindAllDuplicates() {
+		dir := filepath.Dir(files[0].Path)
+		sameDir := true
+		for _, file := range files[1:] {
+			if filepath.Dir(file.Path) != dir {
+				sameDir = false
 				break
 			}
 		}
-		if targetHash != "" {
-			break
+		if sameDir {
+			duplicates[hash] = files
+		}
+	}
+
+	return duplicates
+}
+
+// FindDuplicatesMinSize returns the duplicate groups having at least minSize files.
+// The index must be loaded before calling this method.
+func (idx *Index) FindDuplicatesMinSize(minSize int) map[string][]*FileInfo {
+	return filterGroupsBySize(idx.FindAllDuplicates(), minSize, 0)
+}
+
+// filterGroupsBySize returns the duplicate groups having at least minSize files,
+// and at most maxSize files unless it is 0.
+func filterGroupsBySize(duplicates map[string][]*FileInfo, minSize, maxSize int) map[string][]*FileInfo {
+	filtered := make(map[string][]*FileInfo)
+
+	for hash, files := range duplicates {
+		if len(files) < minSize || (maxSize > 0 && len(files) > maxSize) {
+			continue
+		}
+		filtered[hash] = files
+	}
+
+	return filtered
+}
+
+// isUnderDir returns true if the relative path is located in the given relative directory (or its subdirectories).
+func isUnderDir(path string, dir string) bool {
+	dir = filepath.Clean(dir)
+	if dir == "." {
+		return true
+	}
+	return strings.HasPrefix(filepath.Clean(path), dir+string(filepath.Separator))
+}
+
+// ZeroByteFiles returns all the indexed files that are empty, sorted by path.
+// The index must be loaded before calling this method.
+func (idx *Index) ZeroByteFiles() []*FileInfo {
+	return idx.filterFiles(func(fi *FileInfo) bool {
+		return fi.Size == 0
+	})
+}
+
+// FindChangedSince returns the indexed files modified at or after t, sorted by path.
+// The index must be loaded before calling this method.
+func (idx *Index) FindChangedSince(t time.Time) []*FileInfo {
+	return idx.filterFiles(func(fi *FileInfo) bool {
+		return !fi.ModTime.Before(t)
+	})
+}
+
+// FindUnchangedSince returns the indexed files modified before t, sorted by path.
+// It is the complement of FindChangedSince.
+// The index must be loaded before calling this method.
+func (idx *Index) FindUnchangedSince(t time.Time) []*FileInfo {
+	return idx.filterFiles(func(fi *FileInfo) bool {
+		return fi.ModTime.Before(t)
+	})
+}
+
+// ChangedFiles returns the indexed files modified strictly after since, sorted by path, according to the modification
+// times recorded in the index, without accessing the files.
+// The index must be loaded before calling this method.
+func (idx *Index) ChangedFiles(since time.Time) []*FileInfo {
+	return idx.filterFiles(func(fi *FileInfo) bool {
+		return fi.ModTime.After(since)
+	})
+}
+
+// ChangedFilesCount returns the number of files ChangedFiles would return.
+// The index must be loaded before calling this method.
+func (idx *Index) ChangedFilesCount(since time.Time) int {
+	count := 0
+	_ = idx.WalkFiles(func(fi *FileInfo, hash string) error {
+		if fi.ModTime.After(since) {
+			count++
+		}
+		return nil
+	})
+	return count
+}
+
+// MostRecentFile returns the indexed file with the latest modification time, or nil if the index is empty.
+// Of several files modified at the same time, the first by path is returned.
+// The index must be loaded before calling this method.
+func (idx *Index) MostRecentFile() *FileInfo {
+	return idx.extremeFile(func(a, b time.Time) bool { return a.After(b) })
+}
+
+// OldestFile returns the indexed file with the earliest modification time, or nil if the index is empty.
+// Of several files modified at the same time, the first by path is returned.
+// The index must be loaded before calling this method.
+func (idx *Index) OldestFile() *FileInfo {
+	return idx.extremeFile(func(a, b time.Time) bool { return a.Before(b) })
+}
+
+// extremeFile returns the indexed file whose modification time comes first according to less, in a single pass.
+func (idx *Index) extremeFile(less func(a, b time.Time) bool) *FileInfo {
+	var found *FileInfo
+	_ = idx.WalkFiles(func(fi *FileInfo, hash string) error {
+		if found == nil || less(fi.ModTime, found.ModTime) || (fi.ModTime.Equal(found.ModTime) && fi.Path < found.Path) {
+			found = fi
+		}
+		return nil
+	})
+	return found
+}
+
+// FilteredView returns an index with the settings of the index but only the files for which keep returns true, given
+// each file and its content hash, e.g. to compose queries like idx.FilteredView(isJPEG).FilteredView(isLarge).
+// The returned index shares the FileInfo of the files with the index, use Clone to modify them separately.
+// It is empty, not nil, if no file is kept. The directory statistics are recomputed if the index has them.
+// The index must be loaded before calling this method.
+func (idx *Index) FilteredView(keep func(fi *FileInfo, hash string) bool) *Index {
+	view := *idx
+	view.FilesByContentHash = make(map[string][]*FileInfo)
+	for hash, files := range idx.FilesByContentHash {
+		for _, file := range files {
+			if keep(file, hash) {
+				view.FilesByContentHash[hash] = append(view.FilesByContentHash[hash], file)
+			}
+		}
+	}
+
+	if idx.EmptyDirs != nil {
+		view.EmptyDirs = append([]string{}, idx.EmptyDirs...)
+	}
+	if idx.DirStats != nil {
+		view.DirStats = view.ComputeDirectoryStats()
+	}
+
+	return &view
+}
+
+// filterFiles returns the indexed files for which keep returns true, sorted by path.
+func (idx *Index) filterFiles(keep func(fi *FileInfo) bool) []*FileInfo {
+	files := []*FileInfo{}
+
+	for _, hashFiles := range idx.FilesByContentHash {
+		for _, file := range hashFiles {
+			if keep(file) {
+				files = append(files, file)
+			}
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return files
+}
+
+// GroupBySize returns all the indexed files grouped by exact size, each group sorted by path.
+// Only files of the same size can have the same content.
+// The index must be loaded before calling this method.
+func (idx *Index) GroupBySize() map[int64][]*FileInfo {
+	groups := make(map[int64][]*FileInfo)
+
+	for _, file := range idx.filterFiles(func(fi *FileInfo) bool { return true }) {
+		groups[file.Size] = append(groups[file.Size], file)
+	}
+
+	return groups
+}
+
+// LargestSizeGroup returns the size shared by the most files and these files, the largest size if several
+// have as many files, or 0 and no files if the index is empty.
+// The index must be loaded before calling this method.
+func (idx *Index) LargestSizeGroup() (int64, []*FileInfo) {
+	var largestSize int64
+	var largestGroup []*FileInfo
+
+	for size, files := range idx.GroupBySize() {
+		if len(files) > len(largestGroup) || (len(files) == len(largestGroup) && size > largestSize) {
+			largestSize = size
+			largestGroup = files
+		}
+	}
+
+	return largestSize, largestGroup
+}
+
+// SortedFilesBySize returns all the indexed files sorted by size, the smallest first unless descending is set.
+// Files of the same size are sorted by path.
+// The index must be loaded before calling this method.
+func (idx *Index) SortedFilesBySize(descending bool) []*FileInfo {
+	return idx.sortedFiles(func(a, b *FileInfo) int {
+		return compareInt64(a.Size, b.Size)
+	}, descending)
+}
+
+// SortedFilesByMtime returns all the indexed files sorted by modification time, the oldest first unless
+// descending is set. Files with the same modification time are sorted by path.
+// The index must be loaded before calling this method.
+func (idx *Index) SortedFilesByMtime(descending bool) []*FileInfo {
+	return idx.sortedFiles(func(a, b *FileInfo) int {
+		return a.ModTime.Compare(b.ModTime)
+	}, descending)
+}
+
+// sortedFiles returns all the indexed files sorted with the given comparison function, then by path.
+func (idx *Index) sortedFiles(compare func(a, b *FileInfo) int, descending bool) []*FileInfo {
+	files := make([]*FileInfo, 0, idx.FileCount())
+	_ = idx.WalkFiles(func(fi *FileInfo, hash string) error {
+		files = append(files, fi)
+		return nil
+	})
+
+	sort.Slice(files, func(i, j int) bool {
+		c := compare(files[i], files[j])
+		if descending {
+			c = -c
 		}
+		if c != 0 {
+			return c < 0
+		}
+		return files[i].Path < files[j].Path
+	})
+
+	return files
+}
+
+// compareInt64 returns -1, 0 or 1 depending on whether a is less than, equal to or greater than b.
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
 	}
+}
 
-	if targetHash == "" {
+// FindDuplicates searches for all files that have the same content hash as the one of the provided path.
+// It includes the target file path itself in the results.
+// The index must be loaded before calling this method.
+func (idx *Index) FindDuplicates(targetPath string) ([]string, error) {
+	targetHash, found := idx.hashOf(targetPath)
+	if !found {
 		return nil, fmt.Errorf("file %q not found in index", targetPath)
 	}
 
 	matchingPaths := []string{}
-	if files, exists := idx.FilesByContentHash[targetHash]; exists {
+	for _, file := range idx.FilesByContentHash[targetHash] {
+		matchingPaths = append(matchingPaths, file.Path)
+	}
+
+	return matchingPaths, nil
+}
+
+// FindDuplicatesExcludeSelf is like FindDuplicates, but leaves out the provided path from the returned ones.
+// The index must be loaded before calling this method.
+func (idx *Index) FindDuplicatesExcludeSelf(targetPath string) ([]string, error) {
+	paths, err := idx.FindDuplicates(targetPath)
+	if err != nil {
+		return nil, err
+	}
+
+	others := []string{}
+	for _, path := range paths {
+		if path != targetPath {
+			others = append(others, path)
+		}
+	}
+	return others, nil
+}
+
+// CountDuplicates returns the number of files with the same content as the provided path, including itself.
+// The index must be loaded before calling this method.
+func (idx *Index) CountDuplicates(targetPath string) (int, error) {
+	paths, err := idx.FindDuplicates(targetPath)
+	if err != nil {
+		return 0, err
+	}
+	return len(paths), nil
+}
+
+// FindByNamePattern returns all the indexed files whose base name matches the given glob pattern
+// (see filepath.Match for the pattern syntax), sorted by path.
+// The index must be loaded before calling this method.
+func (idx *Index) FindByNamePattern(pattern string) ([]*FileInfo, error) {
+	// Match against an empty name first so that an invalid pattern is reported even on an empty index.
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	matches := []*FileInfo{}
+	for _, files := range idx.FilesByContentHash {
 		for _, file := range files {
-			matchingPaths = append(matchingPaths, file.Path)
+			matched, err := filepath.Match(pattern, filepath.Base(file.Path))
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			if matched {
+				matches = append(matches, file)
+			}
 		}
 	}
 
-	return matchingPaths, nil
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Path < matches[j].Path
+	})
+
+	return matches, nil
+}
+
+// FindNameConflicts returns a map of file names to the files having this name, for the names shared
+// by files with different content (e.g. diverging copies of a configuration file).
+// The index must be loaded before calling this method.
+func (idx *Index) FindNameConflicts() map[string][]*FileInfo {
+	filesByName := make(map[string][]*FileInfo)
+	hashesByName := make(map[string]map[string]bool)
+
+	for hash, files := range idx.FilesByContentHash {
+		for _, file := range files {
+			name := filepath.Base(file.Path)
+			filesByName[name] = append(filesByName[name], file)
+			if hashesByName[name] == nil {
+				hashesByName[name] = make(map[string]bool)
+			}
+			hashesByName[name][hash] = true
+		}
+	}
+
+	conflicts := make(map[string][]*FileInfo)
+	for name, files := range filesByName {
+		if len(hashesByName[name]) > 1 {
+			sort.Slice(files, func(i, j int) bool {
+				return files[i].Path < files[j].Path
+			})
+			conflicts[name] = files
+		}
+	}
+
+	return conflicts
+}
+
+// HasDuplicates returns true if at least one other indexed file has the same content as the given path.
+func (idx *Index) HasDuplicates(path string) bool {
+	hash, found := idx.hashOf(path)
+	return found && len(idx.FilesByContentHash[hash]) > 1
+}
+
+// hashOf returns the content hash of the file at the given relative path, if it is indexed.
+func (idx *Index) hashOf(path string) (string, bool) {
+	fi, hash := idx.FindByPath(path)
+	return hash, fi != nil
 }
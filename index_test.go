@@ -1,9 +1,22 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestIndex(t *testing.T) {
@@ -166,14 +179,18 @@ func TestIndex(t *testing.T) {
 				t.Fatalf("setup failed: %v", err)
 			}
 
-			idx := NewIndex(testDir, tt.includeHidden)
-			count, err := idx.Index()
+			idx := NewIndexWithOptions(IndexOptions{RootPath: testDir, IncludeHidden: tt.includeHidden})
+			result, err := idx.IndexWithResult()
 			if err != nil {
-				t.Fatalf("Index() failed: %v", err)
+				t.Fatalf("IndexWithResult() failed: %v", err)
 			}
 
-			if count != tt.expectedCount {
-				t.Errorf("expected %d files indexed, got %d", tt.expectedCount, count)
+			if result.FileCount != tt.expectedCount {
+				t.Errorf("expected %d files indexed, got %d", tt.expectedCount, result.FileCount)
+			}
+
+			if len(result.Errors) != 0 {
+				t.Errorf("expected no errors, got %v", result.Errors)
 			}
 
 			indexPath := filepath.Join(testDir, IndexFile)
@@ -212,6 +229,30 @@ func TestIndex(t *testing.T) {
 	}
 }
 
+func TestIndexWithResultBytesRead(t *testing.T) {
+	testDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(testDir, "file1.txt"), []byte("12345"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "file2.txt"), []byte("1234567890"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	result, err := idx.IndexWithResult()
+	if err != nil {
+		t.Fatalf("IndexWithResult() failed: %v", err)
+	}
+
+	if result.BytesRead != 15 {
+		t.Errorf("expected 15 bytes read, got %d", result.BytesRead)
+	}
+	if result.Duration <= 0 {
+		t.Errorf("expected positive duration, got %v", result.Duration)
+	}
+}
+
 func TestCompare(t *testing.T) {
 	tests := []struct {
 		name                   string
@@ -418,9 +459,9 @@ func TestCompare(t *testing.T) {
 				includeHidden = true
 			}
 
-			idx := NewIndex(testDir, includeHidden)
-			if _, err := idx.Index(); err != nil {
-				t.Fatalf("Index() failed: %v", err)
+			idx := NewIndexWithOptions(IndexOptions{RootPath: testDir, IncludeHidden: includeHidden})
+			if _, err := idx.IndexWithResult(); err != nil {
+				t.Fatalf("IndexWithResult() failed: %v", err)
 			}
 
 			if err := tt.changeSetup(testDir); err != nil {
@@ -453,7 +494,7 @@ func TestCompare(t *testing.T) {
 }
 
 func TestIndexPath(t *testing.T) {
-	idx := NewIndex("/tmp", false)
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/tmp"})
 	expected := filepath.Join("/tmp", IndexFile)
 
 	if idx.indexPath() != expected {
@@ -490,8 +531,8 @@ func TestFindAllDuplicates(t *testing.T) {
 		t.Fatalf("failed to create file: %v", err)
 	}
 
-	idx := NewIndex(testDir, false)
-	if _, err := idx.Index(); err != nil {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
 		t.Fatalf("indexing failed: %v", err)
 	}
 
@@ -546,8 +587,8 @@ func TestFindDuplicates(t *testing.T) {
 		t.Fatalf("failed to create file: %v", err)
 	}
 
-	idx := NewIndex(testDir, false)
-	if _, err := idx.Index(); err != nil {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
 		t.Fatalf("indexing failed: %v", err)
 	}
 
@@ -574,3 +615,2340 @@ func TestFindDuplicates(t *testing.T) {
 		t.Error("expected error for non-existent file, got nil")
 	}
 }
+
+func TestFindByNamePattern(t *testing.T) {
+	testDir := t.TempDir()
+
+	files := map[string]string{
+		"photo1.jpg":        "content1",
+		"photo2.jpg":        "content2",
+		"notes.txt":         "content3",
+		"subdir/photo3.jpg": "content1",
+	}
+	for path, content := range files {
+		absPath := filepath.Join(testDir, path)
+		if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(absPath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	matches, err := idx.FindByNamePattern("*.jpg")
+	if err != nil {
+		t.Fatalf("FindByNamePattern() failed: %v", err)
+	}
+
+	expectedPaths := []string{"photo1.jpg", "photo2.jpg", "subdir/photo3.jpg"}
+	if len(matches) != len(expectedPaths) {
+		t.Fatalf("expected %d matches, got %d", len(expectedPaths), len(matches))
+	}
+	for i, match := range matches {
+		if match.Path != expectedPaths[i] {
+			t.Errorf("expected match %d to be %q, got %q", i, expectedPaths[i], match.Path)
+		}
+	}
+
+	matches, err = idx.FindByNamePattern("*.png")
+	if err != nil {
+		t.Fatalf("FindByNamePattern() failed: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("expected no matches, got %d", len(matches))
+	}
+
+	if _, err := idx.FindByNamePattern("[invalid"); err == nil {
+		t.Error("expected error for invalid pattern, got nil")
+	}
+}
+
+func TestZeroByteFiles(t *testing.T) {
+	testDir := t.TempDir()
+
+	for _, name := range []string{"empty1.txt", "empty2.txt", "empty3.txt"} {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte{}, 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "file1.txt"), []byte("content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "file2.txt"), []byte("content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	emptyFiles := idx.ZeroByteFiles()
+	if len(emptyFiles) != 3 {
+		t.Errorf("expected 3 zero-byte files, got %d", len(emptyFiles))
+	}
+
+	if duplicates := idx.FindAllDuplicates(); len(duplicates) != 2 {
+		t.Errorf("expected 2 duplicate groups including empty files, got %d", len(duplicates))
+	}
+
+	idx.SkipZeroBytes = true
+	duplicates := idx.FindAllDuplicates()
+	if len(duplicates) != 1 {
+		t.Errorf("expected 1 duplicate group when skipping empty files, got %d", len(duplicates))
+	}
+	if _, exists := duplicates[computeHash([]byte("content"))]; !exists {
+		t.Error("expected duplicate group for non-empty content")
+	}
+}
+
+func TestFindChangedSince(t *testing.T) {
+	since := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/tmp"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"hash1": {{Path: "old.txt", ModTime: since.Add(-time.Second)}, {Path: "boundary.txt", ModTime: since}},
+		"hash2": {{Path: "new.txt", ModTime: since.Add(time.Second)}},
+	}
+
+	tests := []struct {
+		name     string
+		find     func(time.Time) []*FileInfo
+		expected []string
+	}{
+		{"changed", idx.FindChangedSince, []string{"boundary.txt", "new.txt"}},
+		{"unchanged", idx.FindUnchangedSince, []string{"old.txt"}},
+		{"changed after", idx.ChangedFiles, []string{"new.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths := []string{}
+			for _, file := range tt.find(since) {
+				paths = append(paths, file.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, paths)
+			}
+		})
+	}
+}
+
+func TestChangedFilesCount(t *testing.T) {
+	since := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/tmp"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"hash1": {{Path: "old.txt", ModTime: since.Add(-time.Hour)}, {Path: "copy.txt", ModTime: since.Add(time.Hour)}},
+		"hash2": {{Path: "new.txt", ModTime: since.Add(time.Minute)}},
+	}
+
+	tests := []struct {
+		since    time.Time
+		expected int
+	}{
+		{since.Add(-2 * time.Hour), 3},
+		{since, 2},
+		{since.Add(time.Minute), 1},
+		{since.Add(2 * time.Hour), 0},
+	}
+
+	for _, tt := range tests {
+		if count := idx.ChangedFilesCount(tt.since); count != tt.expected {
+			t.Errorf("ChangedFilesCount(%v): expected %d, got %d", tt.since, tt.expected, count)
+		}
+		if files := idx.ChangedFiles(tt.since); len(files) != tt.expected {
+			t.Errorf("ChangedFiles(%v): expected %d files, got %d", tt.since, tt.expected, len(files))
+		}
+	}
+}
+
+func TestMostRecentAndOldestFile(t *testing.T) {
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/tmp"})
+	if idx.MostRecentFile() != nil || idx.OldestFile() != nil {
+		t.Error("expected nil for an empty index")
+	}
+
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"hash1": {{Path: "middle.txt", ModTime: base}, {Path: "newest.txt", ModTime: base.Add(time.Hour)}},
+		"hash2": {{Path: "oldest.txt", ModTime: base.Add(-time.Hour)}, {Path: "b_oldest.txt", ModTime: base.Add(-time.Hour)}},
+	}
+
+	if newest := idx.MostRecentFile(); newest == nil || newest.Path != "newest.txt" {
+		t.Errorf("expected newest.txt as the most recent file, got %v", newest)
+	}
+	if oldest := idx.OldestFile(); oldest == nil || oldest.Path != "b_oldest.txt" {
+		t.Errorf("expected b_oldest.txt, the first by path of the oldest files, got %v", oldest)
+	}
+}
+
+func TestIndexIncludePatterns(t *testing.T) {
+	testDir := t.TempDir()
+
+	files := []string{"main.go", "README.md", "pkg/util.go", "pkg/util_test.go", "pkg/data.json"}
+	for _, path := range files {
+		absPath := filepath.Join(testDir, path)
+		if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(absPath, []byte(path), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		patterns      []string
+		expectedPaths []string
+	}{
+		{"no_patterns", nil, files},
+		{"name_pattern", []string{"*.go"}, []string{"main.go", "pkg/util.go", "pkg/util_test.go"}},
+		{"path_pattern", []string{"pkg/*.go"}, []string{"pkg/util.go", "pkg/util_test.go"}},
+		{"multiple_patterns", []string{"*.md", "*.json"}, []string{"README.md", "pkg/data.json"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+			idx.IncludePatterns = tt.patterns
+
+			result, err := idx.IndexWithResult()
+			if err != nil {
+				t.Fatalf("IndexWithResult() failed: %v", err)
+			}
+
+			if result.FileCount != len(tt.expectedPaths) {
+				t.Errorf("expected %d files indexed, got %d", len(tt.expectedPaths), result.FileCount)
+			}
+			for _, path := range tt.expectedPaths {
+				if _, found := idx.hashOf(path); !found {
+					t.Errorf("expected %q to be indexed", path)
+				}
+			}
+		})
+	}
+
+	loaded := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if len(loaded.IncludePatterns) != 2 {
+		t.Errorf("expected include patterns to be persisted, got %v", loaded.IncludePatterns)
+	}
+}
+
+func TestIndexXXHashDuplicates(t *testing.T) {
+	testDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(testDir, "file1.txt"), []byte("duplicate content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "file2.txt"), []byte("duplicate content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "file3.txt"), []byte("unique content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	idx.HashAlgorithm = HashXXHash
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	duplicates := idx.FindAllDuplicates()
+	if len(duplicates) != 1 {
+		t.Fatalf("expected 1 duplicate group, got %d", len(duplicates))
+	}
+	for hash, files := range duplicates {
+		if len(hash) != 16 {
+			t.Errorf("expected 16 chars xxhash, got %q", hash)
+		}
+		if len(files) != 2 {
+			t.Errorf("expected 2 files in duplicate group, got %d", len(files))
+		}
+	}
+
+	loaded := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if loaded.HashAlgorithm != HashXXHash {
+		t.Errorf("expected hash algorithm %q to be persisted, got %q", HashXXHash, loaded.HashAlgorithm)
+	}
+}
+
+func TestCountDuplicates(t *testing.T) {
+	testDir := t.TempDir()
+	files := map[string]string{"file1.txt": "duplicate content", "file2.txt": "duplicate content", "file3.txt": "unique content"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	for path, expected := range map[string]int{"file1.txt": 2, "file3.txt": 1} {
+		count, err := idx.CountDuplicates(path)
+		if err != nil {
+			t.Fatalf("CountDuplicates(%s) failed: %v", path, err)
+		}
+		if count != expected {
+			t.Errorf("expected %d files with the content of %s, got %d", expected, path, count)
+		}
+	}
+
+	if _, err := idx.CountDuplicates("nonexistent.txt"); err == nil {
+		t.Error("expected error for a file not in the index")
+	}
+}
+
+func TestFindDuplicatesExcludeSelf(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"group":  {{Path: "a.txt"}, {Path: "b.txt"}, {Path: "c.txt"}},
+		"unique": {{Path: "unique.txt"}},
+	}
+
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{"a.txt", []string{"b.txt", "c.txt"}},
+		{"c.txt", []string{"a.txt", "b.txt"}},
+		{"unique.txt", []string{}},
+	}
+
+	for _, tt := range tests {
+		paths, err := idx.FindDuplicatesExcludeSelf(tt.path)
+		if err != nil {
+			t.Fatalf("FindDuplicatesExcludeSelf(%s) failed: %v", tt.path, err)
+		}
+		if !reflect.DeepEqual(sortedPaths(paths), tt.expected) {
+			t.Errorf("FindDuplicatesExcludeSelf(%s): expected %v, got %v", tt.path, tt.expected, paths)
+		}
+	}
+
+	if _, err := idx.FindDuplicatesExcludeSelf("missing.txt"); err == nil {
+		t.Error("expected error for a file not in the index")
+	}
+}
+
+func TestFindDuplicatesFast(t *testing.T) {
+	testDir := t.TempDir()
+	files := map[string]string{
+		"a.txt":      "duplicate",
+		"b.txt":      "duplicate",
+		"same.txt":   "different", // Same size as the duplicates, but another content.
+		"unique.txt": "unique size",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	// A duplicate created after indexing is found without re-indexing.
+	if err := os.WriteFile(filepath.Join(testDir, "c.txt"), []byte("duplicate"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	duplicates, err := idx.FindDuplicatesFast(context.Background())
+	if err != nil {
+		t.Fatalf("FindDuplicatesFast() failed: %v", err)
+	}
+	group := duplicates[computeHash([]byte("duplicate"))]
+	if len(duplicates) != 1 || len(group) != 3 {
+		t.Errorf("expected 1 group of 3 files, got %v", duplicates)
+	}
+	if _, found := idx.hashOf("c.txt"); found {
+		t.Error("expected the index not to be modified")
+	}
+
+	// The file of a unique size is not hashed.
+	current := idx.Clone()
+	current.FilesByContentHash = make(map[string][]*FileInfo)
+	current.SkipUniqueSizes = true
+	result, err := current.scan(context.Background())
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if result.FileCount != 4 {
+		t.Errorf("expected 4 files hashed, got %d", result.FileCount)
+	}
+	if _, found := current.hashOf("unique.txt"); found {
+		t.Error("expected the file of a unique size to be left out")
+	}
+}
+
+func TestFindDuplicatesWithinAndOverlapping(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/tmp"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"inside":  {{Path: "photos/2023/a.jpg"}, {Path: "photos/2023/sub/b.jpg"}},
+		"cross":   {{Path: "photos/2023/c.jpg"}, {Path: "backup/c.jpg"}},
+		"outside": {{Path: "backup/d.jpg"}, {Path: "other/d.jpg"}},
+		"similar": {{Path: "photos/2023-old/e.jpg"}, {Path: "photos/2023-old/f.jpg"}},
+		"single":  {{Path: "photos/2023/g.jpg"}},
+	}
+
+	within := idx.FindDuplicatesWithin("photos/2023/")
+	if len(within) != 1 {
+		t.Errorf("expected 1 group within photos/2023, got %d: %v", len(within), within)
+	}
+	if _, exists := within["inside"]; !exists {
+		t.Error("expected 'inside' group within photos/2023")
+	}
+
+	overlapping := idx.FindDuplicatesOverlapping("photos/2023")
+	if len(overlapping) != 2 {
+		t.Errorf("expected 2 groups overlapping photos/2023, got %d: %v", len(overlapping), overlapping)
+	}
+	for _, hash := range []string{"inside", "cross"} {
+		if _, exists := overlapping[hash]; !exists {
+			t.Errorf("expected %q group overlapping photos/2023", hash)
+		}
+	}
+}
+
+func TestFindDuplicatesForPrefix(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/tmp"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"backed-up": {{Path: filepath.Join("photos", "a.jpg")}, {Path: "a.jpg"}, {Path: filepath.Join("backup", "a.jpg")}},
+		"internal":  {{Path: filepath.Join("photos", "b.jpg")}, {Path: filepath.Join("photos", "old", "b.jpg")}},
+		"unique":    {{Path: filepath.Join("photos", "c.jpg")}},
+		"outside":   {{Path: "d.jpg"}, {Path: filepath.Join("backup", "d.jpg")}},
+	}
+
+	expected := map[string][]string{
+		filepath.Join("photos", "a.jpg"):        {"a.jpg", filepath.Join("backup", "a.jpg")},
+		filepath.Join("photos", "b.jpg"):        {},
+		filepath.Join("photos", "old", "b.jpg"): {},
+		filepath.Join("photos", "c.jpg"):        {},
+	}
+	if duplicates := idx.FindDuplicatesForPrefix("photos/"); !reflect.DeepEqual(duplicates, expected) {
+		t.Errorf("expected %v, got %v", expected, duplicates)
+	}
+
+	expected = map[string][]string{filepath.Join("photos", "a.jpg"): {"a.jpg", filepath.Join("backup", "a.jpg")}}
+	if duplicates := idx.FindDuplicatesForPrefix(filepath.Join("photos", "a.jpg")); !reflect.DeepEqual(duplicates, expected) {
+		t.Errorf("expected %v for a file, got %v", expected, duplicates)
+	}
+}
+
+func TestGroupBySize(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/tmp"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"hash1": {{Path: "b.txt", Size: 10}, {Path: "a.txt", Size: 10}},
+		"hash2": {{Path: "c.txt", Size: 10}},
+		"hash3": {{Path: "d.txt", Size: 20}, {Path: "e.txt", Size: 20}},
+		"hash4": {{Path: "f.txt", Size: 30}},
+	}
+
+	groups := idx.GroupBySize()
+	expected := map[int64][]string{10: {"a.txt", "b.txt", "c.txt"}, 20: {"d.txt", "e.txt"}, 30: {"f.txt"}}
+	if len(groups) != len(expected) {
+		t.Errorf("expected %d size groups, got %d", len(expected), len(groups))
+	}
+	for size, paths := range expected {
+		groupPaths := []string{}
+		for _, file := range groups[size] {
+			groupPaths = append(groupPaths, file.Path)
+		}
+		if !reflect.DeepEqual(groupPaths, paths) {
+			t.Errorf("expected files %v of size %d, got %v", paths, size, groupPaths)
+		}
+	}
+
+	size, files := idx.LargestSizeGroup()
+	if size != 10 || len(files) != 3 {
+		t.Errorf("expected the largest group to be the 3 files of size 10, got %d files of size %d", len(files), size)
+	}
+
+	if size, files := NewIndexWithOptions(IndexOptions{RootPath: "/tmp"}).LargestSizeGroup(); size != 0 || files != nil {
+		t.Errorf("expected no group for an empty index, got %d files of size %d", len(files), size)
+	}
+}
+
+func TestFindSameDirectoryDuplicates(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/tmp"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"siblings": {{Path: filepath.Join("photos", "photo.jpg")}, {Path: filepath.Join("photos", "photo_copy.jpg")}},
+		"root":     {{Path: "a.txt"}, {Path: "a copy.txt"}},
+		"cross":    {{Path: filepath.Join("photos", "b.jpg")}, {Path: filepath.Join("backup", "b.jpg")}},
+		"nested":   {{Path: filepath.Join("photos", "c.jpg")}, {Path: filepath.Join("photos", "old", "c.jpg")}},
+		"single":   {{Path: filepath.Join("photos", "d.jpg")}},
+	}
+
+	duplicates := idx.FindSameDirectoryDuplicates()
+	hashes := []string{}
+	for hash := range duplicates {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	if expected := []string{"root", "siblings"}; !reflect.DeepEqual(hashes, expected) {
+		t.Errorf("expected groups %v, got %v", expected, hashes)
+	}
+}
+
+func TestAutoIndexSuffix(t *testing.T) {
+	suffix := autoIndexSuffix("/home/alice")
+	if len(suffix) != 8 {
+		t.Errorf("expected an 8 characters suffix, got %q", suffix)
+	}
+	if autoIndexSuffix("/home/alice") != suffix {
+		t.Error("expected the same path to always produce the same suffix")
+	}
+	if autoIndexSuffix("/home/bob") == suffix {
+		t.Error("expected different paths to produce different suffixes")
+	}
+}
+
+func TestIndexAutoSuffix(t *testing.T) {
+	testDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(testDir, "file.txt"), []byte("content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	idx.AutoSuffix = true
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("IndexWithResult() failed: %v", err)
+	}
+
+	expectedPath := filepath.Join(testDir, "bff-"+autoIndexSuffix(testDir)+".json")
+	if _, err := os.Stat(expectedPath); err != nil {
+		t.Errorf("expected the index at %s: %v", expectedPath, err)
+	}
+	if _, err := os.Stat(filepath.Join(testDir, IndexFile)); !os.IsNotExist(err) {
+		t.Errorf("expected no %s in the indexed directory", IndexFile)
+	}
+
+	// The index is found without the option, and the suffixed file is not indexed.
+	loaded := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if !loaded.AutoSuffix || loaded.indexPath() != expectedPath {
+		t.Errorf("expected the loaded index to keep its path, got %s", loaded.indexPath())
+	}
+	if !reflect.DeepEqual(loaded.AllPaths(), []string{"file.txt"}) {
+		t.Errorf("expected only file.txt to be indexed, got %v", loaded.AllPaths())
+	}
+}
+
+func TestLoadWithParents(t *testing.T) {
+	rootDir := filepath.Join(t.TempDir(), "a")
+	subDir := filepath.Join(rootDir, "b", "c")
+	if err := os.MkdirAll(filepath.Join(subDir, "d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{
+		filepath.Join(rootDir, "x.txt"):     "content",
+		filepath.Join(subDir, "y.txt"):      "content",
+		filepath.Join(subDir, "d", "z.txt"): "other",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root := NewIndexWithOptions(IndexOptions{RootPath: rootDir})
+	if _, err := root.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: subDir})
+	if err := idx.Load(); !errors.Is(err, errIndexNotFound) {
+		t.Fatalf("expected no index for the subdirectory, got %v", err)
+	}
+
+	sub, err := idx.LoadWithParents(0)
+	if err != nil {
+		t.Fatalf("LoadWithParents failed: %v", err)
+	}
+	if sub.AbsPath != subDir {
+		t.Errorf("expected the index to be restricted to %s, got %s", subDir, sub.AbsPath)
+	}
+	if expected := []string{filepath.Join("d", "z.txt"), "y.txt"}; !reflect.DeepEqual(sub.AllPaths(), expected) {
+		t.Errorf("expected paths %v, got %v", expected, sub.AllPaths())
+	}
+
+	result, err := sub.Compare()
+	if err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+	if result.HasChanges() {
+		t.Errorf("expected no changes in the subdirectory, got %+v", result)
+	}
+
+	if _, err := idx.LoadWithParents(1); !errors.Is(err, errIndexNotFound) {
+		t.Errorf("expected the index two levels up not to be found with a maximum of 1 level, got %v", err)
+	}
+}
+
+func TestExtractSubtree(t *testing.T) {
+	rootDir := t.TempDir()
+	photosDir := filepath.Join(rootDir, "photos")
+	if err := os.MkdirAll(filepath.Join(photosDir, "2024"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{
+		filepath.Join(rootDir, "notes.txt"):                "notes",
+		filepath.Join(rootDir, "photos.txt"):               "not in photos",
+		filepath.Join(photosDir, "cat.jpg"):                "cat",
+		filepath.Join(photosDir, "2024", "dog.jpg"):        "dog",
+		filepath.Join(photosDir, "2024", "cat (copy).jpg"): "cat",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: rootDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	if err := idx.ExtractSubtree("photos", filepath.Join(photosDir, IndexFile)); err != nil {
+		t.Fatalf("ExtractSubtree failed: %v", err)
+	}
+
+	extracted := NewIndexWithOptions(IndexOptions{RootPath: photosDir})
+	if err := extracted.Load(); err != nil {
+		t.Fatalf("failed to load the extracted index: %v", err)
+	}
+	expected := []string{filepath.Join("2024", "cat (copy).jpg"), filepath.Join("2024", "dog.jpg"), "cat.jpg"}
+	if !reflect.DeepEqual(extracted.AllPaths(), expected) {
+		t.Errorf("expected paths %v, got %v", expected, extracted.AllPaths())
+	}
+	if !extracted.HasDuplicates("cat.jpg") {
+		t.Errorf("expected the duplicates in the subdirectory to be kept")
+	}
+
+	result, err := extracted.Compare()
+	if err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+	if result.HasChanges() {
+		t.Errorf("expected no changes in the extracted directory, got %+v", result)
+	}
+
+	if err := idx.ExtractSubtree("..", filepath.Join(t.TempDir(), IndexFile)); err == nil {
+		t.Errorf("expected an error for a directory outside of the indexed one")
+	}
+}
+
+func TestIndexOnlyChanged(t *testing.T) {
+	testDir := t.TempDir()
+	for name, content := range map[string]string{"changed.txt": "old", "same.txt": "same", "stale.txt": "abc", "deleted.txt": "gone"} {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	future := time.Now().Add(time.Hour)
+	changedPath := filepath.Join(testDir, "changed.txt")
+	if err := os.WriteFile(changedPath, []byte("new content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(changedPath, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	// Same size and modification time: the file is not read, so its change is not detected.
+	stalePath := filepath.Join(testDir, "stale.txt")
+	staleInfo, err := os.Stat(stalePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stalePath, []byte("xyz"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(stalePath, staleInfo.ModTime(), staleInfo.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(filepath.Join(testDir, "deleted.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "added.txt"), []byte("added"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	result, err := loaded.IndexOnlyChanged(context.Background())
+	if err != nil {
+		t.Fatalf("IndexOnlyChanged failed: %v", err)
+	}
+
+	if result.FileCount != 4 || result.Reused != 2 {
+		t.Errorf("expected 2 of 4 files to be hashed, got %d reused of %d", result.Reused, result.FileCount)
+	}
+
+	expectedHashes := map[string]string{
+		"changed.txt": computeHash([]byte("new content")),
+		"same.txt":    computeHash([]byte("same")),
+		"stale.txt":   computeHash([]byte("abc")),
+		"added.txt":   computeHash([]byte("added")),
+	}
+	for path, expected := range expectedHashes {
+		if hash, _ := loaded.hashOf(path); hash != expected {
+			t.Errorf("%s: expected hash %s, got %s", path, expected, hash)
+		}
+	}
+	if _, indexed := loaded.hashOf("deleted.txt"); indexed {
+		t.Errorf("expected the deleted file to be removed from the index")
+	}
+}
+
+func TestRebase(t *testing.T) {
+	tempDir := t.TempDir()
+	rootDir := filepath.Join(tempDir, "a")
+	photosDir := filepath.Join(rootDir, "photos")
+	for _, dir := range []string{filepath.Join(photosDir, "2024"), filepath.Join(rootDir, "other")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for path, content := range map[string]string{
+		filepath.Join(rootDir, "other", "cat.jpg"):  "other cat",
+		filepath.Join(photosDir, "cat.jpg"):         "cat",
+		filepath.Join(photosDir, "2024", "dog.jpg"): "dog",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root := NewIndexWithOptions(IndexOptions{RootPath: rootDir})
+	if _, err := root.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+	if err := root.Rebase(photosDir, filepath.Join("archive", "photos")); err != nil {
+		t.Fatalf("Rebase failed: %v", err)
+	}
+	expected := []string{filepath.Join("archive", "photos", "2024", "dog.jpg"), filepath.Join("archive", "photos", "cat.jpg"), filepath.Join("other", "cat.jpg")}
+	if !reflect.DeepEqual(root.AllPaths(), expected) {
+		t.Errorf("expected paths %v, got %v", expected, root.AllPaths())
+	}
+
+	newPhotosDir := filepath.Join(tempDir, "b", "photos")
+	if err := root.Rebase(filepath.Join("archive", "photos"), newPhotosDir); err == nil {
+		t.Errorf("expected an error when moving files outside of the indexed directory")
+	}
+	if err := root.Rebase(filepath.Join("archive", "photos"), "other"); err == nil {
+		t.Errorf("expected an error when moving files onto other indexed files")
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: photosDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+	if err := idx.Rebase(photosDir+string(filepath.Separator), newPhotosDir); err != nil {
+		t.Fatalf("Rebase failed: %v", err)
+	}
+	if idx.AbsPath != newPhotosDir {
+		t.Errorf("expected the indexed directory to be %s, got %s", newPhotosDir, idx.AbsPath)
+	}
+	if expected := []string{filepath.Join("2024", "dog.jpg"), "cat.jpg"}; !reflect.DeepEqual(idx.AllPaths(), expected) {
+		t.Errorf("expected the paths %v to be kept, got %v", expected, idx.AllPaths())
+	}
+}
+
+func TestWriteVerified(t *testing.T) {
+	testDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(testDir, "file.txt"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+	indexPath := filepath.Join(testDir, IndexFile)
+	saved, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A truncated index, e.g. because of a bug or a disk problem, fails the check.
+	if err := idx.writeVerified(saved[:len(saved)/2]); err == nil {
+		t.Fatalf("expected an error for an invalid index")
+	}
+
+	data, err := os.ReadFile(indexPath)
+	if err != nil || !bytes.Equal(data, saved) {
+		t.Errorf("expected the previous index file to be kept, got %v", err)
+	}
+	if _, err := os.Stat(verifiedNewPath(indexPath)); !os.IsNotExist(err) {
+		t.Errorf("expected the invalid new index file to be deleted, got %v", err)
+	}
+
+	if err := idx.writeVerified(saved); err != nil {
+		t.Errorf("writeVerified failed for a valid index: %v", err)
+	}
+}
+
+func TestSaveInterruptedBeforeRename(t *testing.T) {
+	testDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(testDir, "file.txt"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+	indexPath := filepath.Join(testDir, IndexFile)
+	saved, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The new index is only moved to the index file once fully written, so failing before then keeps the previous one.
+	rename = func(oldPath, newPath string) error {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: syscall.EIO}
+	}
+	defer func() { rename = os.Rename }()
+
+	if err := os.WriteFile(filepath.Join(testDir, "other.txt"), []byte("other"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.IndexWithResult(); err == nil {
+		t.Fatal("expected an error when the index file cannot be moved into place")
+	}
+
+	data, err := os.ReadFile(indexPath)
+	if err != nil || !bytes.Equal(data, saved) {
+		t.Errorf("expected the previous index file to be kept, got %v", err)
+	}
+	entries, err := os.ReadDir(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if isTempFileOf(filepath.Join(testDir, entry.Name()), verifiedNewPath(indexPath)) {
+			t.Errorf("expected the temp file %s to be deleted", entry.Name())
+		}
+	}
+}
+
+func TestWriteAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), IndexFile)
+	for _, content := range []string{"first", "second"} {
+		if err := writeAtomic(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writeAtomic failed: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != content {
+			t.Errorf("expected %q, got %q (%v)", content, data, err)
+		}
+	}
+
+	// A failed rename keeps the previous file and deletes the temp file.
+	rename = func(oldPath, newPath string) error {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: syscall.EIO}
+	}
+	defer func() { rename = os.Rename }()
+
+	if err := writeAtomic(path, []byte("third"), 0644); err == nil {
+		t.Fatal("expected an error when the file cannot be renamed into place")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "second" {
+		t.Errorf("expected the previous content to be kept, got %q (%v)", data, err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the written file, got %v", entries)
+	}
+}
+
+func TestIndexStoredInXDG(t *testing.T) {
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+
+	testDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(testDir, "file.txt"), []byte("content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	idx.StoredInXDG = true
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("IndexWithResult() failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(testDir, IndexFile)); !os.IsNotExist(err) {
+		t.Errorf("expected no %s in the indexed directory", IndexFile)
+	}
+
+	expectedPath := filepath.Join(dataHome, "bff", strings.ReplaceAll(filepath.ToSlash(testDir), "/", "_")+".json")
+	if idx.indexPath() != expectedPath {
+		t.Errorf("expected index path %s, got %s", expectedPath, idx.indexPath())
+	}
+	if _, err := os.Stat(expectedPath); err != nil {
+		t.Errorf("expected index file at %s: %v", expectedPath, err)
+	}
+
+	// The index is found in the XDG directory even without asking for it.
+	loaded := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if !loaded.StoredInXDG {
+		t.Error("expected loaded index to be marked as stored in XDG")
+	}
+	if _, found := loaded.hashOf("file.txt"); !found {
+		t.Error("expected file.txt in loaded index")
+	}
+}
+
+func TestCompareStream(t *testing.T) {
+	testDir := t.TempDir()
+
+	for name, content := range map[string]string{"modified.txt": "original", "deleted.txt": "deleted", "old.txt": "renamed"} {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(testDir, "modified.txt"), []byte("modified"), 0644); err != nil {
+		t.Fatalf("failed to modify file: %v", err)
+	}
+	if err := os.Remove(filepath.Join(testDir, "deleted.txt")); err != nil {
+		t.Fatalf("failed to delete file: %v", err)
+	}
+	if err := os.Rename(filepath.Join(testDir, "old.txt"), filepath.Join(testDir, "new.txt")); err != nil {
+		t.Fatalf("failed to rename file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "added.txt"), []byte("added"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := idx.CompareStream(context.Background(), &buf); err != nil {
+		t.Fatalf("CompareStream() failed: %v", err)
+	}
+
+	expected := map[string]ChangeEvent{
+		ChangeAdded:          {Type: ChangeAdded, Path: "added.txt"},
+		ChangeModified:       {Type: ChangeModified, Path: "modified.txt"},
+		ChangeDeleted:        {Type: ChangeDeleted, Path: "deleted.txt"},
+		ChangeRenamedOrMoved: {Type: ChangeRenamedOrMoved, Path: "new.txt", OldPath: "old.txt"},
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d events, got %d: %q", len(expected), len(lines), buf.String())
+	}
+
+	for _, line := range lines {
+		var event ChangeEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Errorf("invalid JSON line %q: %v", line, err)
+			continue
+		}
+		if event != expected[event.Type] {
+			t.Errorf("expected event %+v, got %+v", expected[event.Type], event)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := idx.CompareStream(ctx, &buf); err == nil {
+		t.Error("expected error with cancelled context, got nil")
+	}
+}
+
+func TestFindNameConflicts(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/tmp"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"hash1": {{Path: "app1/config.yaml"}, {Path: "app2/config.yaml"}},
+		"hash2": {{Path: "app3/config.yaml"}},
+		"hash3": {{Path: "a/README.md"}, {Path: "b/README.md"}},
+		"hash4": {{Path: "unique.txt"}},
+	}
+
+	conflicts := idx.FindNameConflicts()
+
+	if len(conflicts) != 1 {
+		t.Fatalf("expected 1 name conflict, got %d: %v", len(conflicts), conflicts)
+	}
+
+	files, exists := conflicts["config.yaml"]
+	if !exists {
+		t.Fatal("expected config.yaml conflict")
+	}
+	expectedPaths := []string{"app1/config.yaml", "app2/config.yaml", "app3/config.yaml"}
+	if len(files) != len(expectedPaths) {
+		t.Fatalf("expected %d files, got %d", len(expectedPaths), len(files))
+	}
+	for i, file := range files {
+		if file.Path != expectedPaths[i] {
+			t.Errorf("expected %q, got %q", expectedPaths[i], file.Path)
+		}
+	}
+}
+
+func TestPrintChecksums(t *testing.T) {
+	testDir := t.TempDir()
+
+	files := map[string]string{"b.txt": "content b", "a.txt": "content a", "sub/c.txt": "content a"}
+	for path, content := range files {
+		absPath := filepath.Join(testDir, path)
+		if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(absPath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	var buf bytes.Buffer
+	if err := idx.PrintChecksums(context.Background(), &buf); err != nil {
+		t.Fatalf("PrintChecksums() failed: %v", err)
+	}
+
+	expected := computeHash([]byte("content a")) + "  a.txt\n" +
+		computeHash([]byte("content b")) + "  b.txt\n" +
+		computeHash([]byte("content a")) + "  sub/c.txt\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected checksums:\ngot:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+
+	if _, err := os.Stat(filepath.Join(testDir, IndexFile)); !os.IsNotExist(err) {
+		t.Error("expected no index file to be written")
+	}
+
+	sha256sum, err := exec.LookPath("sha256sum")
+	if err != nil {
+		t.Skip("sha256sum not available")
+	}
+	checksumsPath := filepath.Join(t.TempDir(), "checksums.txt")
+	if err := os.WriteFile(checksumsPath, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write checksums: %v", err)
+	}
+	cmd := exec.Command(sha256sum, "--check", "--quiet", checksumsPath)
+	cmd.Dir = testDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("sha256sum --check failed: %v\n%s", err, output)
+	}
+}
+
+func TestIndexIncludeEmptyDirs(t *testing.T) {
+	testDir := t.TempDir()
+
+	for _, dir := range []string{"empty", "full", "nested/empty", "only_hidden"} {
+		if err := os.MkdirAll(filepath.Join(testDir, dir), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "full", "file.txt"), []byte("content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "only_hidden", ".hidden"), []byte("hidden"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	idx.IncludeEmptyDirs = true
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("IndexWithResult() failed: %v", err)
+	}
+
+	expected := []string{"empty", "nested/empty", "only_hidden"}
+	if len(idx.EmptyDirs) != len(expected) {
+		t.Fatalf("expected empty directories %v, got %v", expected, idx.EmptyDirs)
+	}
+	for i, dir := range expected {
+		if idx.EmptyDirs[i] != dir {
+			t.Errorf("expected empty directory %q, got %q", dir, idx.EmptyDirs[i])
+		}
+	}
+
+	if err := os.Remove(filepath.Join(testDir, "empty")); err != nil {
+		t.Fatalf("failed to remove directory: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(testDir, "new_empty"), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	loaded := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	comparison, err := loaded.Compare()
+	if err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+
+	if len(comparison.AddedDirs) != 1 || comparison.AddedDirs[0] != "new_empty" {
+		t.Errorf("expected 'new_empty' to be added, got %v", comparison.AddedDirs)
+	}
+	if len(comparison.DeletedDirs) != 1 || comparison.DeletedDirs[0] != "empty" {
+		t.Errorf("expected 'empty' to be deleted, got %v", comparison.DeletedDirs)
+	}
+	if !comparison.HasChanges() {
+		t.Error("expected directory changes to be reported as changes")
+	}
+
+	withoutDirs := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := withoutDirs.IndexWithResult(); err != nil {
+		t.Fatalf("IndexWithResult() failed: %v", err)
+	}
+	if withoutDirs.EmptyDirs != nil {
+		t.Errorf("expected no empty directories tracked by default, got %v", withoutDirs.EmptyDirs)
+	}
+}
+
+func TestDiffIndexFiles(t *testing.T) {
+	testDir := t.TempDir()
+
+	idxA := NewIndexWithOptions(IndexOptions{RootPath: "/data/a"})
+	idxA.FilesByContentHash = map[string][]*FileInfo{
+		"hash1": {{Path: "kept.txt"}},
+		"hash2": {{Path: "deleted.txt"}},
+	}
+	idxB := NewIndexWithOptions(IndexOptions{RootPath: "/data/b"})
+	idxB.FilesByContentHash = map[string][]*FileInfo{
+		"hash1": {{Path: "kept.txt"}},
+		"hash3": {{Path: "added.txt"}},
+	}
+
+	pathA := filepath.Join(testDir, "a.json")
+	pathB := filepath.Join(testDir, "b.json")
+	for path, idx := range map[string]*Index{pathA: idxA, pathB: idxB} {
+		data, err := idx.marshal()
+		if err != nil {
+			t.Fatalf("marshal failed: %v", err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("failed to write index: %v", err)
+		}
+	}
+
+	result, err := DiffIndexFiles(pathA, pathB)
+	if err != nil {
+		t.Fatalf("DiffIndexFiles() failed: %v", err)
+	}
+
+	if len(result.Added) != 1 || result.Added[0] != "added.txt" {
+		t.Errorf("expected 'added.txt' to be added, got %v", result.Added)
+	}
+	if len(result.Deleted) != 1 || result.Deleted[0] != "deleted.txt" {
+		t.Errorf("expected 'deleted.txt' to be deleted, got %v", result.Deleted)
+	}
+	if len(result.Modified) != 0 || len(result.RenamedOrMoved) != 0 {
+		t.Errorf("expected no other changes, got %+v", result)
+	}
+
+	if _, err := DiffIndexFiles(pathA, filepath.Join(testDir, "missing.json")); err == nil {
+		t.Error("expected error for missing index file, got nil")
+	}
+}
+
+func TestClone(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/data", IncludeHidden: true})
+	idx.IncludePatterns = []string{"*.go"}
+	idx.EmptyDirs = []string{"empty"}
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"hash1": {{Path: "a.txt", Size: 1}, {Path: "b.txt", Size: 1}},
+	}
+
+	clone := idx.Clone()
+
+	clone.AbsPath = "/other"
+	clone.IncludePatterns[0] = "*.txt"
+	clone.EmptyDirs = append(clone.EmptyDirs, "other")
+	clone.FilesByContentHash["hash1"][0].Path = "modified.txt"
+	clone.FilesByContentHash["hash1"] = append(clone.FilesByContentHash["hash1"], &FileInfo{Path: "c.txt"})
+	clone.FilesByContentHash["hash2"] = []*FileInfo{{Path: "d.txt"}}
+
+	if idx.AbsPath != "/data" {
+		t.Errorf("expected original AbsPath to be unchanged, got %s", idx.AbsPath)
+	}
+	if idx.IncludePatterns[0] != "*.go" {
+		t.Errorf("expected original include patterns to be unchanged, got %v", idx.IncludePatterns)
+	}
+	if len(idx.EmptyDirs) != 1 {
+		t.Errorf("expected original empty directories to be unchanged, got %v", idx.EmptyDirs)
+	}
+	if len(idx.FilesByContentHash) != 1 || len(idx.FilesByContentHash["hash1"]) != 2 {
+		t.Errorf("expected original files to be unchanged, got %v", idx.FilesByContentHash)
+	}
+	if idx.FilesByContentHash["hash1"][0].Path != "a.txt" {
+		t.Errorf("expected original file info to be unchanged, got %s", idx.FilesByContentHash["hash1"][0].Path)
+	}
+	if !clone.IncludeHidden || clone.HashAlgorithm != idx.HashAlgorithm {
+		t.Error("expected scalar fields to be copied")
+	}
+
+	empty := NewIndexWithOptions(IndexOptions{RootPath: "/data"}).Clone()
+	if empty == nil || empty.FilesByContentHash == nil {
+		t.Error("expected non-nil clone of an empty index")
+	}
+}
+
+func TestCompareLeavesIndexUnchanged(t *testing.T) {
+	testDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(testDir, "file.txt"), []byte("content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(testDir, "file.txt"), []byte("modified"), 0644); err != nil {
+		t.Fatalf("failed to modify file: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		result, err := idx.Compare()
+		if err != nil {
+			t.Fatalf("Compare() failed: %v", err)
+		}
+		if len(result.Modified) != 1 {
+			t.Errorf("comparison %d: expected 1 modified file, got %v", i, result.Modified)
+		}
+	}
+
+	if hash, _ := idx.hashOf("file.txt"); hash != computeHash([]byte("content")) {
+		t.Error("expected index to still contain the saved hash after Compare()")
+	}
+}
+
+func TestIntersectionAndDifference(t *testing.T) {
+	local := NewIndexWithOptions(IndexOptions{RootPath: "/local"})
+	local.FilesByContentHash = map[string][]*FileInfo{
+		"hash1": {{Path: "a.txt"}},
+		"hash2": {{Path: "b.txt"}},
+		"hash3": {{Path: "c.txt"}, {Path: "c-copy.txt"}},
+		"hash4": {{Path: "d.txt"}},
+		"hash5": {{Path: "e.txt"}},
+	}
+
+	backup := NewIndexWithOptions(IndexOptions{RootPath: "/backup"})
+	backup.FilesByContentHash = map[string][]*FileInfo{
+		"hash1": {{Path: "backup/a.txt"}},
+		"hash2": {{Path: "backup/b.txt"}},
+		"hash3": {{Path: "backup/c.txt"}},
+		"hash6": {{Path: "backup/f.txt"}},
+	}
+
+	intersection := local.Intersection(backup)
+	if len(intersection.FilesByContentHash) != 3 {
+		t.Errorf("expected 3 hashes in intersection, got %d", len(intersection.FilesByContentHash))
+	}
+	for _, hash := range []string{"hash1", "hash2", "hash3"} {
+		if _, ok := intersection.FilesByContentHash[hash]; !ok {
+			t.Errorf("expected %s in intersection", hash)
+		}
+	}
+	if files := intersection.FilesByContentHash["hash3"]; len(files) != 2 || files[0].Path != "c.txt" {
+		t.Errorf("expected intersection to keep the paths of the index, got %v", files)
+	}
+	if intersection.AbsPath != "/local" {
+		t.Errorf("expected intersection AbsPath /local, got %s", intersection.AbsPath)
+	}
+
+	difference := local.Difference(backup)
+	if len(difference.FilesByContentHash) != 2 {
+		t.Errorf("expected 2 hashes in difference, got %d", len(difference.FilesByContentHash))
+	}
+	for _, hash := range []string{"hash4", "hash5"} {
+		if _, ok := difference.FilesByContentHash[hash]; !ok {
+			t.Errorf("expected %s in difference", hash)
+		}
+	}
+
+	if len(local.FilesByContentHash) != 5 {
+		t.Errorf("expected original index to be unchanged, got %d hashes", len(local.FilesByContentHash))
+	}
+}
+
+func TestCompareTruncated(t *testing.T) {
+	testDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(testDir, "truncated.txt"), []byte("important data"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "modified.txt"), []byte("content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "empty.txt"), []byte{}, 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	if err := os.Truncate(filepath.Join(testDir, "truncated.txt"), 0); err != nil {
+		t.Fatalf("failed to truncate file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "modified.txt"), []byte("new content"), 0644); err != nil {
+		t.Fatalf("failed to modify file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "empty.txt"), []byte("filled"), 0644); err != nil {
+		t.Fatalf("failed to modify file: %v", err)
+	}
+
+	result, err := idx.Compare()
+	if err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+
+	expectedTruncated := []TruncatedFile{{Path: "truncated.txt", OriginalSize: int64(len("important data"))}}
+	if !reflect.DeepEqual(result.Truncated, expectedTruncated) {
+		t.Errorf("expected truncated files %v, got %v", expectedTruncated, result.Truncated)
+	}
+
+	modified := sortedPaths(result.Modified)
+	if !reflect.DeepEqual(modified, []string{"empty.txt", "modified.txt"}) {
+		t.Errorf("expected truncated file not to be reported as modified, got %v", modified)
+	}
+}
+
+func TestComparePermissionsChanged(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not supported on Windows")
+	}
+
+	testDir := t.TempDir()
+	scriptPath := filepath.Join(testDir, "script.sh")
+	if err := os.WriteFile(scriptPath, []byte("echo hello"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.Chmod(scriptPath, 0644); err != nil {
+		t.Fatalf("failed to change permissions: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	idx.IncludePermissions = true
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	if err := os.Chmod(scriptPath, 0755); err != nil {
+		t.Fatalf("failed to change permissions: %v", err)
+	}
+
+	result, err := idx.Compare()
+	if err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+
+	expected := []PermissionChange{{Path: "script.sh", OldMode: 0644, NewMode: 0755}}
+	if !reflect.DeepEqual(result.PermissionsChanged, expected) {
+		t.Errorf("expected permission changes %v, got %v", expected, result.PermissionsChanged)
+	}
+	if len(result.Modified) != 0 {
+		t.Errorf("expected no modified files, got %v", result.Modified)
+	}
+
+	var buf bytes.Buffer
+	result.Print(&buf, ColorNever)
+	if !strings.Contains(buf.String(), "chmod 755 script.sh (was 644)") {
+		t.Errorf("expected a chmod entry, got %q", buf.String())
+	}
+
+	// Without tracking permissions, the change is ignored.
+	idx.IncludePermissions = false
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+	if err := os.Chmod(scriptPath, 0600); err != nil {
+		t.Fatalf("failed to change permissions: %v", err)
+	}
+	if result, err := idx.Compare(); err != nil || result.HasChanges() {
+		t.Errorf("expected no changes without tracking permissions, got %+v (%v)", result, err)
+	}
+}
+
+func TestIndexStream(t *testing.T) {
+	testDir := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "a", "b.txt": "bb"} {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	var buf bytes.Buffer
+	count, err := idx.IndexStream(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("IndexStream failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 files indexed, got %d", count)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 2 file lines and a summary line, got %q", buf.String())
+	}
+
+	for _, line := range lines[:2] {
+		var event map[string]any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("failed to parse %q: %v", line, err)
+		}
+		path, _ := event["path"].(string)
+		if hash, _ := idx.hashOf(path); event["hash"] != hash || hash == "" {
+			t.Errorf("expected the hash of %s in %q", path, line)
+		}
+		for _, key := range []string{"size", "mod_time"} {
+			if _, exists := event[key]; !exists {
+				t.Errorf("expected %q in %q", key, line)
+			}
+		}
+	}
+
+	var summary map[string]map[string]any
+	if err := json.Unmarshal([]byte(lines[2]), &summary); err != nil {
+		t.Fatalf("failed to parse %q: %v", lines[2], err)
+	}
+	if summary["summary"]["count"] != float64(2) {
+		t.Errorf("expected a summary with a count of 2, got %q", lines[2])
+	}
+	if _, exists := summary["summary"]["duration_ms"]; !exists {
+		t.Errorf("expected a duration in the summary, got %q", lines[2])
+	}
+
+	if _, err := os.Stat(filepath.Join(testDir, IndexFile)); err != nil {
+		t.Errorf("expected the index file to be saved: %v", err)
+	}
+}
+
+func TestCompareMtimeOnly(t *testing.T) {
+	testDir := t.TempDir()
+	touchedPath := filepath.Join(testDir, "touched.txt")
+	for path, content := range map[string]string{touchedPath: "same content", filepath.Join(testDir, "other.txt"): "other"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	past := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(touchedPath, past, past); err != nil {
+		t.Fatalf("failed to change times: %v", err)
+	}
+
+	result, err := idx.Compare()
+	if err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.MtimeOnly, []string{"touched.txt"}) {
+		t.Errorf("expected touched.txt with a changed mtime only, got %v", result.MtimeOnly)
+	}
+	if result.HasChanges() {
+		t.Errorf("expected no changes for a changed mtime only, got %+v", result)
+	}
+
+	var buf bytes.Buffer
+	result.Print(&buf, ColorNever)
+	if strings.Contains(buf.String(), "touched.txt") {
+		t.Errorf("expected no mtime only entry without verbose, got %q", buf.String())
+	}
+
+	buf.Reset()
+	result.PrintVerbose(&buf, ColorNever, 0, 0)
+	if !strings.Contains(buf.String(), "~ touched.txt (mtime only)") {
+		t.Errorf("expected an mtime only entry, got %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := idx.CompareStream(context.Background(), &buf); err != nil || buf.Len() != 0 {
+		t.Errorf("expected no events for a changed mtime only, got %q (%v)", buf.String(), err)
+	}
+
+	idx.IgnoreMtime = true
+	if result, err = idx.Compare(); err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+	if len(result.MtimeOnly) != 0 {
+		t.Errorf("expected no mtime only files with IgnoreMtime, got %v", result.MtimeOnly)
+	}
+}
+
+func TestCompareStableCheck(t *testing.T) {
+	testDir := t.TempDir()
+	unstablePath := filepath.Join(testDir, "unstable.bin")
+	if err := os.WriteFile(unstablePath, make([]byte, 1<<20), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "modified.txt"), []byte("content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "modified.txt"), []byte("new content"), 0644); err != nil {
+		t.Fatalf("failed to modify file: %v", err)
+	}
+
+	// Keep overwriting the file in place, without changing its size, while comparing.
+	file, err := os.OpenFile(unstablePath, os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteAt([]byte("modified"), 0); err != nil {
+		t.Fatalf("failed to modify file: %v", err)
+	}
+
+	done := make(chan struct{})
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for i := byte(1); ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := file.WriteAt(bytes.Repeat([]byte{i}, 1<<10), 0); err != nil {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	// Slow down hashing so that the file is written to between the scan and the stability check.
+	idx.RateLimitMBps = 50
+	idx.StableCheck = true
+	result, err := idx.Compare()
+	close(done)
+	<-writerDone
+	if err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.Unstable, []string{"unstable.bin"}) {
+		t.Errorf("expected unstable.bin to be unstable, got %v", result.Unstable)
+	}
+	if !reflect.DeepEqual(result.Modified, []string{"modified.txt"}) {
+		t.Errorf("expected only modified.txt to be modified, got %v", result.Modified)
+	}
+	if result.Consistency {
+		t.Error("expected the comparison to be inconsistent")
+	}
+
+	var buf bytes.Buffer
+	result.Print(&buf, ColorNever)
+	if !strings.Contains(buf.String(), "⚠ 1 files were unstable during scan (concurrent modification detected)") {
+		t.Errorf("expected an unstable files warning, got %q", buf.String())
+	}
+}
+
+func TestNewIndexWithOptionsDefaults(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+
+	if idx.AbsPath != "/data" {
+		t.Errorf("expected AbsPath /data, got %s", idx.AbsPath)
+	}
+	if idx.FilesByContentHash == nil {
+		t.Error("expected FilesByContentHash to be initialized")
+	}
+	if idx.IncludeHidden {
+		t.Error("expected hidden files to be excluded by default")
+	}
+	if idx.HashAlgorithm != DefaultHashAlgorithm {
+		t.Errorf("expected hash algorithm %s, got %s", DefaultHashAlgorithm, idx.HashAlgorithm)
+	}
+	if idx.DebounceDelay != DefaultDebounceDelay {
+		t.Errorf("expected debounce delay %v, got %v", DefaultDebounceDelay, idx.DebounceDelay)
+	}
+	if idx.ExcludePatterns != nil || idx.MaxDepth != 0 || idx.MinSize != 0 || idx.MaxSize != 0 {
+		t.Error("expected no exclude patterns, depth or size limits by default")
+	}
+	if idx.Workers != 0 || idx.workerCount() != runtime.NumCPU() {
+		t.Errorf("expected as many workers as CPUs by default, got %d", idx.workerCount())
+	}
+	if workers := NewIndexWithOptions(IndexOptions{RootPath: "/data", Workers: 3}).workerCount(); workers != 3 {
+		t.Errorf("expected 3 workers, got %d", workers)
+	}
+
+	if !reflect.DeepEqual(NewIndex("/data", true), NewIndexWithOptions(IndexOptions{RootPath: "/data", IncludeHidden: true})) {
+		t.Error("expected NewIndex to be equivalent to NewIndexWithOptions")
+	}
+}
+
+func TestIndexWithOptionsFilters(t *testing.T) {
+	testDir := t.TempDir()
+
+	// The content of each file is its path, so that they have different sizes.
+	files := []string{"main.go", "README.md", "pkg/util.go", "pkg/util_test.go", "pkg/data.json", "vendor/lib/lib.go"}
+	for _, path := range files {
+		absPath := filepath.Join(testDir, path)
+		if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(absPath, []byte(path), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		opts          IndexOptions
+		expectedPaths []string
+	}{
+		{"exclude_name", IndexOptions{ExcludePatterns: []string{"*_test.go", "*.md"}}, []string{"main.go", "pkg/util.go", "pkg/data.json", "vendor/lib/lib.go"}},
+		{"exclude_directory", IndexOptions{ExcludePatterns: []string{"vendor"}}, []string{"main.go", "README.md", "pkg/util.go", "pkg/util_test.go", "pkg/data.json"}},
+		{"max_depth", IndexOptions{MaxDepth: 1}, []string{"main.go", "README.md"}},
+		{"min_size", IndexOptions{MinSize: 13}, []string{"pkg/util_test.go", "pkg/data.json", "vendor/lib/lib.go"}},
+		{"max_size", IndexOptions{MaxSize: 9}, []string{"main.go", "README.md"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.RootPath = testDir
+			idx := NewIndexWithOptions(tt.opts)
+
+			result, err := idx.IndexWithResult()
+			if err != nil {
+				t.Fatalf("IndexWithResult() failed: %v", err)
+			}
+
+			if result.FileCount != len(tt.expectedPaths) {
+				t.Errorf("expected %d files indexed, got %d", len(tt.expectedPaths), result.FileCount)
+			}
+			for _, path := range tt.expectedPaths {
+				if _, found := idx.hashOf(filepath.FromSlash(path)); !found {
+					t.Errorf("expected %q to be indexed", path)
+				}
+			}
+		})
+	}
+}
+
+func TestIndexSizeLimits(t *testing.T) {
+	testDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(testDir, "tiny.txt"), []byte("x"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "medium.txt"), []byte("medium"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	largePath := filepath.Join(testDir, "large.bin")
+	if err := os.WriteFile(largePath, nil, 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.Truncate(largePath, 10<<20); err != nil {
+		t.Fatalf("failed to resize file: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir, MinSize: 2})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("IndexWithResult() failed: %v", err)
+	}
+	if _, found := idx.hashOf("tiny.txt"); found {
+		t.Error("expected the 1-byte file to be excluded with MinSize = 2")
+	}
+
+	idx = NewIndexWithOptions(IndexOptions{RootPath: testDir, MaxSize: 5 << 20})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("IndexWithResult() failed: %v", err)
+	}
+	if _, found := idx.hashOf("large.bin"); found {
+		t.Error("expected the 10 MB file to be excluded with MaxSize = 5 MB")
+	}
+
+	// The limits are saved, so that the comparison scans with the same ones.
+	loaded := NewIndex(testDir, false)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if loaded.MaxSize != 5<<20 {
+		t.Errorf("expected MaxSize to be saved, got %d", loaded.MaxSize)
+	}
+	comparison, err := loaded.Compare()
+	if err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+	if comparison.TotalChanges() != 0 {
+		t.Errorf("expected no changes with the saved size limits, got %+v", comparison)
+	}
+}
+
+func TestIndexLongPaths(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("long paths are only limited on Windows")
+	}
+
+	testDir := t.TempDir()
+
+	// Nest directories until the path of the file exceeds the 260 characters limit.
+	deepDir := testDir
+	for len(deepDir) < 300 {
+		deepDir = filepath.Join(deepDir, "nested_directory")
+	}
+	if err := os.MkdirAll(deepDir, 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(deepDir, "file.txt"), []byte("content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	result, err := idx.IndexWithResult()
+	if err != nil {
+		t.Fatalf("IndexWithResult() failed: %v", err)
+	}
+
+	if len(result.Errors) != 0 {
+		t.Errorf("expected no errors, got %v", result.Errors)
+	}
+	if result.FileCount != 1 {
+		t.Errorf("expected 1 file indexed, got %d", result.FileCount)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	newIndex := func(files map[string][]*FileInfo) *Index {
+		idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+		idx.FilesByContentHash = files
+		return idx
+	}
+
+	idx := newIndex(map[string][]*FileInfo{
+		"hash1": {{Path: "a.txt"}, {Path: "b.txt"}},
+		"hash2": {{Path: "c.txt"}},
+	})
+	checksum := idx.Checksum()
+
+	reordered := newIndex(map[string][]*FileInfo{
+		"hash2": {{Path: "c.txt"}},
+		"hash1": {{Path: "b.txt"}, {Path: "a.txt"}},
+	})
+	if reordered.Checksum() != checksum {
+		t.Error("expected checksum not to depend on the order of the files")
+	}
+
+	added := newIndex(map[string][]*FileInfo{
+		"hash1": {{Path: "a.txt"}, {Path: "b.txt"}},
+		"hash2": {{Path: "c.txt"}},
+		"hash3": {{Path: "d.txt"}},
+	})
+	if added.Checksum() == checksum {
+		t.Error("expected checksum to change when a file is added")
+	}
+
+	renamed := newIndex(map[string][]*FileInfo{
+		"hash1": {{Path: "a.txt"}, {Path: "b.txt"}},
+		"hash2": {{Path: "renamed.txt"}},
+	})
+	if renamed.Checksum() == checksum {
+		t.Error("expected checksum to change when a file is renamed")
+	}
+
+	if len(checksum) != 64 {
+		t.Errorf("expected a SHA-256 hex checksum, got %q", checksum)
+	}
+}
+
+func TestFindDuplicatesMinSize(t *testing.T) {
+	newFiles := func(prefix string, n int) []*FileInfo {
+		files := []*FileInfo{}
+		for i := 0; i < n; i++ {
+			files = append(files, &FileInfo{Path: fmt.Sprintf("%s%d.txt", prefix, i), Size: 1})
+		}
+		return files
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"hash1": newFiles("unique", 1),
+		"hash2": newFiles("double", 2),
+		"hash3": newFiles("triple", 3),
+		"hash5": newFiles("five", 5),
+	}
+
+	tests := []struct {
+		name           string
+		minSize        int
+		maxSize        int
+		expectedHashes []string
+	}{
+		{"default", 2, 0, []string{"hash2", "hash3", "hash5"}},
+		{"min_3", 3, 0, []string{"hash3", "hash5"}},
+		{"max_3", 2, 3, []string{"hash2", "hash3"}},
+		{"min_and_max", 3, 4, []string{"hash3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			duplicates := filterGroupsBySize(idx.FindAllDuplicates(), tt.minSize, tt.maxSize)
+
+			hashes := []string{}
+			for hash := range duplicates {
+				hashes = append(hashes, hash)
+			}
+			sort.Strings(hashes)
+			if !reflect.DeepEqual(hashes, tt.expectedHashes) {
+				t.Errorf("expected groups %v, got %v", tt.expectedHashes, hashes)
+			}
+		})
+	}
+
+	if duplicates := idx.FindDuplicatesMinSize(3); len(duplicates) != 2 {
+		t.Errorf("expected FindDuplicatesMinSize(3) to return 2 groups, got %d", len(duplicates))
+	}
+}
+
+func TestWalkFiles(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"hash2": {{Path: "z.txt"}, {Path: "b.txt"}},
+		"hash1": {{Path: "y.txt"}},
+		"hash3": {{Path: "c.txt"}, {Path: "a.txt"}},
+	}
+
+	var visited []string
+	err := idx.WalkFiles(func(fi *FileInfo, hash string) error {
+		visited = append(visited, hash+":"+fi.Path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkFiles() failed: %v", err)
+	}
+
+	expected := []string{"hash1:y.txt", "hash2:b.txt", "hash2:z.txt", "hash3:a.txt", "hash3:c.txt"}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("expected iteration order %v, got %v", expected, visited)
+	}
+	if idx.FilesByContentHash["hash2"][0].Path != "z.txt" {
+		t.Error("expected WalkFiles not to reorder the index")
+	}
+
+	stopErr := errors.New("stop")
+	count := 0
+	err = idx.WalkFiles(func(fi *FileInfo, hash string) error {
+		count++
+		if count == 2 {
+			return stopErr
+		}
+		return nil
+	})
+	if err != stopErr {
+		t.Errorf("expected WalkFiles to return the callback error, got %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected iteration to stop after the error, got %d calls", count)
+	}
+
+	if paths := idx.AllPaths(); !reflect.DeepEqual(paths, []string{"a.txt", "b.txt", "c.txt", "y.txt", "z.txt"}) {
+		t.Errorf("unexpected AllPaths() result: %v", paths)
+	}
+
+	if fi, hash := idx.FindByPath("b.txt"); fi == nil || fi.Path != "b.txt" || hash != "hash2" {
+		t.Errorf("expected FindByPath to find b.txt in hash2, got %v, %s", fi, hash)
+	}
+	if fi, _ := idx.FindByPath("missing.txt"); fi != nil {
+		t.Errorf("expected FindByPath to return nil for a missing path, got %v", fi)
+	}
+
+	duplicates := idx.FindAllDuplicates()
+	if len(duplicates) != 2 || duplicates["hash2"][0].Path != "b.txt" || duplicates["hash3"][0].Path != "a.txt" {
+		t.Errorf("expected the duplicate groups sorted by path, got %v", duplicates)
+	}
+	if idx.FilesByContentHash["hash2"][0].Path != "z.txt" {
+		t.Error("expected FindAllDuplicates not to reorder the index")
+	}
+}
+
+func TestIndexWithContextCancelled(t *testing.T) {
+	testDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(testDir, "file.txt"), []byte("content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled error, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(testDir, IndexFile)); !os.IsNotExist(err) {
+		t.Error("expected index file not to be saved after cancellation")
+	}
+}
+
+func TestSortedFilesBySizeAndMtime(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"hash1": {{Path: "medium.txt", Size: 100, ModTime: start.Add(2 * time.Hour)}},
+		"hash2": {{Path: "large.bin", Size: 5000, ModTime: start}},
+		"hash3": {{Path: "small.txt", Size: 1, ModTime: start.Add(time.Hour)}, {Path: "small-copy.txt", Size: 1, ModTime: start.Add(3 * time.Hour)}},
+	}
+
+	paths := func(files []*FileInfo) []string {
+		result := []string{}
+		for _, file := range files {
+			result = append(result, file.Path)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		files    []*FileInfo
+		expected []string
+	}{
+		{"size_descending", idx.SortedFilesBySize(true), []string{"large.bin", "medium.txt", "small-copy.txt", "small.txt"}},
+		{"size_ascending", idx.SortedFilesBySize(false), []string{"small-copy.txt", "small.txt", "medium.txt", "large.bin"}},
+		{"mtime_descending", idx.SortedFilesByMtime(true), []string{"small-copy.txt", "medium.txt", "small.txt", "large.bin"}},
+		{"mtime_ascending", idx.SortedFilesByMtime(false), []string{"large.bin", "small.txt", "medium.txt", "small-copy.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := paths(tt.files); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestIsFresh(t *testing.T) {
+	testDir := t.TempDir()
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+
+	if _, err := idx.IsFresh(time.Hour); !errors.Is(err, errIndexNotFound) {
+		t.Errorf("expected errIndexNotFound without index, got %v", err)
+	}
+
+	updatedAt := time.Now().Add(-10 * time.Minute)
+	data := fmt.Sprintf(`{"updated_at": %q, "files_by_content_hash": {}}`, updatedAt.Format(time.RFC3339Nano))
+	if err := os.WriteFile(filepath.Join(testDir, "bff.json"), []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write index: %v", err)
+	}
+
+	tests := []struct {
+		maxAge   time.Duration
+		expected bool
+	}{
+		{time.Hour, true},
+		{time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		fresh, err := idx.IsFresh(tt.maxAge)
+		if err != nil {
+			t.Fatalf("IsFresh(%v) failed: %v", tt.maxAge, err)
+		}
+		if fresh != tt.expected {
+			t.Errorf("IsFresh(%v): expected %v, got %v", tt.maxAge, tt.expected, fresh)
+		}
+	}
+	if !idx.UpdatedAt.Equal(updatedAt) {
+		t.Errorf("expected UpdatedAt %v, got %v", updatedAt, idx.UpdatedAt)
+	}
+}
+
+func TestIndexWithHashCache(t *testing.T) {
+	originalDir := t.TempDir()
+	copyDir := t.TempDir()
+	modTime := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	files := map[string]string{"a.txt": "a", "sub/b.txt": "content b", "sub/c.txt": "longer content c"}
+	for _, dir := range []string{originalDir, copyDir} {
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("failed to create directory: %v", err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("failed to create file: %v", err)
+			}
+			if err := os.Chtimes(path, modTime, modTime); err != nil {
+				t.Fatalf("failed to set modification time: %v", err)
+			}
+		}
+	}
+
+	original := NewIndexWithOptions(IndexOptions{RootPath: originalDir})
+	if _, err := original.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: copyDir})
+	result, err := idx.IndexWithHashCacheContext(context.Background(), original)
+	if err != nil {
+		t.Fatalf("IndexWithHashCacheContext failed: %v", err)
+	}
+	if result.FileCount != len(files) || result.Reused != len(files) {
+		t.Errorf("expected all %d files reused from the cache, got %d of %d", len(files), result.Reused, result.FileCount)
+	}
+	if result.BytesRead != 0 {
+		t.Errorf("expected no file hashed, got %d bytes read", result.BytesRead)
+	}
+	for name := range files {
+		path := filepath.FromSlash(name)
+		hash, found := idx.hashOf(path)
+		if originalHash, _ := original.hashOf(path); !found || hash != originalHash {
+			t.Errorf("expected the hash of %s from the cache", name)
+		}
+	}
+
+	// A modified file is hashed again.
+	if err := os.WriteFile(filepath.Join(copyDir, "a.txt"), []byte("modified"), 0644); err != nil {
+		t.Fatalf("failed to modify file: %v", err)
+	}
+	reused, err := idx.IndexWithHashCache(original)
+	if err != nil {
+		t.Fatalf("IndexWithHashCache failed: %v", err)
+	}
+	if reused != len(files)-1 {
+		t.Errorf("expected %d files reused from the cache, got %d", len(files)-1, reused)
+	}
+	if hash, _ := idx.hashOf("a.txt"); hash != computeHash([]byte("modified")) {
+		t.Errorf("expected the hash of the modified content for a.txt, got %s", hash)
+	}
+
+	other := NewIndexWithOptions(IndexOptions{RootPath: originalDir, HashAlgorithm: HashXXHash})
+	if _, err := idx.IndexWithHashCache(other); err == nil {
+		t.Error("expected error for a cache using another hash algorithm")
+	}
+}
+
+func TestVerifyAfter(t *testing.T) {
+	testDir := t.TempDir()
+	for name, content := range map[string]string{"good.txt": "good content", "bad.txt": "bad content"} {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	// The second read of bad.txt returns other data, like a read error going unnoticed.
+	reads := make(map[string]int)
+	openFile = func(path string) (io.ReadCloser, error) {
+		reads[filepath.Base(path)]++
+		if filepath.Base(path) == "bad.txt" && reads["bad.txt"] == 2 {
+			return io.NopCloser(strings.NewReader("bad c\x00\x00\x00\x00\x00\x00")), nil
+		}
+		return os.Open(path)
+	}
+	defer func() {
+		openFile = func(path string) (io.ReadCloser, error) { return os.Open(path) }
+	}()
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	idx.VerifyAfter = true
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	if reads["good.txt"] != 2 || reads["bad.txt"] != 2 {
+		t.Errorf("expected each file to be read twice, got %v", reads)
+	}
+	suspect := idx.SuspectFiles()
+	if len(suspect) != 1 || suspect[0].Path != "bad.txt" {
+		t.Fatalf("expected bad.txt to be suspect, got %v", suspect)
+	}
+
+	loaded := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("failed to load index: %v", err)
+	}
+	if suspect := loaded.SuspectFiles(); len(suspect) != 1 || suspect[0].Path != "bad.txt" {
+		t.Errorf("expected the suspect file to be saved in the index, got %v", suspect)
+	}
+
+	if ok, err := idx.VerifyHash(filepath.Join(testDir, "good.txt"), computeHash([]byte("good content"))); err != nil || !ok {
+		t.Errorf("expected the hash of good.txt to be verified, got %v (%v)", ok, err)
+	}
+	if _, err := idx.VerifyHash(filepath.Join(testDir, "missing.txt"), ""); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
+
+func TestIndexSymlinkModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks requires privileges on Windows")
+	}
+
+	testDir := t.TempDir()
+	externalDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(testDir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{
+		filepath.Join(testDir, "a.txt"):        "a",
+		filepath.Join(testDir, "sub", "b.txt"): "b",
+		filepath.Join(externalDir, "c.txt"):    "c",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"file.lnk": "a.txt",
+		"loop":     ".", // Self-referential.
+		"sublink":  "sub",
+		"external": externalDir,
+	} {
+		if err := os.Symlink(target, filepath.Join(testDir, link)); err != nil {
+			t.Fatalf("failed to create symlink: %v", err)
+		}
+	}
+
+	tests := []struct {
+		mode     string
+		expected []string
+	}{
+		{SymlinkRecord, []string{"a.txt", "file.lnk", filepath.Join("sub", "b.txt")}},
+		{SymlinkSkip, []string{"a.txt", filepath.Join("sub", "b.txt")}},
+		{SymlinkFollow, []string{"a.txt", filepath.Join("external", "c.txt"), "file.lnk", filepath.Join("sub", "b.txt")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+			idx.SymlinkMode = tt.mode
+			if _, err := idx.IndexWithResult(); err != nil {
+				t.Fatalf("indexing failed: %v", err)
+			}
+
+			if paths := sortedPaths(idx.AllPaths()); !reflect.DeepEqual(paths, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, paths)
+			}
+		})
+	}
+}
+
+func TestFilteredView(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"h1": {{Path: "big.jpg", Size: 5000}, {Path: "copy-big.jpg", Size: 5000}},
+		"h2": {{Path: "small.jpg", Size: 10}},
+		"h3": {{Path: "big.png", Size: 8000}},
+		"h4": {{Path: "small.txt", Size: 20}},
+	}
+
+	isJPEG := func(fi *FileInfo, hash string) bool { return filepath.Ext(fi.Path) == ".jpg" }
+	isLarge := func(fi *FileInfo, hash string) bool { return fi.Size >= 1000 }
+
+	view := idx.FilteredView(isJPEG).FilteredView(isLarge)
+	if paths := sortedPaths(view.AllPaths()); !reflect.DeepEqual(paths, []string{"big.jpg", "copy-big.jpg"}) {
+		t.Errorf("expected only the large JPEG files, got %v", paths)
+	}
+	if len(view.FilesByContentHash) != 1 || len(view.FilesByContentHash["h1"]) != 2 {
+		t.Errorf("expected the files to keep their hash, got %v", view.FilesByContentHash)
+	}
+	if view.AbsPath != idx.AbsPath || view.HashAlgorithm != idx.HashAlgorithm {
+		t.Errorf("expected the settings of the index to be kept")
+	}
+	if idx.FileCount() != 5 {
+		t.Errorf("expected the index to be unchanged, got %d files", idx.FileCount())
+	}
+
+	empty := idx.FilteredView(func(fi *FileInfo, hash string) bool { return false })
+	if empty == nil || empty.FilesByContentHash == nil || empty.FileCount() != 0 {
+		t.Errorf("expected an empty index, got %+v", empty)
+	}
+}
+
+func TestSetHashAlgorithm(t *testing.T) {
+	testDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(testDir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := idx.SetHashAlgorithm("crc32"); err == nil {
+		t.Error("expected an error for an unsupported hash algorithm")
+	}
+	if err := idx.SetHashAlgorithm(HashBLAKE2b); err != nil {
+		t.Fatalf("SetHashAlgorithm failed: %v", err)
+	}
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	loaded := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.HashAlgorithm != HashBLAKE2b {
+		t.Errorf("expected the hash algorithm to be saved, got %s", loaded.HashAlgorithm)
+	}
+
+	indexPath := filepath.Join(testDir, IndexFile)
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = []byte(strings.Replace(string(data), `"hash_algorithm": "blake2b"`, `"hash_algorithm": "crc32"`, 1))
+	if err := os.WriteFile(indexPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewIndexWithOptions(IndexOptions{RootPath: testDir}).Load(); err == nil || !strings.Contains(err.Error(), "crc32") {
+		t.Errorf("expected an error for the unsupported hash algorithm of the index, got %v", err)
+	}
+}
+
+func TestLoadFrom(t *testing.T) {
+	testDir := t.TempDir()
+	snapshotsDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(testDir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(testDir, IndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	baselinePath := filepath.Join(snapshotsDir, "weekly.json")
+	if err := os.WriteFile(baselinePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(testDir, "b.txt"), []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewIndexWithOptions(IndexOptions{RootPath: testDir}).IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	baseline := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := baseline.LoadFrom(baselinePath); err != nil {
+		t.Fatalf("LoadFrom failed: %v", err)
+	}
+	comparison, err := baseline.Compare()
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if !reflect.DeepEqual(comparison.Added, []string{"b.txt"}) || comparison.TotalChanges() != 1 {
+		t.Errorf("expected b.txt added since the baseline, got %+v", comparison)
+	}
+
+	current := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := current.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if comparison, err := current.Compare(); err != nil || comparison.HasChanges() {
+		t.Errorf("expected no changes since the index of the directory, got %+v (%v)", comparison, err)
+	}
+
+	// The directory is kept even if the index file was written for another one.
+	other := NewIndexWithOptions(IndexOptions{RootPath: t.TempDir()})
+	if err := other.LoadFrom(baselinePath); err != nil {
+		t.Fatalf("LoadFrom failed: %v", err)
+	}
+	if other.AbsPath == testDir {
+		t.Errorf("expected the directory of the index to be kept, got %s", other.AbsPath)
+	}
+
+	if err := baseline.LoadFrom(filepath.Join(snapshotsDir, "missing.json")); err == nil {
+		t.Error("expected error for a missing index file")
+	}
+}
+
+func TestScanWorkers(t *testing.T) {
+	testDir := t.TempDir()
+	for i := 0; i < 50; i++ {
+		path := filepath.Join(testDir, fmt.Sprintf("dir%d", i%5), fmt.Sprintf("file%d.txt", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(fmt.Sprintf("content %d", i%20)), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	sequential := NewIndexWithOptions(IndexOptions{RootPath: testDir, Workers: 1})
+	if _, err := sequential.IndexWithResult(); err != nil {
+		t.Fatalf("indexing with 1 worker failed: %v", err)
+	}
+
+	parallel := NewIndexWithOptions(IndexOptions{RootPath: testDir, Workers: 8})
+	result, err := parallel.IndexWithResult()
+	if err != nil {
+		t.Fatalf("indexing with 8 workers failed: %v", err)
+	}
+	if result.FileCount != 50 {
+		t.Errorf("expected 50 files indexed, got %d", result.FileCount)
+	}
+	if !reflect.DeepEqual(sequential.FilesByContentHash, parallel.FilesByContentHash) {
+		t.Error("expected the same index with 1 and 8 workers")
+	}
+}
+
+func BenchmarkScan(b *testing.B) {
+	testDir := b.TempDir()
+	for i := 0; i < 500; i++ {
+		path := filepath.Join(testDir, fmt.Sprintf("file%d.bin", i))
+		if err := os.WriteFile(path, bytes.Repeat([]byte{byte(i)}, 64*1024), 0644); err != nil {
+			b.Fatalf("failed to create test file: %v", err)
+		}
+	}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				idx := NewIndexWithOptions(IndexOptions{RootPath: testDir, Workers: workers})
+				if _, err := idx.scan(context.Background()); err != nil {
+					b.Fatalf("scan failed: %v", err)
+				}
+			}
+		})
+	}
+}
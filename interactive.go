@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// previewLines is the number of lines shown of the content of text files.
+const previewLines = 3
+
+// previewMaxBytes bounds the amount of data read from a file to preview it, and to tell whether it is text.
+const previewMaxBytes = 4096
+
+// previewMaxWidth is the number of characters shown of each line of the previews.
+const previewMaxWidth = 80
+
+// Special answers of the interactive dedup session, besides the number of the file to keep.
+const (
+	keepAllChoice  = -1 // Keep all the files of the group.
+	keepNoneChoice = -2 // Skip the group without choosing.
+	quitChoice     = -3 // Stop the session.
+)
+
+// filePreview displays the candidates of a group of duplicates for the user to choose the one to keep.
+type filePreview struct {
+	colors *colorizer
+}
+
+// render returns a table of the files numbered from 1, with their size, modification time and path, the header in bold.
+// With showContent, the first lines of the text files, read from root, are shown below them.
+func (p *filePreview) render(files []*FileInfo, root string, showContent bool) string {
+	sizes := make([]string, len(files))
+	times := make([]string, len(files))
+	sizeWidth, timeWidth := len("Size"), len("Modified")
+	for i, file := range files {
+		sizes[i] = FormatSize(file.Size)
+		sizeWidth = max(sizeWidth, len(sizes[i]))
+		times[i] = file.ModTime.Format(time.RFC3339)
+		timeWidth = max(timeWidth, len(times[i]))
+	}
+	numberWidth := max(len("#"), len(strconv.Itoa(len(files))))
+
+	var sb strings.Builder
+	header := fmt.Sprintf("%*s  %*s  %-*s  %s", numberWidth, "#", sizeWidth, "Size", timeWidth, "Modified", "Path")
+	sb.WriteString(p.colors.colorize(header, styleBold) + "\n")
+
+	for i, file := range files {
+		fmt.Fprintf(&sb, "%*d  %*s  %-*s  %s\n", numberWidth, i+1, sizeWidth, sizes[i], timeWidth, times[i], file.Path)
+		if !showContent {
+			continue
+		}
+
+		lines, ok := readTextPreview(filepath.Join(root, file.Path))
+		if !ok {
+			continue
+		}
+		for _, line := range lines {
+			sb.WriteString(strings.Repeat(" ", numberWidth+2) + p.colors.colorize("│ "+line, styleDim) + "\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// readTextPreview returns the first lines of a file, truncated to previewMaxWidth characters,
+// or false if it cannot be read or is not text.
+func readTextPreview(path string) ([]string, bool) {
+	file, err := os.Open(longPath(path))
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	data := make([]byte, previewMaxBytes)
+	n, err := io.ReadFull(file, data)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, false
+	}
+	data = data[:n]
+
+	// A multi-byte character may be cut at the end of a full buffer.
+	for i := 0; n == previewMaxBytes && i < utf8.UTFMax && !utf8.Valid(data); i++ {
+		data = data[:len(data)-1]
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return nil, false
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > previewLines {
+		lines = lines[:previewLines]
+	}
+	for i, line := range lines {
+		if runes := []rune(line); len(runes) > previewMaxWidth {
+			lines[i] = string(runes[:previewMaxWidth]) + "…"
+		}
+	}
+	return lines, true
+}
+
+// parseKeepChoice returns the 0-based number of the file to keep out of count files, or keepAllChoice for "a",
+// keepNoneChoice for "n" and quitChoice for "q".
+func parseKeepChoice(input string, count int) (int, error) {
+	switch answer := strings.ToLower(strings.TrimSpace(input)); answer {
+	case "a":
+		return keepAllChoice, nil
+	case "n":
+		return keepNoneChoice, nil
+	case "q":
+		return quitChoice, nil
+	default:
+		n, err := strconv.Atoi(answer)
+		if err != nil || n < 1 || n > count {
+			return 0, fmt.Errorf("expected a number between 1 and %d, 'a', 'n' or 'q', got '%s'", count, strings.TrimSpace(input))
+		}
+		return n - 1, nil
+	}
+}
+
+// DedupSessionResult contains the outcome of an interactive dedup session.
+type DedupSessionResult struct {
+	Deleted      int   // Files deleted, or that would be deleted with DryRun.
+	FreedBytes   int64 // Space used by the deleted files.
+	Skipped      int   // Groups skipped or kept entirely.
+	DeletedPaths []string
+}
+
+// InteractiveKeep shows each group of duplicates to out and asks on in which file to keep, deleting the other ones.
+// The user can also keep all the files of a group, skip it or quit. The index file is saved once the session ends
+// if files were deleted. With DryRun, the files that would be deleted are reported but not deleted.
+// The index must be loaded before calling this method.
+func (idx *Index) InteractiveKeep(in io.Reader, out io.Writer, preview *filePreview, showContent bool) (*DedupSessionResult, error) {
+	if idx.isArchive() {
+		return nil, fmt.Errorf("cannot delete the files of %s: %w", idx.AbsPath, errArchiveIndex)
+	}
+
+	verb := "Deleted"
+	if idx.DryRun {
+		verb = "Would delete"
+	}
+
+	result := &DedupSessionResult{DeletedPaths: []string{}}
+	reader := bufio.NewReader(in)
+	groups := idx.SortedDuplicateGroups()
+
+groups:
+	for i, group := range groups {
+		fmt.Fprintf(out, "\nGroup %d of %d (%s wasted):\n", i+1, len(groups), FormatSize(group.WastedBytes))
+		fmt.Fprint(out, preview.render(group.Files, idx.AbsPath, showContent))
+
+		choice := 0
+		for {
+			fmt.Fprintf(out, "Keep which file? [1-%d, a = keep all, n = skip, q = quit] ", len(group.Files))
+			line, err := reader.ReadString('\n')
+			if err == io.EOF && line == "" {
+				fmt.Fprintln(out)
+				break groups
+			}
+			if err != nil && err != io.EOF {
+				return result, fmt.Errorf("failed to read answer: %w", err)
+			}
+
+			choice, err = parseKeepChoice(line, len(group.Files))
+			if err == nil {
+				break
+			}
+			fmt.Fprintf(out, "Invalid answer: %v\n", err)
+		}
+
+		switch choice {
+		case quitChoice:
+			break groups
+		case keepAllChoice, keepNoneChoice:
+			result.Skipped++
+			continue
+		}
+
+		for j, file := range group.Files {
+			if j == choice {
+				continue
+			}
+			if !idx.DryRun {
+				if err := os.Remove(longPath(filepath.Join(idx.AbsPath, file.Path))); err != nil {
+					// The files deleted so far are removed from the index.
+					if saveErr := idx.saveAfterSession(result); saveErr != nil {
+						return result, fmt.Errorf("failed to delete %s: %w (%v)", file.Path, err, saveErr)
+					}
+					return result, fmt.Errorf("failed to delete %s: %w", file.Path, err)
+				}
+			}
+			fmt.Fprintf(out, "%s %s\n", verb, file.Path)
+			result.Deleted++
+			result.FreedBytes += file.Size
+			result.DeletedPaths = append(result.DeletedPaths, file.Path)
+		}
+	}
+
+	return result, idx.saveAfterSession(result)
+}
+
+// saveAfterSession removes the deleted files of a dedup session from the index and saves it, unless nothing was deleted.
+func (idx *Index) saveAfterSession(result *DedupSessionResult) error {
+	if idx.DryRun || len(result.DeletedPaths) == 0 {
+		return nil
+	}
+	for _, path := range result.DeletedPaths {
+		idx.removePath(path)
+	}
+	return idx.save()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilePreviewRender(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "notes.txt"), []byte("line 1\r\nline 2\nline 3\nline 4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "image.bin"), []byte{0x89, 'P', 'N', 'G', 0, 1}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	files := []*FileInfo{
+		{Path: "notes.txt", Size: 28, ModTime: modTime},
+		{Path: "image.bin", Size: 1536, ModTime: modTime.Add(time.Hour)},
+	}
+
+	tests := []struct {
+		name        string
+		color       bool
+		showContent bool
+		expected    string
+	}{
+		{
+			name: "metadata",
+			expected: "" +
+				"#    Size  Modified              Path\n" +
+				"1    28 B  2024-01-02T15:04:05Z  notes.txt\n" +
+				"2  1.5 KB  2024-01-02T16:04:05Z  image.bin\n",
+		},
+		{
+			name:        "content",
+			showContent: true,
+			expected: "" +
+				"#    Size  Modified              Path\n" +
+				"1    28 B  2024-01-02T15:04:05Z  notes.txt\n" +
+				"   │ line 1\n" +
+				"   │ line 2\n" +
+				"   │ line 3\n" +
+				"2  1.5 KB  2024-01-02T16:04:05Z  image.bin\n",
+		},
+		{
+			name:        "colors",
+			color:       true,
+			showContent: true,
+			expected: "" +
+				"\x1b[1m#    Size  Modified              Path\x1b[0m\n" +
+				"1    28 B  2024-01-02T15:04:05Z  notes.txt\n" +
+				"   \x1b[2m│ line 1\x1b[0m\n" +
+				"   \x1b[2m│ line 2\x1b[0m\n" +
+				"   \x1b[2m│ line 3\x1b[0m\n" +
+				"2  1.5 KB  2024-01-02T16:04:05Z  image.bin\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preview := &filePreview{colors: &colorizer{enabled: tt.color}}
+			if got := preview.render(files, root, tt.showContent); got != tt.expected {
+				t.Errorf("unexpected rendering:\n%s\nexpected:\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseKeepChoice(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+		wantErr  bool
+	}{
+		{input: "1\n", expected: 0},
+		{input: " 3 ", expected: 2},
+		{input: "a", expected: keepAllChoice},
+		{input: "N\n", expected: keepNoneChoice},
+		{input: "q", expected: quitChoice},
+		{input: "0", wantErr: true},
+		{input: "4", wantErr: true},
+		{input: "yes", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		choice, err := parseKeepChoice(tt.input, 3)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: expected error %v, got %v", tt.input, tt.wantErr, err)
+			continue
+		}
+		if err == nil && choice != tt.expected {
+			t.Errorf("%q: expected %d, got %d", tt.input, tt.expected, choice)
+		}
+	}
+}
+
+func TestInteractiveKeep(t *testing.T) {
+	testDir := t.TempDir()
+	for name, content := range map[string]string{"a1": "a", "a2": "a", "a3": "a", "bb1": "bb", "bb2": "bb"} {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	// The group wasting the most space comes first: bb, then a, where the invalid answer is asked again.
+	in := strings.NewReader("n\nwhat\n2\n")
+	var out bytes.Buffer
+	result, err := idx.InteractiveKeep(in, &out, &filePreview{colors: &colorizer{}}, false)
+	if err != nil {
+		t.Fatalf("InteractiveKeep failed: %v", err)
+	}
+
+	if result.Deleted != 2 || result.Skipped != 1 || !reflect.DeepEqual(result.DeletedPaths, []string{"a1", "a3"}) {
+		t.Errorf("expected a1 and a3 to be deleted and a group skipped, got %+v", result)
+	}
+	if !strings.Contains(out.String(), "Invalid answer") {
+		t.Errorf("expected the invalid answer to be reported, got:\n%s", out.String())
+	}
+
+	loaded := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if expected := []string{"a2", "bb1", "bb2"}; !reflect.DeepEqual(loaded.AllPaths(), expected) {
+		t.Errorf("expected the index to contain %v, got %v", expected, loaded.AllPaths())
+	}
+	if _, err := os.Stat(filepath.Join(testDir, "a1")); !os.IsNotExist(err) {
+		t.Errorf("expected a1 to be deleted, got %v", err)
+	}
+}
@@ -1,12 +1,126 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
-var validCommands = []string{"index", "compare", "duplicates", "find"}
+// Version is the version of bff, which can be set when building with -ldflags "-X main.Version=<version>".
+var Version = "dev"
+
+var validCommands = []string{"index", "compare", "duplicates", "find", "diff", "watch", "gc", "name-conflicts", "checksum", "show-diff", "diff-indexes", "top-changed", "merge", "restore", "fingerprint", "diagnose", "index-archive", "import", "benchmark", "stats", "extract-subtree", "rename-duplicates", "apply-patch", "verify-against"}
+
+// flagCommands lists, for each flag, the commands it is allowed with.
+var flagCommands = map[string][]string{
+	"--hidden":                  {"index", "checksum", "index-archive"},
+	"--include":                 {"index", "checksum", "index-archive"},
+	"--hash":                    {"index", "checksum", "index-archive", "import"},
+	"--gitignore":               {"index", "checksum"},
+	"--include-empty-dirs":      {"index"},
+	"--xdg":                     {"index", "compare", "duplicates", "find", "diff", "watch", "gc", "name-conflicts", "checksum", "show-diff", "diff-indexes", "fingerprint", "restore", "diagnose", "index-archive", "import", "stats", "extract-subtree", "rename-duplicates", "verify-against"},
+	"--by-name":                 {"find"},
+	"--skip-empty":              {"duplicates", "rename-duplicates"},
+	"--within":                  {"duplicates"},
+	"--overlap":                 {"duplicates"},
+	"--limit":                   {"duplicates"},
+	"--offset":                  {"duplicates"},
+	"--format":                  {"compare", "import"},
+	"--emit-events":             {"compare"},
+	"--save-diff":               {"compare"},
+	"--temp-dir":                {"index", "watch", "gc"},
+	"--snapshots-dir":           {"top-changed", "duplicates"},
+	"--top":                     {"top-changed"},
+	"--tag":                     {"index"},
+	"--backup":                  {"restore"},
+	"--files":                   {"restore"},
+	"--dry-run":                 {"restore", "rename-duplicates", "duplicates"},
+	"--min-group-size":          {"duplicates"},
+	"--max-group-size":          {"duplicates"},
+	"--include-size":            {"find", "duplicates"},
+	"--include-mtime":           {"find", "duplicates"},
+	"--color":                   {"compare", "show-diff", "diff-indexes", "duplicates"},
+	"--no-color":                {"compare", "show-diff", "diff-indexes", "duplicates"},
+	"--rate-limit":              {"index", "checksum", "compare", "watch", "gc"},
+	"--modified-after":          {"find"},
+	"--modified-before":         {"find"},
+	"--root":                    {"import"},
+	"--same-directory":          {"duplicates"},
+	"--stable-check":            {"compare"},
+	"--include-permissions":     {"index"},
+	"--recursive-from":          {"find"},
+	"--index-suffix":            {"index", "compare", "duplicates", "find", "diff", "watch", "gc", "name-conflicts", "fingerprint", "restore", "diagnose", "import", "stats", "extract-subtree", "rename-duplicates", "verify-against"},
+	"--search-parents":          {"compare", "duplicates", "find", "diff", "name-conflicts", "fingerprint", "diagnose", "stats", "extract-subtree"},
+	"--max-depth":               {"compare", "duplicates", "find", "diff", "name-conflicts", "fingerprint", "diagnose", "stats", "extract-subtree"},
+	"--by-size":                 {"stats"},
+	"--json-stream":             {"index", "duplicates"},
+	"--with-age":                {"duplicates"},
+	"--progress-interval":       {"index"},
+	"--suffix":                  {"rename-duplicates"},
+	"--keep":                    {"rename-duplicates", "duplicates"},
+	"--track-renames-within":    {"compare"},
+	"--only-changed":            {"index"},
+	"--output-script":           {"duplicates"},
+	"--output-patch":            {"compare"},
+	"--interactive-keep":        {"duplicates"},
+	"--show-preview":            {"duplicates"},
+	"--count-only":              {"find"},
+	"--skip-if-unchanged-since": {"index"},
+	"--notes":                   {"compare"},
+	"--reuse-hashes-from":       {"index"},
+	"--expected":                {"compare"},
+	"--export-keep-list":        {"duplicates"},
+	"--export-delete-list":      {"duplicates"},
+	"--changed-since":           {"find"},
+	"--verify-after":            {"index"},
+	"--top-dirs":                {"stats"},
+	"--symlinks":                {"index"},
+	"--baseline":                {"compare"},
+	"--exclude-self":            {"find"},
+	"--extension-stats":         {"index"},
+	"--watch-interval":          {"index"},
+	"--ignore-mtime":            {"compare"},
+	"--verbose":                 {"compare"},
+	"--benchmark":               {"index"},
+	"--remote-index":            {"compare"},
+	"--remote-auth":             {"compare"},
+	"--timeout":                 {"compare"},
+	"--collision-check":         {"diagnose"},
+	"--age-threshold":           {"duplicates"},
+	"--hash-algo":               {"index", "checksum", "index-archive", "import"},
+	"--workers":                 {"index", "checksum", "compare"},
+	"--parallel":                {"index", "checksum", "compare"},
+	"--update":                  {"index"},
+	"--min-size":                {"index", "checksum"},
+	"--max-size":                {"index", "checksum"},
+	"--fast":                    {"duplicates"},
+	"--json":                    {"compare", "duplicates", "find", "stats"},
+	"--csv":                     {"compare", "duplicates"},
+	"--output":                  {"compare", "duplicates"},
+}
+
+// commandFormats lists, for each command accepting --format, the supported formats, the default one first.
+var commandFormats = map[string][]string{
+	"compare": {"text", "git", "markdown"},
+	"import":  {"csv"},
+}
+
+// flagAliases maps short flags to their long form.
+var flagAliases = map[string]string{
+	"-h": "--hidden",
+	"-v": "--verbose",
+}
 
 func main() {
 	if len(os.Args) < 2 {
@@ -16,6 +130,15 @@ func main() {
 
 	command := os.Args[1]
 
+	// Interrupted operations stop without saving the index, so that it is never left partially updated.
+	// The signals are only handled for the commands that check the context, the others are stopped by them right away.
+	ctx := context.Background()
+	if handlesInterrupt(command) {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+	}
+
 	isValidCommand := false
 	for _, validCommand := range validCommands {
 		if command == validCommand {
@@ -31,116 +154,1604 @@ func main() {
 
 	rootPath := "."
 	includeHidden := false
+	includePatterns := []string{}
+	hashAlgorithm := DefaultHashAlgorithm
+	storeInXDG := false
+	autoSuffix := false
+	searchParents := false
+	maxDepth := 0
+	useGitignore := false
+	symlinkMode := ""
+	includeEmptyDirs := false
+	includePermissions := false
 	targetFile := ""
+	diffPaths := []string{}
+	extractPaths := []string{}
+	namePattern := ""
+	recursiveFrom := ""
+	modifiedAfter := time.Time{}
+	changedSince := time.Time{}
+	modifiedBefore := time.Time{}
+	skipEmpty := false
+	withinDir := ""
+	overlapDir := ""
+	sameDirectory := false
+	fast := false
+	withAge := false
+	outputScript := false
+	keepListPath := ""
+	deleteListPath := ""
+	interactiveKeep := false
+	showPreview := false
+	countOnly := false
+	excludeSelf := false
+	trackRenamesWithin := ""
+	bySize := false
+	jsonOutput := false
+	csvOutput := false
+	outputPath := ""
+	topDirs := 0
+	limit := 0
+	offset := 0
+	outputFormat := ""
+	importRoot := ""
+	importPath := ""
+	checksumFilePath := ""
+	emitEvents := false
+	stableCheck := false
+	ignoreMtime := false
+	verbose := false
+	benchmark := false
+	collisionCheck := false
+	var ageThreshold time.Duration
+	jsonStream := false
+	onlyChanged := false
+	update := false
+	var progressInterval time.Duration
+	var skipIfUnchangedSince time.Duration
+	var watchInterval time.Duration
+	saveDiffPath := ""
+	outputPatchPath := ""
+	notesPath := ""
+	reuseHashesFrom := ""
+	expectedPath := ""
+	baselinePath := ""
+	remoteIndexURL := ""
+	remoteAuth := ""
+	remoteTimeout := DefaultRemoteTimeout
+	verifyAfter := false
+	extensionStats := false
+	patchFilePath := ""
+	tempDir := ""
+	snapshotsDir := ""
+	top := 10
+	tag := ""
+	backupPath := ""
+	restoreFilter := ""
+	dryRun := false
+	renameSuffix := DefaultRenameSuffix
+	keepStrategy := DefaultKeepStrategy
+	rateLimit := 0.0
+	workers := 0
+	var minSize, maxSize int64
+	minGroupSize := 2
+	maxGroupSize := 0
+	details := fileDetails{}
+	colorMode := ColorAuto
+	positionalArgs := []string{}
+
+	for i := 2; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		if alias, exists := flagAliases[arg]; exists {
+			arg = alias
+		}
+
+		allowedCommands, isFlag := flagCommands[arg]
+		if !isFlag {
+			positionalArgs = append(positionalArgs, arg)
+			continue
+		}
+		checkFlagAllowed(arg, command, allowedCommands)
+
+		switch arg {
+		case "--hidden":
+			includeHidden = true
+		case "--include":
+			pattern := flagValue(arg, &i)
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid include pattern %q: %v\n", pattern, err)
+				os.Exit(1)
+			}
+			includePatterns = append(includePatterns, pattern)
+		case "--hash", "--hash-algo":
+			hashAlgorithm = flagValue(arg, &i)
+			if _, err := newHasher(hashAlgorithm); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "--by-name":
+			namePattern = flagValue(arg, &i)
+		case "--recursive-from":
+			recursiveFrom = flagValue(arg, &i)
+		case "--modified-after":
+			modifiedAfter = timeFlagValue(arg, &i)
+		case "--modified-before":
+			modifiedBefore = timeFlagValue(arg, &i)
+		case "--changed-since":
+			changedSince = sinceFlagValue(arg, &i)
+		case "--skip-empty":
+			skipEmpty = true
+		case "--gitignore":
+			useGitignore = true
+		case "--symlinks":
+			symlinkMode = flagValue(arg, &i)
+			if err := checkSymlinkMode(symlinkMode); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "--include-empty-dirs":
+			includeEmptyDirs = true
+		case "--include-permissions":
+			includePermissions = true
+		case "--xdg":
+			storeInXDG = true
+		case "--search-parents":
+			searchParents = true
+		case "--max-depth":
+			maxDepth = intFlagValue(arg, &i)
+		case "--index-suffix":
+			if value := flagValue(arg, &i); value != "auto" {
+				fmt.Fprintf(os.Stderr, "Error: unknown index suffix '%s' (expected 'auto')\n", value)
+				os.Exit(1)
+			}
+			autoSuffix = true
+		case "--within":
+			withinDir = flagValue(arg, &i)
+		case "--overlap":
+			overlapDir = flagValue(arg, &i)
+		case "--same-directory":
+			sameDirectory = true
+		case "--fast":
+			fast = true
+		case "--with-age":
+			withAge = true
+		case "--output-script":
+			outputScript = true
+		case "--export-keep-list":
+			keepListPath = flagValue(arg, &i)
+		case "--export-delete-list":
+			deleteListPath = flagValue(arg, &i)
+		case "--interactive-keep":
+			interactiveKeep = true
+		case "--show-preview":
+			showPreview = true
+		case "--count-only":
+			countOnly = true
+		case "--exclude-self":
+			excludeSelf = true
+		case "--track-renames-within":
+			trackRenamesWithin = flagValue(arg, &i)
+		case "--by-size":
+			bySize = true
+		case "--json":
+			jsonOutput = true
+		case "--csv":
+			csvOutput = true
+		case "--output":
+			outputPath = flagValue(arg, &i)
+		case "--limit":
+			limit = intFlagValue(arg, &i)
+		case "--offset":
+			offset = intFlagValue(arg, &i)
+		case "--save-diff":
+			saveDiffPath = flagValue(arg, &i)
+		case "--output-patch":
+			outputPatchPath = flagValue(arg, &i)
+		case "--reuse-hashes-from":
+			reuseHashesFrom = flagValue(arg, &i)
+		case "--verify-after":
+			verifyAfter = true
+		case "--extension-stats":
+			extensionStats = true
+		case "--baseline":
+			baselinePath = flagValue(arg, &i)
+		case "--remote-index":
+			remoteIndexURL = flagValue(arg, &i)
+		case "--remote-auth":
+			remoteAuth = flagValue(arg, &i)
+			if !strings.Contains(remoteAuth, ":") {
+				fmt.Fprintf(os.Stderr, "Error: --remote-auth flag requires user:password\n")
+				os.Exit(1)
+			}
+		case "--timeout":
+			value := flagValue(arg, &i)
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout <= 0 {
+				fmt.Fprintf(os.Stderr, "Error: --timeout flag requires a positive duration, e.g. 30s, got '%s'\n", value)
+				os.Exit(1)
+			}
+			remoteTimeout = timeout
+		case "--expected":
+			expectedPath = flagValue(arg, &i)
+		case "--notes":
+			notesPath = flagValue(arg, &i)
+		case "--temp-dir":
+			tempDir = flagValue(arg, &i)
+		case "--snapshots-dir":
+			snapshotsDir = flagValue(arg, &i)
+		case "--top":
+			top = intFlagValue(arg, &i)
+		case "--top-dirs":
+			topDirs = intFlagValue(arg, &i)
+			if topDirs <= 0 {
+				fmt.Fprintf(os.Stderr, "Error: --top-dirs flag requires a positive number of directories\n")
+				os.Exit(1)
+			}
+		case "--tag":
+			tag = flagValue(arg, &i)
+		case "--backup":
+			backupPath = flagValue(arg, &i)
+		case "--files":
+			restoreFilter = flagValue(arg, &i)
+			if _, err := filepath.Match(restoreFilter, ""); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid files pattern %q: %v\n", restoreFilter, err)
+				os.Exit(1)
+			}
+		case "--dry-run":
+			dryRun = true
+		case "--suffix":
+			renameSuffix = flagValue(arg, &i)
+		case "--keep":
+			keepStrategy = flagValue(arg, &i)
+		case "--root":
+			importRoot = flagValue(arg, &i)
+		case "--rate-limit":
+			value := flagValue(arg, &i)
+			mbps, err := strconv.ParseFloat(value, 64)
+			if err != nil || mbps <= 0 {
+				fmt.Fprintf(os.Stderr, "Error: --rate-limit flag requires a positive number of MB/s, got '%s'\n", value)
+				os.Exit(1)
+			}
+			rateLimit = mbps
+		case "--min-size":
+			minSize = sizeFlagValue(arg, &i)
+		case "--max-size":
+			maxSize = sizeFlagValue(arg, &i)
+		case "--workers", "--parallel":
+			workers = intFlagValue(arg, &i)
+			if workers <= 0 {
+				fmt.Fprintf(os.Stderr, "Error: %s flag requires a positive number of workers, got %d\n", arg, workers)
+				os.Exit(1)
+			}
+		case "--min-group-size":
+			minGroupSize = intFlagValue(arg, &i)
+		case "--max-group-size":
+			maxGroupSize = intFlagValue(arg, &i)
+		case "--include-size":
+			details.size = true
+		case "--include-mtime":
+			details.mtime = true
+		case "--color":
+			mode, err := parseColorMode(flagValue(arg, &i))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			colorMode = mode
+		case "--no-color":
+			colorMode = ColorNever
+		case "--emit-events":
+			emitEvents = true
+		case "--stable-check":
+			stableCheck = true
+		case "--ignore-mtime":
+			ignoreMtime = true
+		case "--verbose":
+			verbose = true
+		case "--benchmark":
+			benchmark = true
+		case "--collision-check":
+			collisionCheck = true
+		case "--age-threshold":
+			value := flagValue(arg, &i)
+			threshold, err := parseDuration(value)
+			if err != nil || threshold <= 0 {
+				fmt.Fprintf(os.Stderr, "Error: --age-threshold flag requires a positive duration, e.g. 30d or 12h, got '%s'\n", value)
+				os.Exit(1)
+			}
+			ageThreshold = threshold
+		case "--json-stream":
+			jsonStream = true
+		case "--only-changed":
+			onlyChanged = true
+		case "--update":
+			onlyChanged = true
+			update = true
+		case "--progress-interval":
+			value := flagValue(arg, &i)
+			interval, err := time.ParseDuration(value)
+			if err != nil || interval <= 0 {
+				fmt.Fprintf(os.Stderr, "Error: --progress-interval flag requires a positive duration, e.g. 5s, got '%s'\n", value)
+				os.Exit(1)
+			}
+			progressInterval = interval
+		case "--watch-interval":
+			value := flagValue(arg, &i)
+			interval, err := time.ParseDuration(value)
+			if err != nil || interval <= 0 {
+				fmt.Fprintf(os.Stderr, "Error: --watch-interval flag requires a positive duration, e.g. 5m, got '%s'\n", value)
+				os.Exit(1)
+			}
+			watchInterval = interval
+		case "--skip-if-unchanged-since":
+			value := flagValue(arg, &i)
+			maxAge, err := time.ParseDuration(value)
+			if err != nil || maxAge <= 0 {
+				fmt.Fprintf(os.Stderr, "Error: --skip-if-unchanged-since flag requires a positive duration, e.g. 1h, got '%s'\n", value)
+				os.Exit(1)
+			}
+			skipIfUnchangedSince = maxAge
+		case "--format":
+			outputFormat = flagValue(arg, &i)
+			if !slices.Contains(commandFormats[command], outputFormat) {
+				fmt.Fprintf(os.Stderr, "Error: unknown format '%s' (expected '%s')\n", outputFormat, strings.Join(commandFormats[command], "' or '"))
+				os.Exit(1)
+			}
+		}
+	}
 
-	argIndex := 2
-	if command == "find" {
-		if len(os.Args) < 3 {
+	filterByTime := !modifiedAfter.IsZero() || !modifiedBefore.IsZero()
+	if filterByTime && !changedSince.IsZero() {
+		fmt.Fprintf(os.Stderr, "Error: --changed-since cannot be used with --modified-after or --modified-before\n")
+		os.Exit(1)
+	}
+	if command == "find" && namePattern == "" && recursiveFrom == "" && !filterByTime && changedSince.IsZero() {
+		if len(positionalArgs) < 1 {
 			fmt.Fprintf(os.Stderr, "Error: 'find' command requires a file path\n")
 			fmt.Fprintf(os.Stderr, "Usage: ./bff find <file-path> [directory]\n")
 			os.Exit(1)
 		}
-		targetFile = os.Args[argIndex]
-		argIndex = 3
+		targetFile = positionalArgs[0]
+		positionalArgs = positionalArgs[1:]
 	}
 
-	for i := argIndex; i < len(os.Args); i++ {
-		arg := os.Args[i]
-		if arg == "--hidden" || arg == "-h" {
-			// --hidden flag is only allowed for index command.
-			if command != "index" {
-				fmt.Fprintf(os.Stderr, "Error: --hidden flag is only allowed with 'index' command\n")
-				os.Exit(1)
-			}
-			includeHidden = true
-		} else if rootPath == "." {
-			rootPath = arg
+	if (countOnly || excludeSelf) && (namePattern != "" || recursiveFrom != "" || filterByTime || !changedSince.IsZero()) {
+		fmt.Fprintf(os.Stderr, "Error: --count-only and --exclude-self cannot be used with --by-name, --recursive-from, --modified-after, --modified-before or --changed-since\n")
+		os.Exit(1)
+	}
+
+	if withAge && snapshotsDir == "" {
+		fmt.Fprintf(os.Stderr, "Error: --with-age flag requires a snapshots directory\n")
+		fmt.Fprintf(os.Stderr, "Usage: ./bff duplicates --with-age --snapshots-dir <dir> [directory]\n")
+		os.Exit(1)
+	}
+	if snapshotsDir != "" && command == "duplicates" && !withAge {
+		fmt.Fprintf(os.Stderr, "Error: --snapshots-dir flag requires --with-age for the 'duplicates' command\n")
+		os.Exit(1)
+	}
+
+	if maxSize > 0 && maxSize < minSize {
+		fmt.Fprintf(os.Stderr, "Error: --max-size cannot be smaller than --min-size\n")
+		os.Exit(1)
+	}
+
+	if reuseHashesFrom != "" && (onlyChanged || jsonStream || progressInterval > 0) {
+		fmt.Fprintf(os.Stderr, "Error: --reuse-hashes-from cannot be used with --only-changed, --json-stream or --progress-interval\n")
+		os.Exit(1)
+	}
+
+	if onlyChanged && (jsonStream || progressInterval > 0) {
+		fmt.Fprintf(os.Stderr, "Error: --only-changed cannot be used with --json-stream or --progress-interval\n")
+		os.Exit(1)
+	}
+
+	if progressInterval > 0 && jsonStream {
+		fmt.Fprintf(os.Stderr, "Error: --progress-interval cannot be used with --json-stream, which already reports each file\n")
+		os.Exit(1)
+	}
+
+	if watchInterval > 0 && (onlyChanged || jsonStream || progressInterval > 0 || reuseHashesFrom != "" || skipIfUnchangedSince > 0 || benchmark) {
+		fmt.Fprintf(os.Stderr, "Error: --watch-interval cannot be used with --only-changed, --json-stream, --progress-interval, --reuse-hashes-from, --skip-if-unchanged-since or --benchmark\n")
+		os.Exit(1)
+	}
+
+	if command == "apply-patch" {
+		if len(positionalArgs) < 1 {
+			fmt.Fprintf(os.Stderr, "Error: 'apply-patch' command requires a patch file path\n")
+			fmt.Fprintf(os.Stderr, "Usage: ./bff apply-patch <patch.json> [directory]\n")
+			os.Exit(1)
+		}
+		patchFilePath = positionalArgs[0]
+		positionalArgs = positionalArgs[1:]
+	}
+
+	if command == "index-archive" && len(positionalArgs) < 1 {
+		fmt.Fprintf(os.Stderr, "Error: 'index-archive' command requires an archive path\n")
+		fmt.Fprintf(os.Stderr, "Usage: ./bff index-archive <archive.tar.gz>\n")
+		os.Exit(1)
+	}
+
+	if command == "diff" {
+		if len(positionalArgs) < 2 {
+			fmt.Fprintf(os.Stderr, "Error: 'diff' command requires two file paths\n")
+			fmt.Fprintf(os.Stderr, "Usage: ./bff diff <file-path> <file-path> [directory]\n")
+			os.Exit(1)
+		}
+		diffPaths = positionalArgs[:2]
+		positionalArgs = positionalArgs[2:]
+	}
+
+	if command == "extract-subtree" {
+		if len(positionalArgs) < 2 {
+			fmt.Fprintf(os.Stderr, "Error: 'extract-subtree' command requires a subdirectory and an output path\n")
+			fmt.Fprintf(os.Stderr, "Usage: ./bff extract-subtree <subdir> <output-index> [directory]\n")
+			os.Exit(1)
+		}
+		extractPaths = positionalArgs[:2]
+		positionalArgs = positionalArgs[2:]
+	}
+
+	if command == "verify-against" {
+		if len(positionalArgs) < 1 {
+			fmt.Fprintf(os.Stderr, "Error: 'verify-against' command requires a checksum file path\n")
+			fmt.Fprintf(os.Stderr, "Usage: ./bff verify-against <sha256sums.txt> [directory]\n")
+			os.Exit(1)
+		}
+		checksumFilePath = positionalArgs[0]
+		positionalArgs = positionalArgs[1:]
+	}
+
+	if command == "import" {
+		if len(positionalArgs) < 1 {
+			fmt.Fprintf(os.Stderr, "Error: 'import' command requires a file path\n")
+			fmt.Fprintf(os.Stderr, "Usage: ./bff import [--format csv] [--root <directory>] <file>\n")
+			os.Exit(1)
+		}
+		importPath = positionalArgs[0]
+		positionalArgs = nil
+		if importRoot != "" {
+			rootPath = importRoot
 		}
 	}
 
+	if len(positionalArgs) > 0 {
+		rootPath = positionalArgs[0]
+	}
+
 	absPath, err := filepath.Abs(rootPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: invalid path: %v\n", err)
 		os.Exit(1)
 	}
 
-	index := NewIndex(absPath, includeHidden)
+	index := NewIndexWithOptions(IndexOptions{RootPath: absPath, IncludeHidden: includeHidden, Workers: workers})
+	index.SkipZeroBytes = skipEmpty
+	index.IncludePatterns = includePatterns
+	index.MinSize = minSize
+	index.MaxSize = maxSize
+	if err := index.SetHashAlgorithm(hashAlgorithm); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	index.StoredInXDG = storeInXDG
+	index.AutoSuffix = autoSuffix
+	index.UseGitignore = useGitignore
+	index.SymlinkMode = symlinkMode
+	index.IncludeEmptyDirs = includeEmptyDirs
+	index.IncludePermissions = includePermissions
+	index.TempDir = tempDir
+	index.Tag = tag
+	index.DryRun = dryRun
+	index.RateLimitMBps = rateLimit
+	index.StableCheck = stableCheck
+	index.IgnoreMtime = ignoreMtime
+	index.VerifyAfter = verifyAfter
+	if benchmark {
+		index.Benchmark = &HashBenchmark{}
+	}
+	if info, err := os.Stat(absPath); err == nil && info.Mode().IsRegular() {
+		// Besides index-archive, only the archives already indexed are accepted in place of a directory.
+		if _, err := os.Stat(absPath + ArchiveIndexSuffix); command != "index-archive" && err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s is a file, not a directory (use index-archive to index a tar archive)\n", absPath)
+			os.Exit(1)
+		}
+		index.SourceType = SourceTar
+	}
 
-	if command == "index" {
-		count, err := index.Index()
+	if command == "show-diff" {
+		if len(positionalArgs) < 1 {
+			fmt.Fprintf(os.Stderr, "Error: 'show-diff' command requires a file path\n")
+			fmt.Fprintf(os.Stderr, "Usage: ./bff show-diff <diff-file>\n")
+			os.Exit(1)
+		}
+
+		result, err := LoadComparison(positionalArgs[0])
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("Indexed %d files\n", count)
+
+		fmt.Printf("Comparison of %s saved at %s\n", result.IndexAbsPath, result.SavedAt.Format(time.RFC3339))
+		result.Print(os.Stdout, colorMode)
 		return
 	}
 
-	if err := index.Load(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Please run 'bff index' first to create an index\n")
-		os.Exit(1)
+	if command == "apply-patch" {
+		ops, err := LoadPatch(patchFilePath)
+		if err == nil {
+			err = ApplyPatch(ops, absPath)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		moved, deleted := 0, 0
+		for _, op := range ops {
+			switch op.Op {
+			case PatchMove:
+				moved++
+			case PatchDelete:
+				deleted++
+			}
+		}
+		fmt.Printf("Applied %d move(s) and %d deletion(s) to %s\n", moved, deleted, absPath)
+		return
 	}
 
-	switch command {
-	case "compare":
-		result, err := index.Compare()
+	if command == "diff-indexes" {
+		if len(positionalArgs) < 2 {
+			fmt.Fprintf(os.Stderr, "Error: 'diff-indexes' command requires two index file paths\n")
+			fmt.Fprintf(os.Stderr, "Usage: ./bff diff-indexes <old-index> <new-index>\n")
+			os.Exit(1)
+		}
+
+		result, err := DiffIndexFiles(positionalArgs[0], positionalArgs[1])
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-		result.Print()
+		result.Print(os.Stdout, colorMode)
+		return
+	}
 
-	case "duplicates":
-		duplicates := index.FindAllDuplicates()
-		if len(duplicates) == 0 {
-			fmt.Println("No duplicates found")
-			return
+	if command == "merge" {
+		if len(positionalArgs) < 3 {
+			fmt.Fprintf(os.Stderr, "Error: 'merge' command requires two index file paths and an output path\n")
+			fmt.Fprintf(os.Stderr, "Usage: ./bff merge <index> <index> <output>\n")
+			os.Exit(1)
 		}
 
-		fmt.Printf("Found %d group(s) of duplicate files:\n\n", len(duplicates))
-		for hash, files := range duplicates {
-			fmt.Printf("Hash: %s\n", hash)
-			fmt.Printf("  %d files with identical content:\n", len(files))
-			for _, file := range files {
-				fmt.Printf("    - %s\n", file.Path)
+		merged, err := MergeIndexFiles(positionalArgs[0], positionalArgs[1])
+		if err == nil {
+			var data []byte
+			data, err = merged.marshal()
+			if err == nil {
+				err = writeAtomic(positionalArgs[2], data, 0644)
 			}
-			fmt.Println()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
 
-	case "find":
-		matches, err := index.FindDuplicates(targetFile)
+		fmt.Printf("Merged index saved to %s\n", positionalArgs[2])
+		return
+	}
+
+	if command == "top-changed" {
+		if snapshotsDir == "" {
+			fmt.Fprintf(os.Stderr, "Error: 'top-changed' command requires a snapshots directory\n")
+			fmt.Fprintf(os.Stderr, "Usage: ./bff top-changed --snapshots-dir <dir> [--top <n>]\n")
+			os.Exit(1)
+		}
+
+		snapshots, err := LoadSnapshots(snapshotsDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		history := &ChangeHistory{}
+		history.Analyze(snapshots)
+		printTopChanged(os.Stdout, history.TopChanged(top))
+		return
+	}
+
+	if command == "benchmark" {
+		results, err := BenchmarkDirectory(absPath)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+		printBenchmarkResults(os.Stdout, results)
+		return
+	}
+
+	if command == "checksum" {
+		if err := index.PrintChecksums(ctx, os.Stdout); err != nil {
+			exitIfInterrupted(ctx)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == "index" && skipIfUnchangedSince > 0 {
+		fresh, err := index.IsFresh(skipIfUnchangedSince)
+		if err != nil && !errors.Is(err, errIndexNotFound) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if fresh {
+			fmt.Printf("Index is fresh (updated %s ago), skipping\n", formatAge(time.Since(index.UpdatedAt)))
+			return
+		}
+	}
+
+	if command == "index" && jsonStream {
+		if _, err := index.IndexStream(ctx, os.Stdout); err != nil {
+			exitIfInterrupted(ctx)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printSuspectFiles(os.Stderr, index.SuspectFiles())
+		if extensionStats {
+			printExtensionStats(os.Stderr, index.GroupByExtension())
+		}
+		if index.Benchmark != nil {
+			printSlowestFiles(os.Stderr, index.Benchmark.TopSlowest(10))
+		}
+		return
+	}
+
+	if command == "index" && watchInterval > 0 {
+		fmt.Printf("Indexing %s every %s (press Ctrl+C to stop)\n", absPath, watchInterval)
+		if err := index.IndexLoop(ctx, watchInterval, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == "index" && onlyChanged {
+		if err := index.Load(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Please run 'bff index' first to create an index\n")
+			os.Exit(1)
+		}
 
-		if len(matches) == 1 {
-			fmt.Printf("File '%s' has no duplicates\n", targetFile)
+		result, err := index.IndexOnlyChanged(ctx)
+		if err != nil {
+			exitIfInterrupted(ctx)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, err := range result.Errors {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		printSuspectFiles(os.Stderr, index.SuspectFiles())
+		if extensionStats {
+			printExtensionStats(os.Stderr, index.GroupByExtension())
+		}
+		if index.Benchmark != nil {
+			printSlowestFiles(os.Stderr, index.Benchmark.TopSlowest(10))
+		}
+		if update {
+			printSkippedUnchanged(os.Stdout, result)
 		} else {
-			fmt.Printf("Found %d file(s) with identical content to '%s':\n", len(matches)-1, targetFile)
-			for _, match := range matches {
-				if match != targetFile {
-					fmt.Printf("  - %s\n", match)
-				}
-			}
+			printUpdateResult(os.Stdout, result)
 		}
+		return
 	}
-}
 
-func printUsage() {
-	fmt.Println("Usage: ./bff <command> [option] [directory]")
-	fmt.Println()
-	fmt.Println("Commands:")
-	fmt.Println("  index                - Index all files including in subdirectories (creates/updates the index file)")
-	fmt.Println("                         Option: --hidden, -h to include hidden files and directories")
-	fmt.Println("  compare              - Compare current state with last saved index")
-	fmt.Println("  duplicates           - Find all duplicate files")
-	fmt.Println("  find <path>          - Find all duplicates of a specific file")
+	if command == "index" && reuseHashesFrom != "" {
+		cache := NewIndex("", false)
+		if err := cache.loadFile(reuseHashesFrom); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to load %s: %v\n", reuseHashesFrom, err)
+			os.Exit(1)
+		}
+
+		result, err := index.IndexWithHashCacheContext(ctx, cache)
+		if err != nil {
+			exitIfInterrupted(ctx)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, err := range result.Errors {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		printSuspectFiles(os.Stderr, index.SuspectFiles())
+		if extensionStats {
+			printExtensionStats(os.Stderr, index.GroupByExtension())
+		}
+		if index.Benchmark != nil {
+			printSlowestFiles(os.Stderr, index.Benchmark.TopSlowest(10))
+		}
+		printIndexResult(os.Stdout, result)
+		printCacheHits(os.Stdout, result)
+		return
+	}
+
+	if command == "index" {
+		var printer *ProgressPrinter
+		if progressInterval > 0 {
+			printer = NewProgressPrinter(os.Stderr, progressInterval)
+		}
+
+		result, err := index.IndexWithProgress(ctx, printer)
+		if err != nil {
+			exitIfInterrupted(ctx)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, err := range result.Errors {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		printSuspectFiles(os.Stderr, index.SuspectFiles())
+		if extensionStats {
+			printExtensionStats(os.Stderr, index.GroupByExtension())
+		}
+		if index.Benchmark != nil {
+			printSlowestFiles(os.Stderr, index.Benchmark.TopSlowest(10))
+		}
+		printIndexResult(os.Stdout, result)
+		return
+	}
+
+	if command == "index-archive" {
+		count, err := indexArchive(index)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Indexed %d files from %s\n", count, absPath)
+		return
+	}
+
+	if command == "import" {
+		count, err := importCSV(index, importPath)
+		var rowErr *CSVRowError
+		if err != nil && !errors.As(err, &rowErr) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipped invalid rows:\n%v\n", err)
+		}
+		fmt.Printf("Imported %d files from %s into the index of %s\n", count, importPath, absPath)
+		return
+	}
+
+	if notesPath != "" && outputFormat != "markdown" {
+		fmt.Fprintf(os.Stderr, "Error: --notes flag requires --format markdown\n")
+		os.Exit(1)
+	}
+
+	if expectedPath != "" && (emitEvents || outputFormat != "") {
+		fmt.Fprintf(os.Stderr, "Error: --expected cannot be used with --emit-events or --format\n")
+		os.Exit(1)
+	}
+
+	if outputPatchPath != "" && emitEvents {
+		fmt.Fprintf(os.Stderr, "Error: --output-patch cannot be used with --emit-events\n")
+		os.Exit(1)
+	}
+
+	if trackRenamesWithin != "" && emitEvents {
+		fmt.Fprintf(os.Stderr, "Error: --track-renames-within cannot be used with --emit-events, whose renames are written during the scan\n")
+		os.Exit(1)
+	}
+
+	if command == "duplicates" && jsonStream && (withinDir != "" || overlapDir != "" || sameDirectory || limit > 0 || offset > 0 || minGroupSize != 2 || maxGroupSize > 0 || withAge || ageThreshold > 0 || interactiveKeep || outputScript || keepListPath != "" || deleteListPath != "") {
+		fmt.Fprintf(os.Stderr, "Error: --json-stream cannot be used with the options selecting, sorting or acting on the groups of duplicates\n")
+		os.Exit(1)
+	}
+
+	if fast && (withinDir != "" || overlapDir != "" || sameDirectory || jsonStream || interactiveKeep || outputScript || keepListPath != "" || deleteListPath != "") {
+		fmt.Fprintf(os.Stderr, "Error: --fast cannot be used with --within, --overlap, --same-directory, --json-stream, --interactive-keep, --output-script, --export-keep-list or --export-delete-list\n")
+		os.Exit(1)
+	}
+
+	if jsonOutput && (topDirs > 0 || bySize) {
+		fmt.Fprintf(os.Stderr, "Error: --json cannot be used with --top-dirs or --by-size\n")
+		os.Exit(1)
+	}
+
+	if outputPath != "" && !csvOutput {
+		fmt.Fprintf(os.Stderr, "Error: --output flag requires --csv\n")
+		os.Exit(1)
+	}
+
+	if csvOutput && (jsonOutput || outputFormat != "" || emitEvents || expectedPath != "") {
+		fmt.Fprintf(os.Stderr, "Error: --csv cannot be used with --json, --format, --emit-events or --expected\n")
+		os.Exit(1)
+	}
+
+	if command == "duplicates" && csvOutput && (jsonStream || limit > 0 || offset > 0 || withAge || ageThreshold > 0 || interactiveKeep || outputScript || keepListPath != "" || deleteListPath != "") {
+		fmt.Fprintf(os.Stderr, "Error: --csv cannot be used with --json-stream or the options sorting or acting on the groups of duplicates\n")
+		os.Exit(1)
+	}
+
+	if jsonOutput && (outputFormat != "" || emitEvents || expectedPath != "") {
+		fmt.Fprintf(os.Stderr, "Error: --json cannot be used with --format, --emit-events or --expected\n")
+		os.Exit(1)
+	}
+
+	if command == "duplicates" && jsonOutput && (jsonStream || limit > 0 || offset > 0 || withAge || ageThreshold > 0 || interactiveKeep || outputScript || keepListPath != "" || deleteListPath != "") {
+		fmt.Fprintf(os.Stderr, "Error: --json cannot be used with --json-stream or the options sorting or acting on the groups of duplicates\n")
+		os.Exit(1)
+	}
+
+	if command == "find" && jsonOutput && (namePattern != "" || recursiveFrom != "" || !changedSince.IsZero() || filterByTime || countOnly) {
+		fmt.Fprintf(os.Stderr, "Error: --json cannot be used with --by-name, --recursive-from, --changed-since, --modified-after, --modified-before or --count-only\n")
+		os.Exit(1)
+	}
+
+	if stableCheck && emitEvents {
+		fmt.Fprintf(os.Stderr, "Error: --stable-check cannot be used with --emit-events, whose events are written during the scan\n")
+		os.Exit(1)
+	}
+
+	if command == "restore" && backupPath == "" {
+		fmt.Fprintf(os.Stderr, "Error: 'restore' command requires a backup directory\n")
+		fmt.Fprintf(os.Stderr, "Usage: ./bff restore --backup <backup-dir> [--files <pattern>] [--dry-run] [directory]\n")
+		os.Exit(1)
+	}
+
+	if baselinePath != "" && searchParents {
+		fmt.Fprintf(os.Stderr, "Error: --baseline cannot be used with --search-parents\n")
+		os.Exit(1)
+	}
+
+	if remoteIndexURL != "" && (baselinePath != "" || searchParents) {
+		fmt.Fprintf(os.Stderr, "Error: --remote-index cannot be used with --baseline or --search-parents\n")
+		os.Exit(1)
+	}
+
+	if remoteIndexURL == "" && (remoteAuth != "" || remoteTimeout != DefaultRemoteTimeout) {
+		fmt.Fprintf(os.Stderr, "Error: --remote-auth and --timeout flags require --remote-index\n")
+		os.Exit(1)
+	}
+
+	switch {
+	case baselinePath != "":
+		err = index.LoadFrom(baselinePath)
+	case remoteIndexURL != "":
+		index.RemoteAuth = remoteAuth
+		fetchCtx, cancel := context.WithTimeout(ctx, remoteTimeout)
+		err = index.LoadFromHTTP(fetchCtx, remoteIndexURL)
+		cancel()
+	case searchParents:
+		index, err = index.LoadWithParents(maxDepth)
+	default:
+		err = index.Load()
+	}
+	if err != nil {
+		// The commands checking and repairing the index can load one with paths indexed several times.
+		if !errors.Is(err, errDuplicatePaths) || (command != "gc" && command != "diagnose") {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			if !errors.Is(err, errDuplicatePaths) && remoteIndexURL == "" {
+				fmt.Fprintf(os.Stderr, "Please run 'bff index' first to create an index\n")
+			}
+			os.Exit(1)
+		}
+	}
+
+	if index.isArchive() && needsFilesOnDisk(command) {
+		fmt.Fprintf(os.Stderr, "Error: '%s' command %v\n", command, errArchiveIndex)
+		os.Exit(1)
+	}
+
+	switch command {
+	case "compare":
+		if emitEvents {
+			if err := index.CompareStream(ctx, os.Stdout); err != nil {
+				exitIfInterrupted(ctx)
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		result, err := index.CompareWithContext(ctx)
+		if err != nil {
+			exitIfInterrupted(ctx)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if trackRenamesWithin != "" {
+			result = result.RefineRenames(trackRenamesWithin)
+		}
+
+		if saveDiffPath != "" {
+			if err := result.SaveToFile(saveDiffPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if outputPatchPath != "" {
+			if err := SavePatch(result.ToPatch(), outputPatchPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if expectedPath != "" {
+			expected, err := LoadComparison(expectedPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if !result.Equals(expected) {
+				fmt.Println("FAIL")
+				fmt.Print(result.Diff(expected))
+				os.Exit(1)
+			}
+			fmt.Println("PASS")
+			return
+		}
+
+		if jsonOutput {
+			if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		if csvOutput {
+			if err := writeOutput(outputPath, result.WriteCSV); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		switch outputFormat {
+		case "git":
+			fmt.Print(result.ToGitStatus())
+		case "markdown":
+			notes := map[string]string{}
+			if notesPath != "" {
+				if notes, err = LoadNotes(notesPath); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+			fmt.Print(result.Annotate(notes).ToMarkdown())
+		default:
+			if verbose {
+				result.PrintVerbose(os.Stdout, colorMode, index.FileCount(), index.TotalIndexedBytes())
+			} else {
+				result.PrintWithStats(os.Stdout, colorMode, index.FileCount(), index.TotalIndexedBytes())
+			}
+			if result.HasChanges() {
+				printChangeRate(os.Stdout, result, index.FileCount())
+			}
+		}
+
+	case "duplicates":
+		if interactiveKeep {
+			preview := &filePreview{colors: newColorizer(os.Stdout, colorMode)}
+			result, err := index.InteractiveKeep(os.Stdin, os.Stdout, preview, showPreview)
+			if result != nil {
+				printDedupSessionResult(os.Stdout, result, dryRun)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if outputScript {
+			if err := index.WriteDedupScript(os.Stdout, keepStrategy); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if jsonStream {
+			if err := index.StreamDuplicates(ctx, os.Stdout); err != nil {
+				exitIfInterrupted(ctx)
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if keepListPath != "" || deleteListPath != "" {
+			lists := []struct {
+				path  string
+				build func(string) ([]string, error)
+			}{
+				{keepListPath, index.KeepList},
+				{deleteListPath, index.DeleteList},
+			}
+			for _, list := range lists {
+				if list.path == "" {
+					continue
+				}
+				paths, err := list.build(keepStrategy)
+				if err == nil {
+					err = WritePathList(list.path, paths)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Printf("Wrote %d paths to %s\n", len(paths), list.path)
+			}
+			return
+		}
+
+		var duplicates map[string][]*FileInfo
+		switch {
+		case withinDir != "":
+			duplicates = index.FindDuplicatesWithin(withinDir)
+		case overlapDir != "":
+			duplicates = index.FindDuplicatesOverlapping(overlapDir)
+		case sameDirectory:
+			duplicates = index.FindSameDirectoryDuplicates()
+		case fast:
+			duplicates, err = index.FindDuplicatesFast(ctx)
+			if err != nil {
+				exitIfInterrupted(ctx)
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			duplicates = index.FindAllDuplicates()
+		}
+		duplicates = filterGroupsBySize(duplicates, minGroupSize, maxGroupSize)
+
+		if jsonOutput {
+			if err := json.NewEncoder(os.Stdout).Encode(duplicates); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		if csvOutput {
+			err := writeOutput(outputPath, func(w io.Writer) error {
+				return WriteDuplicatesCSV(w, duplicates)
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		groups := newDuplicateGroups(duplicates)
+		if withAge {
+			snapshots, err := LoadSnapshots(snapshotsDir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			sortDuplicateGroupsByAge(groups, snapshots)
+		}
+		if ageThreshold > 0 {
+			MarkAgeDivergence(groups, ageThreshold)
+		}
+		printDuplicateGroups(os.Stdout, paginateDuplicateGroups(groups, offset, limit), len(groups), details)
+
+	case "find":
+		if namePattern != "" {
+			matches, err := index.FindByNamePattern(namePattern)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			printNameMatches(os.Stdout, index, namePattern, matches)
+			return
+		}
+
+		if recursiveFrom != "" {
+			printPrefixDuplicates(os.Stdout, recursiveFrom, index.FindDuplicatesForPrefix(recursiveFrom))
+			return
+		}
+
+		if !changedSince.IsZero() {
+			printChangedFiles(os.Stdout, index.ChangedFiles(changedSince), changedSince, details)
+			return
+		}
+
+		if filterByTime {
+			printTimeMatches(os.Stdout, filesModifiedBetween(index, modifiedAfter, modifiedBefore), modifiedAfter, modifiedBefore, details)
+			return
+		}
+
+		if countOnly {
+			count, err := index.CountDuplicates(targetFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if excludeSelf {
+				count--
+			}
+			fmt.Println(count)
+			return
+		}
+
+		if excludeSelf || jsonOutput {
+			paths, err := index.FindDuplicatesExcludeSelf(targetFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if jsonOutput {
+				if err := json.NewEncoder(os.Stdout).Encode(sortedPaths(paths)); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				return
+			}
+			for _, path := range sortedPaths(paths) {
+				fmt.Println(path)
+			}
+			return
+		}
+
+		if _, err := index.FindDuplicates(targetFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printFindMatches(os.Stdout, index, targetFile, details)
+
+	case "diff":
+		for _, path := range diffPaths {
+			if _, indexed := index.hashOf(path); !indexed {
+				fmt.Fprintf(os.Stderr, "Warning: '%s' is not in the index\n", path)
+			}
+		}
+
+		diff, err := index.DiffFiles(diffPaths[0], diffPaths[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if diff == "" {
+			fmt.Println("Files are identical")
+		} else {
+			fmt.Print(diff)
+		}
+
+	case "diagnose":
+		printDiagnosis(os.Stdout, index.FindOrphanedHashes(), index.Validate(), index.ZeroByteFiles())
+		if collisionCheck {
+			printCollisionGroups(os.Stdout, index.HashCollisionCheck())
+		}
+
+	case "rename-duplicates":
+		actions, err := index.RenameDuplicates(renameSuffix, keepStrategy)
+		printRenameActions(os.Stdout, actions, dryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "extract-subtree":
+		if err := index.ExtractSubtree(extractPaths[0], extractPaths[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Index of %s saved to %s\n", extractPaths[0], extractPaths[1])
+
+	case "stats":
+		if jsonOutput {
+			data, err := json.MarshalIndent(index.Stats(), "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		printIndexSummary(os.Stdout, index)
+		fmt.Println()
+		printTopDirectories(os.Stdout, index.TopDirectoriesByWaste(10))
+		if topDirs > 0 {
+			fmt.Println()
+			printTopDirectoriesBySize(os.Stdout, index.TopDirectoriesBySize(topDirs))
+		}
+		if bySize {
+			fmt.Println()
+			printSizeGroups(os.Stdout, index.GroupBySize(), 10)
+		}
+
+	case "verify-against":
+		file, err := os.Open(checksumFilePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to open checksums: %v\n", err)
+			os.Exit(1)
+		}
+		result, err := index.VerifyAgainst(file)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printVerifyAgainstResult(os.Stdout, result)
+		if result.HasProblems() {
+			os.Exit(1)
+		}
+
+	case "fingerprint":
+		fmt.Println(index.Checksum())
+
+	case "name-conflicts":
+		printNameConflicts(os.Stdout, index.FindNameConflicts())
+
+	case "gc":
+		result, err := index.GC()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if result.Repaired > 0 {
+			fmt.Printf("%d duplicate entries of paths indexed several times removed\n", result.Repaired)
+		}
+		fmt.Printf("%d entries removed, %d hashes refreshed, %d bytes freed from index\n",
+			result.Removed, result.Refreshed, result.IndexSizeReduction)
+
+	case "restore":
+		backupAbsPath, err := filepath.Abs(backupPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid backup path: %v\n", err)
+			os.Exit(1)
+		}
+
+		backupIndex := NewIndex(backupAbsPath, false)
+		if err := backupIndex.Load(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Please run 'bff index' first in the backup directory\n")
+			os.Exit(1)
+		}
+
+		result, err := index.RestoreFrom(backupIndex, backupAbsPath, absPath, restoreFilter)
+		if result != nil {
+			printRestoreResult(os.Stdout, result, dryRun)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "watch":
+		fmt.Printf("Watching %s for changes (press Ctrl+C to stop)\n", absPath)
+		err := index.Watch(ctx.Done(), func(relPath string, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				return
+			}
+			fmt.Printf("Updated %s\n", relPath)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		exitIfInterrupted(ctx)
+	}
+}
+
+// exitIfInterrupted exits with code 130 if the context was cancelled by SIGINT or SIGTERM.
+func exitIfInterrupted(ctx context.Context) {
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "Interrupted")
+		os.Exit(130)
+	}
+}
+
+// checkFlagAllowed exits with an error if the flag cannot be used with the given command.
+func checkFlagAllowed(flag string, command string, allowedCommands []string) {
+	for _, allowedCommand := range allowedCommands {
+		if command == allowedCommand {
+			return
+		}
+	}
+
+	if len(allowedCommands) == 1 {
+		fmt.Fprintf(os.Stderr, "Error: %s flag is only allowed with '%s' command\n", flag, allowedCommands[0])
+	} else {
+		fmt.Fprintf(os.Stderr, "Error: %s flag is only allowed with %v commands\n", flag, allowedCommands)
+	}
+	os.Exit(1)
+}
+
+// flagValue returns the value following the flag at position i and advances i past it.
+// It exits with an error if the value is missing.
+func flagValue(flag string, i *int) string {
+	if *i+1 >= len(os.Args) {
+		fmt.Fprintf(os.Stderr, "Error: %s flag requires a value\n", flag)
+		os.Exit(1)
+	}
+	*i++
+	return os.Args[*i]
+}
+
+// intFlagValue returns the integer value following the flag at position i and advances i past it.
+// It exits with an error if the value is missing or not a valid integer.
+func intFlagValue(flag string, i *int) int {
+	value := flagValue(flag, i)
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s flag requires an integer value, got '%s'\n", flag, value)
+		os.Exit(1)
+	}
+	return n
+}
+
+// writeOutput calls write with the standard output, or with a buffer then saved to the file at the path if not empty.
+func writeOutput(path string, write func(io.Writer) error) error {
+	if path == "" {
+		return write(os.Stdout)
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	if err := atomicWriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// sizeFlagValue returns the size following the flag at position i and advances i past it.
+// The size can have a unit, e.g. 500KB. It exits with an error if it is invalid.
+func sizeFlagValue(flag string, i *int) int64 {
+	value := flagValue(flag, i)
+	size, err := ParseSize(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s flag requires a size (e.g. 500KB or 1MB), got '%s'\n", flag, value)
+		os.Exit(1)
+	}
+	return size
+}
+
+// timeFlagValue returns the time following the flag at position i and advances i past it.
+// The time can be in RFC 3339 format or a date, in the local time zone. It exits with an error if it is invalid.
+func timeFlagValue(flag string, i *int) time.Time {
+	value := flagValue(flag, i)
+	t, err := parseTime(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s flag requires an RFC 3339 timestamp or a YYYY-MM-DD date, got '%s'\n", flag, value)
+		os.Exit(1)
+	}
+	return t
+}
+
+// sinceFlagValue is like timeFlagValue, but the time can also be a positive duration before now, e.g. 24h.
+func sinceFlagValue(flag string, i *int) time.Time {
+	value := flagValue(flag, i)
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return time.Now().Add(-d)
+	}
+	t, err := parseTime(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s flag requires a duration (e.g. 24h), an RFC 3339 timestamp or a YYYY-MM-DD date, got '%s'\n", flag, value)
+		os.Exit(1)
+	}
+	return t
+}
+
+// parseDuration parses a duration like time.ParseDuration, or a number of days, e.g. 30d.
+func parseDuration(value string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(value, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number of days %q", days)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// parseTime parses a time in RFC 3339 format or a date, in the local time zone.
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, value, time.Local)
+}
+
+// filesModifiedBetween returns the indexed files modified at or after the first time and before the second one,
+// any of which can be zero to not filter on it.
+func filesModifiedBetween(index *Index, after, before time.Time) []*FileInfo {
+	if before.IsZero() {
+		return index.FindChangedSince(after)
+	}
+
+	files := []*FileInfo{}
+	for _, file := range index.FindUnchangedSince(before) {
+		if !file.ModTime.Before(after) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// indexArchive indexes the tar archive at the path of the index and saves the index next to it.
+func indexArchive(index *Index) (int, error) {
+	file, err := os.Open(index.AbsPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	count, err := index.IndexTar(file)
+	if err != nil {
+		return 0, err
+	}
+	if err := index.save(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// importCSV replaces the files of the index with the ones of a CSV file and saves the index.
+// It returns the number of files imported. The index is saved even if some rows were invalid,
+// in which case the error contains the *CSVRowError of these rows.
+func importCSV(index *Index, path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	defer file.Close()
+
+	readErr := index.ReadCSV(file)
+	var rowErr *CSVRowError
+	if readErr != nil && !errors.As(readErr, &rowErr) {
+		return 0, readErr
+	}
+	if err := index.save(); err != nil {
+		return 0, err
+	}
+	return index.FileCount(), readErr
+}
+
+// handlesInterrupt returns true if the command stops on SIGINT or SIGTERM through the context it is given, and exits
+// with code 130 without saving the index.
+func handlesInterrupt(command string) bool {
+	switch command {
+	case "index", "checksum", "compare", "duplicates", "watch":
+		return true
+	}
+	return false
+}
+
+// needsFilesOnDisk returns true if the command reads the indexed files, which is not possible for archive indexes.
+func needsFilesOnDisk(command string) bool {
+	switch command {
+	case "compare", "diff", "watch", "gc", "restore", "diagnose", "rename-duplicates":
+		return true
+	}
+	return false
+}
+
+func printUsage() {
+	fmt.Println("Usage: ./bff <command> [option] [directory]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  index                - Index all files including in subdirectories (creates/updates the index file)")
+	fmt.Println("                         Option: --hidden, -h to include hidden files and directories")
+	fmt.Println("                         Option: --include <pattern> to only index matching files (can be repeated)")
+	fmt.Println("                         Option: --min-size <size> and --max-size <size> to only index the files of at least or at most this size, e.g. 1MB (also for checksum)")
+	fmt.Println("                         Option: --hash sha256|sha512|blake2b|md5|xxhash (or --hash-algo) to choose the hash algorithm (default: sha256)")
+	fmt.Println("                         Option: --gitignore to exclude the files ignored by .gitignore files")
+	fmt.Println("                         Option: --symlinks follow|skip|record to follow symlinks to directories, ignore symlinks or index them by content (default: record)")
+	fmt.Println("                         Option: --include-empty-dirs to track empty directories")
+	fmt.Println("                         Option: --include-permissions to track the permission bits of the files")
+	fmt.Println("                         Option: --xdg to store the index in $XDG_DATA_HOME/bff instead of the directory")
+	fmt.Println("                         Option: --index-suffix auto to name the index file bff-<hash of the directory path>.json")
+	fmt.Println("                         Option: --tag <name> to label the index, e.g. with the machine name")
+	fmt.Println("                         Option: --temp-dir <dir> to write the index through a temp file in another directory (also for watch and gc)")
+	fmt.Println("                         Option: --json-stream to write each file as a JSON object per line as soon as it is indexed")
+	fmt.Println("                         Option: --only-changed to only hash the files whose size or modification time changed since last indexing")
+	fmt.Println("                         Option: --update like --only-changed, printing the number of unchanged files skipped")
+	fmt.Println("                         Option: --progress-interval <duration> to print the number of files indexed at most once per interval, e.g. 5s")
+	fmt.Println("                         Option: --extension-stats to print the number and size of the indexed files by extension to stderr")
+	fmt.Println("                         Option: --verify-after to hash each file a second time and warn about the ones whose hash differs")
+	fmt.Println("                         Option: --reuse-hashes-from <index file> to reuse the hashes of the files with the same size and modification time in another index")
+	fmt.Println("                         Option: --skip-if-unchanged-since <duration> to skip indexing if the index was written less than the duration ago, e.g. 1h")
+	fmt.Println("                         Option: --benchmark to show the 10 files that took the longest to hash, with their throughput")
+	fmt.Println("                         Option: --watch-interval <duration> to index the directory again every duration, e.g. 5m, until Ctrl+C")
+	fmt.Println("                         Option: --rate-limit <MB/s> to limit the rate at which files are read (also for checksum, compare, watch and gc)")
+	fmt.Println("                         Option: --workers <n> (or --parallel <n>) to hash n files in parallel, by default the number of CPUs (also for checksum and compare)")
+	fmt.Println("  index-archive <archive> - Index the files of a tar archive (.tar or .tar.gz) without extracting them")
+	fmt.Println("                         Option: the hidden, include, hash and xdg options of index")
+	fmt.Println("  import <file>        - Create the index from the checksums of a CSV file with the columns hash,path,size,mod_time")
+	fmt.Println("                         Option: --root <dir> to choose the directory the paths are in (default: current directory)")
+	fmt.Println("                         Option: --format csv to choose the format of the file (default: csv)")
+	fmt.Println("                         Option: the hash and xdg options of index")
+	fmt.Println("  benchmark            - Measure the throughput of reading and hashing files of the directory with each hash algorithm")
+	fmt.Println("  checksum             - Print the hash of all files like sha256sum, without creating an index")
+	fmt.Println("                         Option: the hidden, include, hash and gitignore options of index")
+	fmt.Println("  compare              - Compare current state with last saved index")
+	fmt.Println("                         Option: --format text|git|markdown to choose the output format (git: like 'git status --porcelain')")
+	fmt.Println("                         Option: --json to print the comparison as JSON, for scripts and CI pipelines")
+	fmt.Println("                         Option: --csv to print the changes as CSV with the columns type,path,old_path, to a file with --output <file>")
+	fmt.Println("                         Option: --notes <file> to add the notes of a JSON file {\"path\": \"note\"} to the changed files with --format markdown")
+	fmt.Println("                         Option: --emit-events to stream each change as a JSON object per line")
+	fmt.Println("                         Option: --save-diff <file> to also save the comparison to a JSON file")
+	fmt.Println("                         Option: --baseline <index file> to compare with another index file than the one of the directory, e.g. a snapshot")
+	fmt.Println("                         Option: --remote-index <url> to compare with an index file served over HTTP, with --remote-auth user:password and --timeout <duration> (default: 30s)")
+	fmt.Println("                         Option: --expected <file> to check that the changes are the ones of a comparison saved with --save-diff")
+	fmt.Println("                         Option: --output-patch <file> to also save the changes as JSON patch operations for apply-patch")
+	fmt.Println("                         Option: --track-renames-within <subdir> to only report files renamed or moved within a subdirectory")
+	fmt.Println("                         Option: --stable-check to hash modified files twice and report the ones changing during the scan")
+	fmt.Println("                         Option: --verbose, -v to also list the files whose modification time changed but not their content")
+	fmt.Println("                         Option: --ignore-mtime to skip the files whose modification time changed but not their content")
+	fmt.Println("                         Option: --color always|auto|never to color the changes (default: auto, only in a terminal), --no-color for never")
+	fmt.Println("  show-diff <file>     - Show a comparison saved with compare --save-diff")
+	fmt.Println("  apply-patch <file>   - Move and delete files like in a patch saved with compare --output-patch")
+	fmt.Println("  diff-indexes <old> <new> - Compare two index files without accessing the indexed directories")
+	fmt.Println("  merge <index> <index> <output> - Combine two index files, e.g. of different machines, into a new one")
+	fmt.Println("  extract-subtree <subdir> <output> - Save an index of the files of a subdirectory without indexing it again")
+	fmt.Println("  top-changed          - Show the files modified the most times across index snapshots")
+	fmt.Println("                         Option: --snapshots-dir <dir> to load the bff-*.json snapshots from (required)")
+	fmt.Println("                         Option: --top <n> to choose the number of files shown (default: 10)")
+	fmt.Println("  duplicates           - Find all duplicate files")
+	fmt.Println("                         Option: --skip-empty to ignore empty files")
+	fmt.Println("                         Option: --within <subdir> to only show groups entirely located in a subdirectory")
+	fmt.Println("                         Option: --overlap <subdir> to only show groups with at least one file in a subdirectory")
+	fmt.Println("                         Option: --same-directory to only show groups whose files are all in the same directory")
+	fmt.Println("                         Option: --fast to scan the directory again, only hashing the files of the same size as another one, instead of using the indexed hashes")
+	fmt.Println("                         Option: --limit <n> and --offset <k> to only show some of the groups")
+	fmt.Println("                         Option: --json to print the groups as a JSON object of the files by hash")
+	fmt.Println("                         Option: --csv to print the files of the groups as CSV with the columns hash,path,size,mod_time, to a file with --output <file>")
+	fmt.Println("                         Option: --min-group-size <n> and --max-group-size <n> to only show groups with that many files")
+	fmt.Println("                         Option: --include-size and --include-mtime to show the size and modification time of the files")
+	fmt.Println("                         Option: --age-threshold <duration> to warn about the groups whose newest file is that much newer than the oldest, e.g. 30d")
+	fmt.Println("                         Option: --json-stream to write each group as a JSON object per line as soon as it is found")
+	fmt.Println("                         Option: --interactive-keep to choose the file to keep of each group and delete the other ones")
+	fmt.Println("                         Option: --show-preview to also show the first lines of the text files, --dry-run to not delete them")
+	fmt.Println("                         Option: --output-script to write a shell script deleting all the copies but one instead, with --keep")
+	fmt.Println("                         Option: --export-keep-list <file> and --export-delete-list <file> to write the paths of the copies kept and the other ones instead, with --keep")
+	fmt.Println("                         Option: --keep first|shortest|oldest|newest to choose the copy kept by the script or the lists (default: first by path)")
+	fmt.Println("                         Option: --with-age --snapshots-dir <dir> to show when each group first appeared in the bff-*.json snapshots, oldest first")
+	fmt.Println("  rename-duplicates    - Add a numbered suffix to the names of all the copies of duplicate files but one")
+	fmt.Println("                         Option: --suffix <suffix> with <N> for the number of the copy (default: _dup<N>)")
+	fmt.Println("                         Option: --keep first|shortest|oldest|newest to choose the copy kept as is (default: first by path)")
+	fmt.Println("                         Option: --dry-run to only show the files that would be renamed")
+	fmt.Println("                         Option: --skip-empty to ignore empty files")
+	fmt.Println("  find <path>          - Find all duplicates of a specific file")
+	fmt.Println("                         Option: --count-only to only print the number of files with the same content, including the file")
+	fmt.Println("                         Option: --json to print the paths of the files with the same content as a JSON array")
+	fmt.Println("                         Option: --exclude-self to only print the paths of the other files with the same content, one per line (or their number with --count-only)")
+	fmt.Println("                         Option: --by-name <pattern> to find files whose name matches a glob pattern instead")
+	fmt.Println("                         Option: --recursive-from <subdir> to find the duplicates elsewhere of all the files of a subdirectory instead")
+	fmt.Println("                         Option: --modified-after <time> and --modified-before <time> to find the files modified in a time range instead")
+	fmt.Println("                         Option: --changed-since <duration|time> to find the files modified after a time or in the last duration instead, e.g. 24h")
+	fmt.Println("                         Option: --include-size and --include-mtime to show the size and modification time of the files")
+	fmt.Println("  diff <path> <path>   - Show the differences between the current content of two files")
+	fmt.Println("  name-conflicts       - Find files with the same name but different content")
+	fmt.Println("  restore              - Copy back from a backup the files modified or deleted since indexing")
+	fmt.Println("                         Option: --backup <dir> to choose the backup directory, which must be indexed too (required)")
+	fmt.Println("                         Option: --files <pattern> to only restore the files matching a glob pattern")
+	fmt.Println("                         Option: --dry-run to only show the files that would be restored")
+	fmt.Println("  diagnose             - Check the health of the index: missing files, inconsistent data and empty files")
+	fmt.Println("                         Option: --collision-check to also report the hashes shared by files of different sizes")
+	fmt.Println("  stats                - Show statistics about the indexed files and the directories wasting the most space")
+	fmt.Println("                         Option: --top-dirs <n> to also show the n top-level directories using the most space")
+	fmt.Println("                         Option: --by-size to also show the 10 most common file sizes")
+	fmt.Println("                         Option: --json to print the statistics of the index as JSON, for scripts")
+	fmt.Println("  fingerprint          - Print a single hash of the indexed state of the directory")
+	fmt.Println("  verify-against <file> - Check the indexed hashes against checksums in the format of sha256sum, e.g. downloaded with the files")
+	fmt.Println("  watch                - Watch for changes and keep the index up to date")
+	fmt.Println("  gc                   - Remove deleted files from the index and refresh the hashes of modified ones")
+	fmt.Println()
+	fmt.Println("Option: --search-parents to use the index of a parent directory if the directory has none, restricted to the directory")
+	fmt.Println("        (compare, duplicates, find, diff, name-conflicts, fingerprint, diagnose, stats and extract-subtree), --max-depth <n> to only search n levels up")
 	fmt.Println()
 	fmt.Println("Directory:")
 	fmt.Println("  Optional path to the directory (default: current directory)")
 	fmt.Println()
-	fmt.Println("Note: compare, duplicates and find commands require running index first")
-	fmt.Println("Note: the hidden option is only applicable to the index command, then when using other commands the hidden settings from the saved index will be used")
+	fmt.Println("Note: all commands except index, index-archive, import, benchmark, checksum, show-diff, diff-indexes, top-changed and merge require running index first")
+	fmt.Println("Note: the hidden, include, size, hash and gitignore options are only applicable to the index and checksum commands, then when using other commands the settings from the saved index will be used")
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// TestInterruptExitCode runs the watch command in a subprocess (the test binary running main),
+// then checks that it exits with code 130 when it receives SIGINT or SIGTERM.
+func TestInterruptExitCode(t *testing.T) {
+	if dir := os.Getenv("BFF_TEST_WATCH_DIR"); dir != "" {
+		os.Args = []string{"bff", "watch", dir}
+		main()
+		return
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("signals cannot be sent to processes on Windows")
+	}
+
+	for _, sig := range []syscall.Signal{syscall.SIGINT, syscall.SIGTERM} {
+		t.Run(sig.String(), func(t *testing.T) {
+			testDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(testDir, "file.txt"), []byte("content"), 0644); err != nil {
+				t.Fatalf("failed to create file: %v", err)
+			}
+			if _, err := NewIndexWithOptions(IndexOptions{RootPath: testDir}).IndexWithResult(); err != nil {
+				t.Fatalf("indexing failed: %v", err)
+			}
+
+			cmd := exec.Command(os.Args[0], "-test.run=^TestInterruptExitCode$")
+			cmd.Env = append(os.Environ(), "BFF_TEST_WATCH_DIR="+testDir)
+			stdout, err := cmd.StdoutPipe()
+			if err != nil {
+				t.Fatalf("failed to get stdout: %v", err)
+			}
+			if err := cmd.Start(); err != nil {
+				t.Fatalf("failed to start subprocess: %v", err)
+			}
+
+			// Wait for the watch to start so that the signal handling is set up.
+			line, err := bufio.NewReader(stdout).ReadString('\n')
+			if err != nil || !strings.HasPrefix(line, "Watching") {
+				cmd.Process.Kill()
+				t.Fatalf("expected watch to start, got %q (%v)", line, err)
+			}
+
+			if err := cmd.Process.Signal(sig); err != nil {
+				t.Fatalf("failed to send signal: %v", err)
+			}
+
+			err = cmd.Wait()
+			if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 130 {
+				t.Errorf("expected exit code 130, got %v", err)
+			}
+		})
+	}
+}
+
+// TestFindCountOnly runs the find command with --count-only in a subprocess (the test binary running main),
+// then checks that it prints the number of files with the same content and exits with code 0.
+func TestFindCountOnly(t *testing.T) {
+	if args := os.Getenv("BFF_TEST_FIND_ARGS"); args != "" {
+		os.Args = append([]string{"bff", "find"}, strings.Split(args, "\n")...)
+		main()
+		// Exit like the command would, without the output of the test framework.
+		os.Exit(0)
+	}
+
+	testDir := t.TempDir()
+	files := map[string]string{"unique.txt": "unique content", "copy1.txt": "duplicate content", "copy2.txt": "duplicate content"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+	if _, err := NewIndexWithOptions(IndexOptions{RootPath: testDir}).IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"unique.txt", "1"},
+		{"copy1.txt", "2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestFindCountOnly$")
+			cmd.Env = append(os.Environ(), "BFF_TEST_FIND_ARGS="+strings.Join([]string{tt.file, "--count-only", testDir}, "\n"))
+			output, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("expected exit code 0, got %v", err)
+			}
+			if got := strings.TrimSpace(string(output)); got != tt.expected {
+				t.Errorf("expected output %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestJSONOutput runs the compare, duplicates and find commands with --json in a subprocess (the test binary running
+// main), then checks that their output can be unmarshalled.
+func TestJSONOutput(t *testing.T) {
+	if args := os.Getenv("BFF_TEST_JSON_ARGS"); args != "" {
+		os.Args = append([]string{"bff"}, strings.Split(args, "\n")...)
+		main()
+		os.Exit(0)
+	}
+
+	testDir := t.TempDir()
+	files := map[string]string{"unique.txt": "unique content", "copy1.txt": "duplicate content", "copy2.txt": "duplicate content"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+	if _, err := NewIndexWithOptions(IndexOptions{RootPath: testDir}).IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "added.txt"), []byte("added"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.Remove(filepath.Join(testDir, "unique.txt")); err != nil {
+		t.Fatalf("failed to delete file: %v", err)
+	}
+
+	run := func(args ...string) []byte {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestJSONOutput$")
+		cmd.Env = append(os.Environ(), "BFF_TEST_JSON_ARGS="+strings.Join(append(args, "--json", testDir), "\n"))
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("%v failed: %v", args, err)
+		}
+		return output
+	}
+
+	var comparison Comparison
+	if err := json.Unmarshal(run("compare"), &comparison); err != nil {
+		t.Fatalf("failed to unmarshal the comparison: %v", err)
+	}
+	if len(comparison.Added) != 1 || len(comparison.Deleted) != 1 || len(comparison.Modified) != 0 {
+		t.Errorf("expected 1 added and 1 deleted file, got %+v", comparison)
+	}
+
+	var duplicates map[string][]FileInfo
+	if err := json.Unmarshal(run("duplicates"), &duplicates); err != nil {
+		t.Fatalf("failed to unmarshal the duplicates: %v", err)
+	}
+	if files := duplicates[computeHash([]byte("duplicate content"))]; len(duplicates) != 1 || len(files) != 2 {
+		t.Errorf("expected 1 group of 2 files, got %v", duplicates)
+	}
+
+	var paths []string
+	if err := json.Unmarshal(run("find", "copy1.txt"), &paths); err != nil {
+		t.Fatalf("failed to unmarshal the paths: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != "copy2.txt" {
+		t.Errorf("expected [copy2.txt], got %v", paths)
+	}
+}
+
+// TestFilePathRequiresIndexArchive runs the index command on a file in a subprocess (the test binary running main),
+// then checks that it exits with an error instead of treating the file as an archive.
+func TestFilePathRequiresIndexArchive(t *testing.T) {
+	if path := os.Getenv("BFF_TEST_FILE_PATH"); path != "" {
+		os.Args = []string{"bff", "index", path}
+		main()
+		os.Exit(0)
+	}
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFilePathRequiresIndexArchive$")
+	cmd.Env = append(os.Environ(), "BFF_TEST_FILE_PATH="+path)
+	output, err := cmd.CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+		t.Fatalf("expected exit code 1, got %v", err)
+	}
+	if !strings.Contains(string(output), "is a file, not a directory") {
+		t.Errorf("expected an error about the file, got %q", output)
+	}
+	if _, err := os.Stat(path + ArchiveIndexSuffix); !os.IsNotExist(err) {
+		t.Errorf("expected no archive index to be written, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Merge returns a new index combining the files of the index and of another one, e.g. indexes of
+// different machines. If both indexes have different tags, the paths are prefixed with "<tag>:"
+// to know where each file comes from. Both indexes must use the same hash algorithm.
+func (idx *Index) Merge(other *Index) (*Index, error) {
+	if idx.HashAlgorithm != other.HashAlgorithm {
+		return nil, fmt.Errorf("cannot merge indexes using different hash algorithms (%s and %s)", idx.HashAlgorithm, other.HashAlgorithm)
+	}
+
+	prefix, otherPrefix := "", ""
+	if idx.Tag != other.Tag {
+		prefix, otherPrefix = tagPrefix(idx.Tag), tagPrefix(other.Tag)
+	}
+
+	merged := idx.Clone()
+	merged.FilesByContentHash = make(map[string][]*FileInfo)
+	merged.EmptyDirs = nil
+	if idx.Tag != other.Tag {
+		merged.Tag = ""
+	}
+
+	addFiles(merged, idx, prefix)
+	addFiles(merged, other, otherPrefix)
+	if idx.DirStats != nil || other.DirStats != nil {
+		merged.DirStats = merged.ComputeDirectoryStats()
+	}
+
+	return merged, nil
+}
+
+// tagPrefix returns the prefix of the paths of a tagged index when merged, or an empty one if there is no tag.
+func tagPrefix(tag string) string {
+	if tag == "" {
+		return ""
+	}
+	return tag + ":"
+}
+
+// addFiles adds copies of the files and empty directories of src to dst, with the given prefix added to their paths.
+// Files already present in dst with the same path and hash are not added twice.
+func addFiles(dst, src *Index, prefix string) {
+	for hash, files := range src.FilesByContentHash {
+		existing := make(map[string]bool)
+		for _, file := range dst.FilesByContentHash[hash] {
+			existing[file.Path] = true
+		}
+
+		for _, file := range files {
+			merged := *file
+			merged.Path = prefix + file.Path
+			if existing[merged.Path] {
+				continue
+			}
+			dst.FilesByContentHash[hash] = append(dst.FilesByContentHash[hash], &merged)
+		}
+	}
+
+	for _, dir := range src.EmptyDirs {
+		dst.EmptyDirs = append(dst.EmptyDirs, prefix+dir)
+	}
+}
+
+// MergeIndexFiles merges two saved index files, see (*Index).Merge.
+func MergeIndexFiles(pathA, pathB string) (*Index, error) {
+	idxA := NewIndex("", false)
+	if err := idxA.loadFile(pathA); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", pathA, err)
+	}
+
+	idxB := NewIndex("", false)
+	if err := idxB.loadFile(pathB); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", pathB, err)
+	}
+
+	return idxA.Merge(idxB)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	newTaggedIndex := func(tag string) *Index {
+		idx := NewIndexWithOptions(IndexOptions{RootPath: "/home/user"})
+		idx.Tag = tag
+		idx.FilesByContentHash = map[string][]*FileInfo{
+			"report": {{Path: "docs/report.pdf", Size: 100}},
+		}
+		return idx
+	}
+
+	tests := []struct {
+		name          string
+		tagA, tagB    string
+		expectedPaths []string
+		expectedTag   string
+	}{
+		{"different tags", "machine1", "machine2", []string{"machine1:docs/report.pdf", "machine2:docs/report.pdf"}, ""},
+		{"same tags", "machine1", "machine1", []string{"docs/report.pdf"}, "machine1"},
+		{"no tags", "", "", []string{"docs/report.pdf"}, ""},
+		{"single tag", "machine1", "", []string{"docs/report.pdf", "machine1:docs/report.pdf"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idxA := newTaggedIndex(tt.tagA)
+			idxB := newTaggedIndex(tt.tagB)
+			idxB.FilesByContentHash["other"] = []*FileInfo{{Path: "other.txt"}}
+
+			merged, err := idxA.Merge(idxB)
+			if err != nil {
+				t.Fatalf("Merge() failed: %v", err)
+			}
+
+			paths := []string{}
+			for _, file := range merged.FilesByContentHash["report"] {
+				paths = append(paths, file.Path)
+			}
+			sort.Strings(paths)
+			if !reflect.DeepEqual(paths, tt.expectedPaths) {
+				t.Errorf("expected paths %v, got %v", tt.expectedPaths, paths)
+			}
+			if len(merged.FilesByContentHash["other"]) != 1 {
+				t.Errorf("expected other.txt to be merged, got %v", merged.FilesByContentHash["other"])
+			}
+			if merged.Tag != tt.expectedTag {
+				t.Errorf("expected tag %q, got %q", tt.expectedTag, merged.Tag)
+			}
+
+			if idxA.FilesByContentHash["report"][0].Path != "docs/report.pdf" {
+				t.Error("expected merged index to be unchanged")
+			}
+		})
+	}
+
+	idxA := newTaggedIndex("machine1")
+	idxB := newTaggedIndex("machine2")
+	idxB.HashAlgorithm = HashXXHash
+	if _, err := idxA.Merge(idxB); err == nil {
+		t.Error("expected error when merging indexes with different hash algorithms, got nil")
+	}
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// printIndexResult outputs a summary of an indexing run, e.g. "Indexed 1234 files (45.6 GB) in 12.3s (3.7 GB/s)".
+func printIndexResult(w io.Writer, result *IndexResult) {
+	seconds := result.Duration.Seconds()
+
+	throughput := ""
+	if seconds > 0 {
+		throughput = fmt.Sprintf(" (%s/s)", FormatSize(int64(float64(result.BytesRead)/seconds)))
+	}
+
+	fmt.Fprintf(w, "Indexed %d files (%s) in %.1fs%s\n", result.FileCount, FormatSize(result.BytesRead), seconds, throughput)
+}
+
+// printUpdateResult outputs a summary of an update of the index, e.g. "Updated 12 of 1234 files in 1.2s".
+func printUpdateResult(w io.Writer, result *IndexResult) {
+	fmt.Fprintf(w, "Updated %d of %d files in %.1fs\n", result.FileCount-result.Reused, result.FileCount, result.Duration.Seconds())
+}
+
+// printSkippedUnchanged outputs a summary of an update of the index with the number of unchanged files,
+// e.g. "Updated 12 files, skipped 1222 unchanged in 1.2s".
+func printSkippedUnchanged(w io.Writer, result *IndexResult) {
+	fmt.Fprintf(w, "Updated %d files, skipped %d unchanged in %.1fs\n", result.FileCount-result.Reused, result.Reused, result.Duration.Seconds())
+}
+
+// printCacheHits outputs the number of files whose hash was reused from another index, e.g.
+// "Reused the hashes of 1200 of 1234 files (97.2%)".
+func printCacheHits(w io.Writer, result *IndexResult) {
+	rate := 0.0
+	if result.FileCount > 0 {
+		rate = float64(result.Reused) / float64(result.FileCount) * 100
+	}
+	fmt.Fprintf(w, "Reused the hashes of %d of %d files (%.1f%%)\n", result.Reused, result.FileCount, rate)
+}
+
+// printSuspectFiles outputs a warning for each file whose hash was different when it was read again, see VerifyHash.
+func printSuspectFiles(w io.Writer, files []*FileInfo) {
+	for _, file := range files {
+		fmt.Fprintf(w, "Warning: %s had another hash when read again, it may have a read error or have been modified while indexing\n", file.Path)
+	}
+}
+
+// formatAge returns a duration rounded down to its largest unit, e.g. "45s", "2m", "3h" or "5d".
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+// fileDetails selects the information shown after the paths of the files, if any.
+type fileDetails struct {
+	size  bool
+	mtime bool
+}
+
+// format returns the selected information about the file, e.g. " (1.2 KB, 2024-01-02T15:04:05Z)",
+// or an empty string if none is selected.
+func (d fileDetails) format(file *FileInfo) string {
+	details := []string{}
+	if d.size {
+		details = append(details, FormatSize(file.Size))
+	}
+	if d.mtime {
+		details = append(details, file.ModTime.Format(time.RFC3339))
+	}
+
+	if len(details) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(details, ", ") + ")"
+}
+
+// printDuplicateGroups outputs groups of duplicate files, out of a total number of groups.
+func printDuplicateGroups(w io.Writer, groups []DuplicateGroup, total int, details fileDetails) {
+	if total == 0 {
+		fmt.Fprintln(w, "No duplicates found")
+		return
+	}
+
+	fmt.Fprintf(w, "Found %d group(s) of duplicate files:\n\n", total)
+	for _, group := range groups {
+		fmt.Fprintf(w, "Hash: %s\n", group.Hash)
+		if group.AgeWarning != "" {
+			fmt.Fprintf(w, "  ⚠ %s\n", group.AgeWarning)
+		}
+		if group.FirstSeenAt != nil {
+			fmt.Fprintf(w, "  First seen: %s\n", group.FirstSeenAt.Format(time.RFC3339))
+		}
+		fmt.Fprintf(w, "  %d files with identical content:\n", len(group.Files))
+		for _, file := range group.Files {
+			fmt.Fprintf(w, "    - %s%s\n", file.Path, details.format(file))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(groups) < total {
+		fmt.Fprintf(w, "(showing %d of %d groups)\n", len(groups), total)
+	}
+}
+
+// printFindMatches outputs the files with the same content as the target file, other than itself.
+func printFindMatches(w io.Writer, idx *Index, targetPath string, details fileDetails) {
+	hash, _ := idx.hashOf(targetPath)
+	files := idx.FilesByContentHash[hash]
+
+	if len(files) <= 1 {
+		fmt.Fprintf(w, "File '%s' has no duplicates\n", targetPath)
+		return
+	}
+
+	fmt.Fprintf(w, "Found %d file(s) with identical content to '%s':\n", len(files)-1, targetPath)
+	for _, file := range files {
+		if file.Path != targetPath {
+			fmt.Fprintf(w, "  - %s%s\n", file.Path, details.format(file))
+		}
+	}
+}
+
+// printPrefixDuplicates outputs the duplicates outside of a directory of each of its files, then how many have some.
+func printPrefixDuplicates(w io.Writer, prefix string, duplicates map[string][]string) {
+	paths := make([]string, 0, len(duplicates))
+	for path := range duplicates {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	withDuplicates := 0
+	for _, path := range paths {
+		if len(duplicates[path]) == 0 {
+			continue
+		}
+		withDuplicates++
+		fmt.Fprintf(w, "%s:\n", path)
+		for _, duplicate := range duplicates[path] {
+			fmt.Fprintf(w, "  - %s\n", duplicate)
+		}
+	}
+
+	if withDuplicates > 0 {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d of %d files under '%s' have duplicates elsewhere\n", withDuplicates, len(paths), prefix)
+}
+
+// printIndexSummary outputs the statistics of the index, and the newest and oldest indexed files.
+func printIndexSummary(w io.Writer, idx *Index) {
+	stats := idx.Stats()
+	fmt.Fprintf(w, "Files: %d\n", stats.FileCount)
+	fmt.Fprintf(w, "Total size: %s\n", FormatSize(stats.TotalSize))
+	fmt.Fprintf(w, "Duplicate groups: %d\n", stats.DuplicateGroups)
+	fmt.Fprintf(w, "Wasted space: %s\n", FormatSize(stats.WastedBytes))
+	if stats.LargestGroupSize > 0 {
+		fmt.Fprintf(w, "Largest duplicate group: %d files (%s)\n", stats.LargestGroupSize, stats.LargestGroupHash)
+	}
+	fmt.Fprintf(w, "Hash algorithm: %s\n", stats.HashAlgorithm)
+	if newest := idx.MostRecentFile(); newest != nil {
+		fmt.Fprintf(w, "Newest file: %s (%s)\n", newest.Path, newest.ModTime.Format(time.RFC3339))
+	}
+	if oldest := idx.OldestFile(); oldest != nil {
+		fmt.Fprintf(w, "Oldest file: %s (%s)\n", oldest.Path, oldest.ModTime.Format(time.RFC3339))
+	}
+}
+
+// printTopDirectories outputs the directories wasting the most space with duplicates.
+func printTopDirectories(w io.Writer, top []DirStat) {
+	if len(top) == 0 {
+		fmt.Fprintln(w, "No directories with duplicates")
+		return
+	}
+
+	fmt.Fprintln(w, "Directories wasting the most space:")
+	for _, dir := range top {
+		fmt.Fprintf(w, "  %s: %s wasted by %d duplicate(s) out of %d files\n", dir.Dir, FormatSize(dir.WastedBytes), dir.DuplicateCount, dir.FileCount)
+	}
+}
+
+// printTopDirectoriesBySize outputs the top-level directories using the most space with their number of files.
+func printTopDirectoriesBySize(w io.Writer, top []DirSizeEntry) {
+	if len(top) == 0 {
+		fmt.Fprintln(w, "No indexed files")
+		return
+	}
+
+	fmt.Fprintln(w, "Top-level directories using the most space:")
+	for _, entry := range top {
+		fmt.Fprintf(w, "  %s: %s in %d files\n", entry.Dir, FormatSize(entry.TotalSize), entry.FileCount)
+	}
+}
+
+// printExtensionStats outputs the number and size of the files by extension on one line, the ones using the most space
+// first, e.g. "jpg: 1245 files, 4.3 GB | go: 342 files, 2.1 MB | (none): 3 files, 1.2 KB".
+func printExtensionStats(w io.Writer, stats map[string]*ExtensionStats) {
+	exts := make([]string, 0, len(stats))
+	for ext := range stats {
+		exts = append(exts, ext)
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if stats[exts[i]].TotalSize != stats[exts[j]].TotalSize {
+			return stats[exts[i]].TotalSize > stats[exts[j]].TotalSize
+		}
+		return exts[i] < exts[j]
+	})
+
+	entries := make([]string, len(exts))
+	for i, ext := range exts {
+		name := ext
+		if name == "" {
+			name = "(none)"
+		}
+		entries[i] = fmt.Sprintf("%s: %d files, %s", name, stats[ext].FileCount, FormatSize(stats[ext].TotalSize))
+	}
+	fmt.Fprintln(w, strings.Join(entries, " | "))
+}
+
+// printSizeGroups outputs the top file sizes shared by the most files, the largest first for sizes with as many files.
+func printSizeGroups(w io.Writer, groups map[int64][]*FileInfo, top int) {
+	sizes := make([]int64, 0, len(groups))
+	for size := range groups {
+		sizes = append(sizes, size)
+	}
+	sort.Slice(sizes, func(i, j int) bool {
+		if len(groups[sizes[i]]) != len(groups[sizes[j]]) {
+			return len(groups[sizes[i]]) > len(groups[sizes[j]])
+		}
+		return sizes[i] > sizes[j]
+	})
+	if len(sizes) > top {
+		sizes = sizes[:top]
+	}
+
+	fmt.Fprintf(w, "Most common file sizes:\n")
+	for _, size := range sizes {
+		fmt.Fprintf(w, "  %s (%d bytes): %d files\n", FormatSize(size), size, len(groups[size]))
+	}
+}
+
+// printNameMatches outputs the files matching a name pattern, along with their size
+// and whether they have duplicates.
+func printNameMatches(w io.Writer, idx *Index, pattern string, matches []*FileInfo) {
+	if len(matches) == 0 {
+		fmt.Fprintf(w, "No matches found for pattern '%s'\n", pattern)
+		return
+	}
+
+	fmt.Fprintf(w, "Found %d file(s) matching '%s':\n", len(matches), pattern)
+	for _, file := range matches {
+		duplicateInfo := ""
+		if idx.HasDuplicates(file.Path) {
+			duplicateInfo = ", has duplicates"
+		}
+		fmt.Fprintf(w, "  - %s (%s%s)\n", file.Path, FormatSize(file.Size), duplicateInfo)
+	}
+}
+
+// printTimeMatches outputs the files found by modification time, with the time range searched.
+func printTimeMatches(w io.Writer, matches []*FileInfo, after, before time.Time, details fileDetails) {
+	var conditions []string
+	if !after.IsZero() {
+		conditions = append(conditions, "at or after "+after.Format(time.RFC3339))
+	}
+	if !before.IsZero() {
+		conditions = append(conditions, "before "+before.Format(time.RFC3339))
+	}
+	timeRange := strings.Join(conditions, " and ")
+
+	printModifiedFiles(w, matches, timeRange, details)
+}
+
+// printChangedFiles outputs the files modified after a time, with their modification time.
+func printChangedFiles(w io.Writer, matches []*FileInfo, since time.Time, details fileDetails) {
+	printModifiedFiles(w, matches, "after "+since.Format(time.RFC3339), details)
+}
+
+// printModifiedFiles outputs the files modified in the described time range, with their modification time.
+func printModifiedFiles(w io.Writer, matches []*FileInfo, timeRange string, details fileDetails) {
+	if len(matches) == 0 {
+		fmt.Fprintf(w, "No files modified %s\n", timeRange)
+		return
+	}
+
+	details.mtime = true
+	fmt.Fprintf(w, "Found %d file(s) modified %s:\n", len(matches), timeRange)
+	for _, file := range matches {
+		fmt.Fprintf(w, "  - %s%s\n", file.Path, details.format(file))
+	}
+}
+
+// printBenchmarkResults outputs the throughput of each benchmark on a single line.
+func printBenchmarkResults(w io.Writer, results []BenchmarkResult) {
+	throughputs := make([]string, len(results))
+	for i, result := range results {
+		throughputs[i] = fmt.Sprintf("%s: %.0f MB/s", result.Name, result.Throughput())
+	}
+	fmt.Fprintf(w, "Benchmarked on %s of data\n", FormatSize(results[0].Bytes))
+	fmt.Fprintln(w, strings.Join(throughputs, ", "))
+}
+
+// printSlowestFiles outputs the files that took the longest to hash, with their hashing time and throughput.
+func printSlowestFiles(w io.Writer, entries []BenchmarkEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "Slowest files to hash:")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "  %s: %s, %s (%.1f MB/s)\n", entry.Path, entry.Duration.Round(time.Microsecond), FormatSize(entry.Size), entry.Throughput())
+	}
+}
+
+// printNameConflicts outputs the files sharing the same name but having different content.
+func printNameConflicts(w io.Writer, conflicts map[string][]*FileInfo) {
+	if len(conflicts) == 0 {
+		fmt.Fprintln(w, "No name conflicts found")
+		return
+	}
+
+	names := make([]string, 0, len(conflicts))
+	for name := range conflicts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "Found %d name(s) shared by files with different content:\n\n", len(conflicts))
+	for _, name := range names {
+		fmt.Fprintf(w, "Name: %s\n", name)
+		for _, file := range conflicts[name] {
+			fmt.Fprintf(w, "    - %s (%s)\n", file.Path, FormatSize(file.Size))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// printTopChanged outputs the most modified paths across snapshots, with their number of modifications.
+func printTopChanged(w io.Writer, counts []PathCount) {
+	if len(counts) == 0 {
+		fmt.Fprintln(w, "No modified files found")
+		return
+	}
+
+	fmt.Fprintf(w, "Top %d most changed file(s):\n", len(counts))
+	for _, count := range counts {
+		fmt.Fprintf(w, "  %4d  %s\n", count.Count, count.Path)
+	}
+}
+
+// printChangeRate outputs the proportion of changes out of the total number of files, e.g. "Change rate: 2.3% (31 of 1345 files)".
+func printChangeRate(w io.Writer, comparison *Comparison, totalFiles int) {
+	fmt.Fprintf(w, "Change rate: %.1f%% (%d of %d files)\n", comparison.ChangeRate(totalFiles)*100, comparison.TotalChanges(), totalFiles)
+}
+
+// printRestoreResult outputs the files restored from a backup, or that would be restored in a dry run,
+// and the ones that could not be found in the backup.
+func printRestoreResult(w io.Writer, result *RestoreResult, dryRun bool) {
+	verb := "Restored"
+	if dryRun {
+		verb = "Would restore"
+	}
+
+	for _, path := range result.RestoredPaths {
+		fmt.Fprintf(w, "%s %s\n", verb, path)
+	}
+	for _, path := range result.NotFoundPaths {
+		fmt.Fprintf(w, "Not found in backup: %s\n", path)
+	}
+
+	fmt.Fprintf(w, "%s %d file(s), %d not found in backup, %d skipped\n", verb, result.Restored, result.NotFound, result.Skipped)
+}
+
+// printVerifyAgainstResult outputs the files whose indexed hash differs from their checksum, the ones that are
+// not indexed, then how many files of each kind.
+func printVerifyAgainstResult(w io.Writer, result *VerifyAgainstResult) {
+	for _, path := range result.Mismatched {
+		fmt.Fprintf(w, "MISMATCH %s\n", path)
+	}
+	for _, path := range result.Missing {
+		fmt.Fprintf(w, "MISSING %s\n", path)
+	}
+
+	fmt.Fprintf(w, "OK: %d, MISMATCH: %d (stored differs), MISSING: %d (path not in index)\n",
+		result.OK, len(result.Mismatched), len(result.Missing))
+}
+
+// printRenameActions outputs the files renamed, or that would be renamed in a dry run, and how many.
+func printRenameActions(w io.Writer, actions []RenameAction, dryRun bool) {
+	verb := "Renamed"
+	if dryRun {
+		verb = "Would rename"
+	}
+
+	for _, action := range actions {
+		fmt.Fprintf(w, "%s %s -> %s\n", verb, action.OldPath, action.NewPath)
+	}
+	fmt.Fprintf(w, "%s %d file(s)\n", verb, len(actions))
+}
+
+// printDedupSessionResult outputs how many files were deleted in an interactive dedup session, or would be in a dry run.
+func printDedupSessionResult(w io.Writer, result *DedupSessionResult, dryRun bool) {
+	verb := "Deleted"
+	if dryRun {
+		verb = "Would delete"
+	}
+	fmt.Fprintf(w, "%s %d file(s) (%s), %d group(s) skipped\n", verb, result.Deleted, FormatSize(result.FreedBytes), result.Skipped)
+}
+
+// printCollisionGroups outputs the hashes shared by files of different sizes, with the size of each file.
+func printCollisionGroups(w io.Writer, collisions []CollisionGroup) {
+	if len(collisions) == 0 {
+		fmt.Fprintln(w, "Hash collisions: none")
+		return
+	}
+
+	fmt.Fprintf(w, "Hash collisions: %d (files with the same hash but different sizes, the index may be corrupted)\n", len(collisions))
+	for _, group := range collisions {
+		files := make([]string, len(group.Files))
+		for i, file := range group.Files {
+			files[i] = fmt.Sprintf("%s (%s)", file.Path, FormatSize(file.Size))
+		}
+		fmt.Fprintf(w, "  - %s: %s\n", group.Hash, strings.Join(files, ", "))
+	}
+}
+
+// printDiagnosis outputs a health report of the index: the hashes whose files no longer exist,
+// the consistency problems of the index data and the empty files.
+func printDiagnosis(w io.Writer, orphanedHashes []string, validationErr error, emptyFiles []*FileInfo) {
+	if len(orphanedHashes) == 0 {
+		fmt.Fprintln(w, "Orphaned hashes: none")
+	} else {
+		fmt.Fprintf(w, "Orphaned hashes: %d (all their files no longer exist, run 'bff gc' to remove them)\n", len(orphanedHashes))
+		for _, hash := range orphanedHashes {
+			fmt.Fprintf(w, "  - %s\n", hash)
+		}
+	}
+
+	if validationErr == nil {
+		fmt.Fprintln(w, "Index data: valid")
+	} else {
+		fmt.Fprintln(w, "Index data: invalid")
+		for _, line := range strings.Split(validationErr.Error(), "\n") {
+			fmt.Fprintf(w, "  - %s\n", line)
+		}
+	}
+
+	if len(emptyFiles) == 0 {
+		fmt.Fprintln(w, "Empty files: none")
+	} else {
+		fmt.Fprintf(w, "Empty files: %d\n", len(emptyFiles))
+		for _, file := range emptyFiles {
+			fmt.Fprintf(w, "  - %s\n", file.Path)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintIndexResult(t *testing.T) {
+	var buf bytes.Buffer
+	printIndexResult(&buf, &IndexResult{FileCount: 1234, BytesRead: 3 << 30, Duration: 2 * time.Second})
+
+	expected := "Indexed 1234 files (3.0 GB) in 2.0s (1.5 GB/s)\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestPrintUpdateResult(t *testing.T) {
+	var buf bytes.Buffer
+	result := &IndexResult{FileCount: 1234, Reused: 1222, Duration: 1200 * time.Millisecond}
+	printUpdateResult(&buf, result)
+
+	expected := "Updated 12 of 1234 files in 1.2s\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	printSkippedUnchanged(&buf, result)
+
+	expected = "Updated 12 files, skipped 1222 unchanged in 1.2s\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestPrintNameMatches(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/tmp"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"hash1": {{Path: "a.jpg", Size: 2048}, {Path: "copy/a.jpg", Size: 2048}},
+		"hash2": {{Path: "b.jpg", Size: 10}},
+	}
+
+	var buf bytes.Buffer
+	printNameMatches(&buf, idx, "b.*", []*FileInfo{{Path: "b.jpg", Size: 10}})
+	if !strings.Contains(buf.String(), "b.jpg (10 B)\n") {
+		t.Errorf("expected b.jpg without duplicates in output, got %q", buf.String())
+	}
+
+	buf.Reset()
+	printNameMatches(&buf, idx, "a.jpg", idx.FilesByContentHash["hash1"])
+	if !strings.Contains(buf.String(), "a.jpg (2.0 KB, has duplicates)") {
+		t.Errorf("expected a.jpg with duplicates in output, got %q", buf.String())
+	}
+
+	buf.Reset()
+	printNameMatches(&buf, idx, "*.png", []*FileInfo{})
+	if !strings.Contains(buf.String(), "No matches found") {
+		t.Errorf("expected no matches message, got %q", buf.String())
+	}
+}
+
+func TestPrintChangeRate(t *testing.T) {
+	comparison := &Comparison{Modified: make([]string, 31)}
+
+	var buf bytes.Buffer
+	printChangeRate(&buf, comparison, 1345)
+
+	expected := "Change rate: 2.3% (31 of 1345 files)\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestFileDetails(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	file := &FileInfo{Path: "report.pdf", Size: 123 * 1024, ModTime: modTime}
+
+	tests := []struct {
+		name     string
+		details  fileDetails
+		expected string
+	}{
+		{"none", fileDetails{}, ""},
+		{"size", fileDetails{size: true}, " (123.0 KB)"},
+		{"mtime", fileDetails{mtime: true}, " (2024-01-02T15:04:05Z)"},
+		{"size_and_mtime", fileDetails{size: true, mtime: true}, " (123.0 KB, 2024-01-02T15:04:05Z)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.details.format(file); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPrintFindMatchesWithSize(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/tmp"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"hash1": {{Path: "a.txt", Size: 1536}, {Path: "copy/a.txt", Size: 1536}, {Path: "b.txt", Size: 1536}},
+		"hash2": {{Path: "unique.txt", Size: 10}},
+	}
+
+	var buf bytes.Buffer
+	printFindMatches(&buf, idx, "a.txt", fileDetails{size: true})
+	output := buf.String()
+
+	for _, expected := range []string{"  - copy/a.txt (1.5 KB)\n", "  - b.txt (1.5 KB)\n"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %q in output, got %q", expected, output)
+		}
+	}
+	if strings.Contains(output, "  - a.txt") {
+		t.Errorf("expected target file not to be listed, got %q", output)
+	}
+
+	buf.Reset()
+	printFindMatches(&buf, idx, "unique.txt", fileDetails{size: true})
+	if buf.String() != "File 'unique.txt' has no duplicates\n" {
+		t.Errorf("unexpected output for a file without duplicates: %q", buf.String())
+	}
+}
+
+func TestPrintExtensionStats(t *testing.T) {
+	var buf bytes.Buffer
+	printExtensionStats(&buf, map[string]*ExtensionStats{
+		"go":  {FileCount: 342, TotalSize: 2 << 20},
+		"jpg": {FileCount: 1245, TotalSize: 4 << 30},
+		"":    {FileCount: 3, TotalSize: 1024},
+	})
+
+	expected := "jpg: 1245 files, 4.0 GB | go: 342 files, 2.0 MB | (none): 3 files, 1.0 KB\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Types of patch operations.
+const (
+	PatchAdd    = "add"
+	PatchDelete = "delete"
+	PatchMove   = "move"
+	PatchModify = "modify"
+)
+
+// PatchOperation is a change of a patch, with paths relative to the compared directory using forward slashes.
+type PatchOperation struct {
+	Op   string `json:"op"`
+	Path string `json:"path,omitempty"` // Not set for moves.
+	From string `json:"from,omitempty"` // Only set for moves.
+	To   string `json:"to,omitempty"`   // Only set for moves.
+}
+
+// ToPatch returns the changes of the files as patch operations: the added files, then the deleted, moved and modified
+// ones, each sorted by path. Truncated and unstable files and permission changes are modifications.
+func (c *Comparison) ToPatch() []PatchOperation {
+	ops := []PatchOperation{}
+	for _, path := range sortedPaths(c.Added) {
+		ops = append(ops, PatchOperation{Op: PatchAdd, Path: filepath.ToSlash(path)})
+	}
+	for _, path := range sortedPaths(c.Deleted) {
+		ops = append(ops, PatchOperation{Op: PatchDelete, Path: filepath.ToSlash(path)})
+	}
+
+	moves := make(map[string]string, len(c.RenamedOrMoved))
+	from := make([]string, 0, len(c.RenamedOrMoved))
+	for _, file := range c.RenamedOrMoved {
+		moves[file.OldPath] = file.NewPath
+		from = append(from, file.OldPath)
+	}
+	for _, path := range sortedPaths(from) {
+		ops = append(ops, PatchOperation{Op: PatchMove, From: filepath.ToSlash(path), To: filepath.ToSlash(moves[path])})
+	}
+
+	modified := append([]string{}, c.Modified...)
+	for _, file := range c.Truncated {
+		modified = append(modified, file.Path)
+	}
+	modified = append(modified, c.Unstable...)
+	for _, change := range c.PermissionsChanged {
+		modified = append(modified, change.Path)
+	}
+	for _, path := range sortedPaths(modified) {
+		ops = append(ops, PatchOperation{Op: PatchModify, Path: filepath.ToSlash(path)})
+	}
+
+	return ops
+}
+
+// SavePatch saves patch operations as a JSON file.
+func SavePatch(ops []PatchOperation, path string) error {
+	data, err := json.MarshalIndent(ops, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal patch: %w", err)
+	}
+
+	if err := atomicWriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write patch: %w", err)
+	}
+
+	return nil
+}
+
+// LoadPatch loads patch operations saved with SavePatch.
+func LoadPatch(path string) ([]PatchOperation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patch: %w", err)
+	}
+
+	var ops []PatchOperation
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, fmt.Errorf("failed to parse patch: %w", err)
+	}
+
+	return ops, nil
+}
+
+// ApplyPatch moves and deletes the files of the directory root according to the patch operations, in order, e.g. to
+// replay on a copy of a directory the changes made to the original. Additions and modifications are only informational,
+// as the patch does not contain the content of the files. Moves never overwrite existing files.
+// It stops at the first operation that fails, or that is invalid.
+func ApplyPatch(ops []PatchOperation, root string) error {
+	for _, op := range ops {
+		switch op.Op {
+		case PatchAdd, PatchModify:
+			continue
+
+		case PatchDelete:
+			path, err := patchPath(root, op.Path)
+			if err != nil {
+				return err
+			}
+			if err := os.Remove(longPath(path)); err != nil {
+				return fmt.Errorf("failed to delete %s: %w", op.Path, err)
+			}
+
+		case PatchMove:
+			from, err := patchPath(root, op.From)
+			if err != nil {
+				return err
+			}
+			to, err := patchPath(root, op.To)
+			if err != nil {
+				return err
+			}
+
+			if _, err := os.Lstat(longPath(to)); err == nil {
+				return fmt.Errorf("failed to move %s to %s: %w", op.From, op.To, os.ErrExist)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to move %s to %s: %w", op.From, op.To, err)
+			}
+			if err := os.MkdirAll(longPath(filepath.Dir(to)), 0755); err != nil {
+				return fmt.Errorf("failed to create directory for %s: %w", op.To, err)
+			}
+			if err := os.Rename(longPath(from), longPath(to)); err != nil {
+				return fmt.Errorf("failed to move %s to %s: %w", op.From, op.To, err)
+			}
+
+		default:
+			return fmt.Errorf("unknown patch operation '%s'", op.Op)
+		}
+	}
+
+	return nil
+}
+
+// patchPath returns the absolute path of a path of a patch operation, checking that it is in the directory root.
+func patchPath(root string, path string) (string, error) {
+	relPath := filepath.Clean(filepath.FromSlash(path))
+	if path == "" || filepath.IsAbs(relPath) || relPath == "." || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid path '%s' in patch: it must be relative to the directory and inside of it", path)
+	}
+	return filepath.Join(root, relPath), nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestToPatch(t *testing.T) {
+	comparison := &Comparison{
+		Added:          []string{"foo.txt"},
+		Modified:       []string{"c.txt"},
+		Deleted:        []string{"bar.txt"},
+		RenamedOrMoved: []RenamedOrMovedFile{{OldPath: "a.txt", NewPath: filepath.Join("dir", "b.txt")}},
+		Truncated:      []TruncatedFile{{Path: "empty.txt", OriginalSize: 10}},
+	}
+
+	expected := []PatchOperation{
+		{Op: PatchAdd, Path: "foo.txt"},
+		{Op: PatchDelete, Path: "bar.txt"},
+		{Op: PatchMove, From: "a.txt", To: "dir/b.txt"},
+		{Op: PatchModify, Path: "c.txt"},
+		{Op: PatchModify, Path: "empty.txt"},
+	}
+	if ops := comparison.ToPatch(); !reflect.DeepEqual(ops, expected) {
+		t.Errorf("expected %v, got %v", expected, ops)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	original := t.TempDir()
+	replica := t.TempDir()
+	for _, dir := range []string{original, replica} {
+		for name, content := range map[string]string{"a.txt": "moved", "bar.txt": "deleted", "c.txt": "kept"} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: original})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(original, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(original, "a.txt"), filepath.Join(original, "dir", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(original, "bar.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	comparison, err := idx.Compare()
+	if err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+	patchPath := filepath.Join(t.TempDir(), "patch.json")
+	if err := SavePatch(comparison.ToPatch(), patchPath); err != nil {
+		t.Fatalf("SavePatch failed: %v", err)
+	}
+	ops, err := LoadPatch(patchPath)
+	if err != nil {
+		t.Fatalf("LoadPatch failed: %v", err)
+	}
+
+	if err := ApplyPatch(ops, replica); err != nil {
+		t.Fatalf("ApplyPatch failed: %v", err)
+	}
+	for _, path := range []string{"a.txt", "bar.txt"} {
+		if _, err := os.Stat(filepath.Join(replica, path)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to no longer exist, got %v", path, err)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(replica, "dir", "b.txt")); err != nil || string(data) != "moved" {
+		t.Errorf("expected the file to be moved, got %q, %v", data, err)
+	}
+
+	invalid := []PatchOperation{{Op: PatchDelete, Path: "../c.txt"}}
+	if err := ApplyPatch(invalid, filepath.Join(replica, "dir")); err == nil {
+		t.Errorf("expected an error for a path outside of the directory")
+	}
+	if _, err := os.Stat(filepath.Join(replica, "c.txt")); err != nil {
+		t.Errorf("expected the file outside of the directory to be kept: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// ProgressPrinter prints the progress of an indexing run at most once per interval,
+// e.g. "Indexed 1234/5678 files (21.7%)... photos/cat.jpg".
+type ProgressPrinter struct {
+	w         io.Writer
+	interval  time.Duration
+	lastPrint time.Time
+}
+
+// NewProgressPrinter returns a printer writing to w, whose first progress line is printed once the interval
+// has elapsed.
+func NewProgressPrinter(w io.Writer, interval time.Duration) *ProgressPrinter {
+	return &ProgressPrinter{w: w, interval: interval, lastPrint: time.Now()}
+}
+
+// MaybePrint prints the number of files indexed out of the total, along with the path of the last one,
+// if the interval has elapsed since the last progress line. The final line, once all the files are indexed,
+// is always printed.
+func (p *ProgressPrinter) MaybePrint(indexed, total int, currentPath string) {
+	if indexed < total && time.Since(p.lastPrint) < p.interval {
+		return
+	}
+
+	percent := 100.0
+	if total > 0 {
+		percent = float64(indexed) / float64(total) * 100
+	}
+	fmt.Fprintf(p.w, "Indexed %d/%d files (%.1f%%)... %s\n", indexed, total, percent, currentPath)
+	p.lastPrint = time.Now()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressPrinter(t *testing.T) {
+	testDir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		if err := os.WriteFile(filepath.Join(testDir, fmt.Sprintf("file%d.txt", i)), []byte{byte(i)}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		interval      time.Duration
+		expectedLines func(lines []string) bool
+	}{
+		{
+			name:     "short interval",
+			interval: time.Microsecond,
+			expectedLines: func(lines []string) bool {
+				return len(lines) > 1 && strings.HasPrefix(lines[0], "Indexed ") && strings.Contains(lines[0], "/5 files (")
+			},
+		},
+		{
+			name:     "long interval",
+			interval: time.Hour,
+			expectedLines: func(lines []string) bool {
+				return len(lines) == 1
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+			if _, err := idx.IndexWithProgress(context.Background(), NewProgressPrinter(&buf, tt.interval)); err != nil {
+				t.Fatalf("IndexWithProgress failed: %v", err)
+			}
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if !tt.expectedLines(lines) {
+				t.Errorf("unexpected progress lines: %q", lines)
+			}
+			if last := lines[len(lines)-1]; !strings.HasPrefix(last, "Indexed 5/5 files (100.0%)... ") {
+				t.Errorf("expected the final progress line to be printed, got %q", last)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// bytesPerMB is the number of bytes in a megabyte for rate limits.
+const bytesPerMB = 1 << 20
+
+// readRate is a maximum read rate shared by several readers, e.g. the files hashed in parallel by a scan, so that
+// it applies to all their reads together rather than to each of them.
+type readRate struct {
+	mbps      float64
+	rateBytes float64 // Maximum number of bytes read per second.
+
+	mu   sync.Mutex
+	next time.Time // Time until which the bytes read so far take at the limited rate.
+}
+
+// newReadRate returns a read rate of at most mbps megabytes per second.
+func newReadRate(mbps float64) *readRate {
+	return &readRate{mbps: mbps, rateBytes: mbps * bytesPerMB}
+}
+
+// wait sleeps until n more bytes can have been read at the limited rate, after the bytes already read by all readers.
+func (r *readRate) wait(n int) {
+	r.mu.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	r.next = r.next.Add(time.Duration(float64(n) / r.rateBytes * float64(time.Second)))
+	until := r.next
+	r.mu.Unlock()
+
+	time.Sleep(time.Until(until))
+}
+
+// rateLimiter is a reader throttling the reads of the wrapped reader to a maximum rate.
+type rateLimiter struct {
+	reader io.Reader
+	rate   *readRate
+}
+
+// newRateLimiter returns a reader reading from r at most at the given rate, shared with the other readers using it.
+func newRateLimiter(r io.Reader, rate *readRate) *rateLimiter {
+	return &rateLimiter{reader: r, rate: rate}
+}
+
+// Read reads from the wrapped reader, then sleeps for the time reading that many bytes should take at the limited rate.
+func (r *rateLimiter) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.rate.wait(n)
+	}
+	return n, err
+}
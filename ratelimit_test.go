@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProcessFileWithRateLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping rate limit test in short mode")
+	}
+
+	absPath := filepath.Join(t.TempDir(), "large.bin")
+	data := make([]byte, 5*bytesPerMB)
+	if err := os.WriteFile(absPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	hash, _, err := processFileWithRateLimit(absPath, "large.bin", DefaultHashAlgorithm, newReadRate(1), false)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("processFileWithRateLimit failed: %v", err)
+	}
+
+	if hash != computeHash(data) {
+		t.Errorf("expected the hash not to be affected by the rate limit")
+	}
+	if elapsed < 4500*time.Millisecond || elapsed > 10*time.Second {
+		t.Errorf("expected hashing 5 MB at 1 MB/s to take about 5s, took %v", elapsed)
+	}
+}
+
+func TestScanRateLimitSharedByWorkers(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping rate limit test in short mode")
+	}
+
+	testDir := t.TempDir()
+	for i := 0; i < 4; i++ {
+		data := make([]byte, bytesPerMB)
+		data[0] = byte(i)
+		if err := os.WriteFile(filepath.Join(testDir, fmt.Sprintf("file%d.bin", i)), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir, Workers: 4})
+	idx.RateLimitMBps = 2
+	start := time.Now()
+	if _, err := idx.scan(context.Background()); err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	// Each worker limited on its own would read the 4 MB in about 0.5s.
+	if elapsed < 1800*time.Millisecond || elapsed > 6*time.Second {
+		t.Errorf("expected hashing 4 MB with 4 workers at 2 MB/s to take about 2s, took %v", elapsed)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultRemoteTimeout is the maximum time to fetch a remote index file.
+const DefaultRemoteTimeout = 30 * time.Second
+
+// remoteIndexCacheTTL is how long an index file fetched by LoadFromHTTP is reused from its cache file instead of
+// being fetched again, e.g. when retrying a comparison that failed.
+const remoteIndexCacheTTL = 5 * time.Minute
+
+// LoadFromHTTP is like LoadFrom, but fetches the index file with a GET request to the URL, e.g. the index of a
+// directory of a shared storage served by a central server. The request uses basic authentication with RemoteAuth
+// if set, and stops when the context is cancelled, e.g. on timeout. The fetched index file is cached in the cache
+// directory of the user, which is reused for remoteIndexCacheTTL with the same URL and credentials.
+func (idx *Index) LoadFromHTTP(ctx context.Context, url string) error {
+	cachePath, err := idx.remoteIndexCachePath(url)
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(cachePath); err != nil || time.Since(info.ModTime()) > remoteIndexCacheTTL {
+		data, err := idx.fetchIndex(ctx, url)
+		if err != nil {
+			return err
+		}
+		if err := atomicWriteFile(cachePath, data, 0600); err != nil {
+			return fmt.Errorf("failed to cache remote index: %w", err)
+		}
+	}
+
+	return idx.LoadFrom(cachePath)
+}
+
+// fetchIndex returns the content of the index file at the URL.
+func (idx *Index) fetchIndex(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote index URL: %w", err)
+	}
+	if idx.RemoteAuth != "" {
+		user, password, _ := strings.Cut(idx.RemoteAuth, ":")
+		req.SetBasicAuth(user, password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch remote index: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch remote index: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch remote index: %w", err)
+	}
+	// Invalid data is not cached, so that it is fetched again.
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("failed to parse remote index: invalid JSON")
+	}
+
+	return data, nil
+}
+
+// remoteIndexCachePath returns the path of the file caching the index file at the URL, in the bff directory of the
+// cache directory of the user, which is created if needed. The credentials are part of its name so that an index
+// fetched with some credentials is not reused with others.
+func (idx *Index) remoteIndexCachePath(url string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the cache directory: %w", err)
+	}
+	cacheDir = filepath.Join(cacheDir, "bff")
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create the cache directory: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(idx.RemoteAuth + "\n" + url))
+	return filepath.Join(cacheDir, "remote-"+hex.EncodeToString(sum[:8])+".json"), nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadFromHTTP(t *testing.T) {
+	cacheDir := t.TempDir()
+	for _, env := range []string{"XDG_CACHE_HOME", "HOME", "LocalAppData"} {
+		t.Setenv(env, cacheDir)
+	}
+
+	remoteDir := t.TempDir()
+	for name, content := range map[string]string{"kept.txt": "kept", "deleted.txt": "deleted"} {
+		if err := os.WriteFile(filepath.Join(remoteDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+	remote := NewIndexWithOptions(IndexOptions{RootPath: remoteDir})
+	if _, err := remote.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+	data, err := remote.marshal()
+	if err != nil {
+		t.Fatalf("failed to marshal index: %v", err)
+	}
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if user, password, ok := r.BasicAuth(); !ok || user != "alice" || password != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/bff.json":
+			w.Write(data)
+		case "/invalid.json":
+			w.Write([]byte("not an index"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	localDir := t.TempDir()
+	for name, content := range map[string]string{"kept.txt": "kept", "added.txt": "added"} {
+		if err := os.WriteFile(filepath.Join(localDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: localDir})
+	if err := idx.LoadFromHTTP(context.Background(), server.URL+"/bff.json"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected an unauthorized error without credentials, got %v", err)
+	}
+
+	idx.RemoteAuth = "alice:secret"
+	if err := idx.LoadFromHTTP(context.Background(), server.URL+"/bff.json"); err != nil {
+		t.Fatalf("LoadFromHTTP failed: %v", err)
+	}
+	if idx.AbsPath != localDir {
+		t.Errorf("expected the local directory to be kept, got %s", idx.AbsPath)
+	}
+
+	result, err := idx.Compare()
+	if err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+	if len(result.Added) != 1 || result.Added[0] != "added.txt" {
+		t.Errorf("expected added.txt added, got %v", result.Added)
+	}
+	if len(result.Deleted) != 1 || result.Deleted[0] != "deleted.txt" {
+		t.Errorf("expected deleted.txt deleted, got %v", result.Deleted)
+	}
+
+	// The cached index file is used instead of fetching it again.
+	fetched := requests.Load()
+	cached := NewIndexWithOptions(IndexOptions{RootPath: localDir})
+	cached.RemoteAuth = "alice:secret"
+	if err := cached.LoadFromHTTP(context.Background(), server.URL+"/bff.json"); err != nil {
+		t.Fatalf("LoadFromHTTP failed with the cache: %v", err)
+	}
+	if requests.Load() != fetched {
+		t.Error("expected the cached index file to be used")
+	}
+
+	// The index file cached with other credentials is not used.
+	other := NewIndexWithOptions(IndexOptions{RootPath: localDir})
+	other.RemoteAuth = "mallory:secret"
+	if err := other.LoadFromHTTP(context.Background(), server.URL+"/bff.json"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected an unauthorized error with other credentials, got %v", err)
+	}
+	if requests.Load() != fetched+1 {
+		t.Error("expected the index file to be fetched again with other credentials")
+	}
+
+	for _, path := range []string{"/invalid.json", "/missing.json"} {
+		if err := idx.LoadFromHTTP(context.Background(), server.URL+path); err == nil {
+			t.Errorf("expected an error for %s", path)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	if err := idx.LoadFromHTTP(ctx, server.URL+"/other.json"); err == nil {
+		t.Error("expected an error after the timeout")
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultRenameSuffix is the suffix added by RenameDuplicates to the names of the copies, with <N> the number of the copy.
+const DefaultRenameSuffix = "_dup" + renameCounter
+
+// renameCounter is replaced by the number of the copy in the suffixes of RenameDuplicates.
+const renameCounter = "<N>"
+
+// DefaultKeepStrategy is the strategy choosing the canonical copy of the duplicates by default.
+const DefaultKeepStrategy = "first"
+
+// keepStrategies tell whether a file is a better canonical copy than another one, by strategy name.
+var keepStrategies = map[string]func(a, b *FileInfo) bool{
+	"first": func(a, b *FileInfo) bool {
+		return a.Path < b.Path
+	},
+	"shortest": func(a, b *FileInfo) bool {
+		if len(a.Path) != len(b.Path) {
+			return len(a.Path) < len(b.Path)
+		}
+		return a.Path < b.Path
+	},
+	"oldest": func(a, b *FileInfo) bool {
+		if !a.ModTime.Equal(b.ModTime) {
+			return a.ModTime.Before(b.ModTime)
+		}
+		return a.Path < b.Path
+	},
+	"newest": func(a, b *FileInfo) bool {
+		if !a.ModTime.Equal(b.ModTime) {
+			return a.ModTime.After(b.ModTime)
+		}
+		return a.Path < b.Path
+	},
+}
+
+// RenameAction is the renaming of a duplicate file, with paths relative to the indexed directory.
+type RenameAction struct {
+	OldPath string
+	NewPath string
+}
+
+// RenameDuplicates renames all the copies of each group of duplicates but the canonical one, chosen with keepStrategy
+// (first by path, shortest path, oldest or newest), adding the suffix to their name before the extension, e.g.
+// photo_dup1.jpg and photo_dup2.jpg for the suffix "_dup<N>". <N> is replaced by the number of the copy in its group,
+// skipping the names already in use, and is added at the end of the suffix if it does not contain it.
+// The index file is then saved with the new paths. It returns the renamings done, or that would be done with DryRun.
+// The index must be loaded before calling this method.
+func (idx *Index) RenameDuplicates(suffix string, keepStrategy string) ([]RenameAction, error) {
+	if idx.isArchive() {
+		return nil, fmt.Errorf("cannot rename the files of %s: %w", idx.AbsPath, errArchiveIndex)
+	}
+
+	groups, err := idx.canonicalGroups(keepStrategy)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(suffix, renameCounter) {
+		suffix += renameCounter
+	}
+
+	taken := make(map[string]bool)
+	for _, path := range idx.AllPaths() {
+		taken[path] = true
+	}
+
+	actions := []RenameAction{}
+	renamedFiles := []*FileInfo{}
+	for _, files := range groups {
+		n := 0
+		for _, file := range files[1:] {
+			var newPath string
+			for {
+				n++
+				newPath = renamedPath(file.Path, suffix, n)
+				if taken[newPath] {
+					continue
+				}
+				_, err := os.Lstat(longPath(filepath.Join(idx.AbsPath, newPath)))
+				if os.IsNotExist(err) {
+					break
+				}
+				if err != nil {
+					return nil, fmt.Errorf("failed to stat %s: %w", newPath, err)
+				}
+			}
+
+			taken[newPath] = true
+			actions = append(actions, RenameAction{OldPath: file.Path, NewPath: newPath})
+			renamedFiles = append(renamedFiles, file)
+		}
+	}
+
+	if idx.DryRun {
+		return actions, nil
+	}
+
+	for i, action := range actions {
+		oldAbsPath := longPath(filepath.Join(idx.AbsPath, action.OldPath))
+		newAbsPath := longPath(filepath.Join(idx.AbsPath, action.NewPath))
+		if err := os.Rename(oldAbsPath, newAbsPath); err != nil {
+			// The files renamed so far are kept in the index.
+			if saveErr := idx.save(); saveErr != nil {
+				return actions[:i], fmt.Errorf("failed to rename %s: %w (%v)", action.OldPath, err, saveErr)
+			}
+			return actions[:i], fmt.Errorf("failed to rename %s: %w", action.OldPath, err)
+		}
+		renamedFiles[i].Path = action.NewPath
+	}
+
+	if err := idx.save(); err != nil {
+		return actions, err
+	}
+
+	return actions, nil
+}
+
+// canonicalGroups returns the files of each group of duplicates, the ones wasting the most space first,
+// with the canonical copy chosen with keepStrategy first.
+func (idx *Index) canonicalGroups(keepStrategy string) ([][]*FileInfo, error) {
+	prefer, exists := keepStrategies[keepStrategy]
+	if !exists {
+		return nil, fmt.Errorf("unknown keep strategy '%s' (expected 'first', 'shortest', 'oldest' or 'newest')", keepStrategy)
+	}
+
+	groups := [][]*FileInfo{}
+	for _, group := range idx.SortedDuplicateGroups() {
+		files := append([]*FileInfo{}, group.Files...)
+		sort.SliceStable(files, func(i, j int) bool {
+			return prefer(files[i], files[j])
+		})
+		groups = append(groups, files)
+	}
+
+	return groups, nil
+}
+
+// renamedPath returns the path with the suffix added to the file name before its extension,
+// its counter replaced by n.
+func renamedPath(path string, suffix string, n int) string {
+	ext := filepath.Ext(path)
+	name := strings.TrimSuffix(filepath.Base(path), ext)
+	return filepath.Join(filepath.Dir(path), name+strings.ReplaceAll(suffix, renameCounter, strconv.Itoa(n))+ext)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRenameDuplicates(t *testing.T) {
+	tests := []struct {
+		name     string
+		dryRun   bool
+		suffix   string
+		expected []RenameAction
+	}{
+		{
+			name:   "rename",
+			suffix: DefaultRenameSuffix,
+			expected: []RenameAction{
+				{OldPath: "b.jpg", NewPath: "b_dup1.jpg"},
+				{OldPath: filepath.Join("dir", "c.jpg"), NewPath: filepath.Join("dir", "c_dup2.jpg")},
+			},
+		},
+		{
+			name:   "dry run",
+			dryRun: true,
+			suffix: "-copy",
+			expected: []RenameAction{
+				{OldPath: "b.jpg", NewPath: "b-copy1.jpg"},
+				{OldPath: filepath.Join("dir", "c.jpg"), NewPath: filepath.Join("dir", "c-copy2.jpg")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDir := t.TempDir()
+			if err := os.Mkdir(filepath.Join(testDir, "dir"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			for path, content := range map[string]string{
+				"a.jpg":                       "photo",
+				"b.jpg":                       "photo",
+				filepath.Join("dir", "c.jpg"): "photo",
+				// The name of the first copy is taken, so it gets the next number.
+				filepath.Join("dir", "c_dup1.jpg"): "other",
+				"unique.jpg":                       "unique",
+			} {
+				if err := os.WriteFile(filepath.Join(testDir, path), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+			if _, err := idx.IndexWithResult(); err != nil {
+				t.Fatalf("indexing failed: %v", err)
+			}
+			idx.DryRun = tt.dryRun
+
+			actions, err := idx.RenameDuplicates(tt.suffix, DefaultKeepStrategy)
+			if err != nil {
+				t.Fatalf("RenameDuplicates failed: %v", err)
+			}
+			if !reflect.DeepEqual(actions, tt.expected) {
+				t.Errorf("expected renames %v, got %v", tt.expected, actions)
+			}
+
+			if _, err := os.Stat(filepath.Join(testDir, "a.jpg")); err != nil {
+				t.Errorf("expected the canonical file to be kept: %v", err)
+			}
+			for _, action := range actions {
+				_, err := os.Stat(filepath.Join(testDir, action.NewPath))
+				if renamed := err == nil; renamed == tt.dryRun {
+					t.Errorf("expected %s to be renamed: %v, got %v", action.OldPath, !tt.dryRun, renamed)
+				}
+			}
+
+			loaded := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+			if err := loaded.Load(); err != nil {
+				t.Fatalf("failed to load the index: %v", err)
+			}
+			result, err := loaded.Compare()
+			if err != nil {
+				t.Fatalf("Compare() failed: %v", err)
+			}
+			if result.HasChanges() {
+				t.Errorf("expected the index to match the files, got %+v", result)
+			}
+		})
+	}
+}
+
+func TestRenameDuplicatesKeepStrategy(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: t.TempDir()})
+	idx.DryRun = true
+	idx.FilesByContentHash["hash"] = []*FileInfo{{Path: filepath.Join("long", "name.txt")}, {Path: "z.txt"}}
+
+	actions, err := idx.RenameDuplicates(DefaultRenameSuffix, "shortest")
+	if err != nil {
+		t.Fatalf("RenameDuplicates failed: %v", err)
+	}
+	expected := []RenameAction{{OldPath: filepath.Join("long", "name.txt"), NewPath: filepath.Join("long", "name_dup1.txt")}}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Errorf("expected renames %v, got %v", expected, actions)
+	}
+
+	if _, err := idx.RenameDuplicates(DefaultRenameSuffix, "unknown"); err == nil {
+		t.Errorf("expected an error for an unknown keep strategy")
+	}
+}
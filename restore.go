@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// RestoreResult contains the outcome of restoring files from a backup.
+type RestoreResult struct {
+	Restored      int
+	NotFound      int // Files whose indexed content is not in the backup.
+	Skipped       int // Files not matching the filter.
+	RestoredPaths []string
+	NotFoundPaths []string
+}
+
+// RestoreFrom copies back from a backup the files that were modified, truncated or deleted since they were indexed.
+// The backup copies are looked up by content hash in the backup index, so they may have another path, and are
+// checked to still have the indexed content before being copied to targetRoot.
+// Only files matching the filter glob pattern are restored, or all of them if it is empty.
+// With DryRun, the files that would be restored are reported but not copied.
+// The index must be loaded before calling this method.
+func (idx *Index) RestoreFrom(backupIdx *Index, backupRoot string, targetRoot string, filter string) (*RestoreResult, error) {
+	if idx.HashAlgorithm != backupIdx.HashAlgorithm {
+		return nil, fmt.Errorf("cannot restore from a backup index using another hash algorithm (%s instead of %s)", backupIdx.HashAlgorithm, idx.HashAlgorithm)
+	}
+
+	comparison, err := idx.Compare()
+	if err != nil {
+		return nil, err
+	}
+
+	paths := append(append([]string{}, comparison.Modified...), comparison.Deleted...)
+	for _, file := range comparison.Truncated {
+		paths = append(paths, file.Path)
+	}
+	sort.Strings(paths)
+
+	result := &RestoreResult{RestoredPaths: []string{}, NotFoundPaths: []string{}}
+	for _, path := range paths {
+		if filter != "" && !matchesAnyPattern([]string{filter}, path) {
+			result.Skipped++
+			continue
+		}
+
+		hash, _ := idx.hashOf(path)
+		backupPath, err := findBackupCopy(backupIdx, backupRoot, hash)
+		if err != nil {
+			return result, err
+		}
+		if backupPath == "" {
+			result.NotFound++
+			result.NotFoundPaths = append(result.NotFoundPaths, path)
+			continue
+		}
+
+		if !idx.DryRun {
+			if err := restoreFile(backupPath, filepath.Join(targetRoot, path)); err != nil {
+				return result, err
+			}
+		}
+		result.Restored++
+		result.RestoredPaths = append(result.RestoredPaths, path)
+	}
+
+	return result, nil
+}
+
+// findBackupCopy returns the absolute path of a file of the backup with the given content hash,
+// or an empty path if none of the indexed backup files still has this content.
+func findBackupCopy(backupIdx *Index, backupRoot string, hash string) (string, error) {
+	for _, file := range backupIdx.FilesByContentHash[hash] {
+		absPath := filepath.Join(backupRoot, file.Path)
+		currentHash, _, err := ProcessFile(longPath(absPath), file.Path, backupIdx.HashAlgorithm)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to check backup file %s: %w", absPath, err)
+		}
+		if currentHash == hash {
+			return absPath, nil
+		}
+	}
+	return "", nil
+}
+
+// restoreFile copies the backup file to the target path, creating its directory if needed.
+func restoreFile(backupPath, targetPath string) error {
+	info, err := os.Stat(backupPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat backup file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory of %s: %w", targetPath, err)
+	}
+
+	if err := copyFile(backupPath, targetPath, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", targetPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRestoreFrom(t *testing.T) {
+	testDir := t.TempDir()
+	backupDir := t.TempDir()
+
+	files := map[string]string{
+		"modified.txt":   "original content",
+		"deleted.txt":    "deleted content",
+		"docs/notes.txt": "notes",
+		"unchanged.txt":  "unchanged",
+		"unsaved.txt":    "not in backup",
+	}
+	for path, content := range files {
+		for _, dir := range []string{testDir, backupDir} {
+			if dir == backupDir && path == "unsaved.txt" {
+				continue
+			}
+			absPath := filepath.Join(dir, path)
+			if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+				t.Fatalf("failed to create directory: %v", err)
+			}
+			if err := os.WriteFile(absPath, []byte(content), 0644); err != nil {
+				t.Fatalf("failed to create file: %v", err)
+			}
+		}
+	}
+	// The backup copy of the notes is at another path.
+	if err := os.Rename(filepath.Join(backupDir, "docs", "notes.txt"), filepath.Join(backupDir, "notes-copy.txt")); err != nil {
+		t.Fatalf("failed to rename file: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+	backupIdx := NewIndexWithOptions(IndexOptions{RootPath: backupDir})
+	if _, err := backupIdx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing backup failed: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(testDir, "modified.txt"), []byte("corrupted"), 0644); err != nil {
+		t.Fatalf("failed to modify file: %v", err)
+	}
+	for _, path := range []string{"deleted.txt", "docs/notes.txt", "unsaved.txt"} {
+		if err := os.Remove(filepath.Join(testDir, path)); err != nil {
+			t.Fatalf("failed to delete file: %v", err)
+		}
+	}
+
+	idx.DryRun = true
+	result, err := idx.RestoreFrom(backupIdx, backupDir, testDir, "")
+	if err != nil {
+		t.Fatalf("RestoreFrom() failed: %v", err)
+	}
+	if result.Restored != 3 || result.NotFound != 1 {
+		t.Errorf("expected 3 files to restore and 1 not found in dry run, got %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(testDir, "deleted.txt")); !os.IsNotExist(err) {
+		t.Error("expected no file to be restored in dry run")
+	}
+
+	idx.DryRun = false
+	result, err = idx.RestoreFrom(backupIdx, backupDir, testDir, "*.txt")
+	if err != nil {
+		t.Fatalf("RestoreFrom() failed: %v", err)
+	}
+
+	expected := &RestoreResult{
+		Restored:      3,
+		NotFound:      1,
+		RestoredPaths: []string{"deleted.txt", filepath.Join("docs", "notes.txt"), "modified.txt"},
+		NotFoundPaths: []string{"unsaved.txt"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+
+	for _, path := range []string{"modified.txt", "deleted.txt", "docs/notes.txt"} {
+		data, err := os.ReadFile(filepath.Join(testDir, path))
+		if err != nil {
+			t.Fatalf("failed to read restored file: %v", err)
+		}
+		if string(data) != files[path] {
+			t.Errorf("expected %s to be restored with %q, got %q", path, files[path], data)
+		}
+	}
+
+	comparison, err := idx.Compare()
+	if err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+	if len(comparison.Deleted) != 1 || comparison.Deleted[0] != "unsaved.txt" {
+		t.Errorf("expected only unsaved.txt to remain deleted, got %+v", comparison)
+	}
+
+	if err := os.Remove(filepath.Join(testDir, "deleted.txt")); err != nil {
+		t.Fatalf("failed to delete file: %v", err)
+	}
+	result, err = idx.RestoreFrom(backupIdx, backupDir, testDir, "docs/*")
+	if err != nil {
+		t.Fatalf("RestoreFrom() failed: %v", err)
+	}
+	if result.Restored != 0 || result.Skipped != 2 {
+		t.Errorf("expected files not matching the filter to be skipped, got %+v", result)
+	}
+}
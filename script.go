@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WriteDedupScript writes to w a shell script deleting all the copies of each group of duplicates but the canonical
+// one, chosen with keepStrategy like with RenameDuplicates, one line per file with absolute paths:
+// rm -f "<path>" # duplicate of <canonical>
+// The index must be loaded before calling this method.
+func (idx *Index) WriteDedupScript(w io.Writer, keepStrategy string) error {
+	groups, err := idx.canonicalGroups(keepStrategy)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#!/bin/sh")
+	fmt.Fprintf(bw, "# Generated by bff %s from the index of %s, check it before running it.\n", Version, shellComment(idx.AbsPath))
+	fmt.Fprintln(bw, "set -e")
+	for _, files := range groups {
+		fmt.Fprintln(bw)
+		canonical := files[0]
+		for _, file := range files[1:] {
+			fmt.Fprintf(bw, "rm -f %s # duplicate of %s\n", shellQuote(filepath.Join(idx.AbsPath, file.Path)), shellComment(canonical.Path))
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write script: %w", err)
+	}
+	return nil
+}
+
+// KeepList returns the relative paths of the canonical copy of each group of duplicates, chosen with keepStrategy
+// like with RenameDuplicates, i.e. the files to keep when deleting the other ones, sorted.
+// The index must be loaded before calling this method.
+func (idx *Index) KeepList(keepStrategy string) ([]string, error) {
+	groups, err := idx.canonicalGroups(keepStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(groups))
+	for _, files := range groups {
+		paths = append(paths, files[0].Path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// DeleteList returns the relative paths of all the copies of each group of duplicates but the canonical one, chosen
+// with keepStrategy like with RenameDuplicates, sorted. It is the complement of KeepList within the duplicates.
+// The index must be loaded before calling this method.
+func (idx *Index) DeleteList(keepStrategy string) ([]string, error) {
+	groups, err := idx.canonicalGroups(keepStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	for _, files := range groups {
+		for _, file := range files[1:] {
+			paths = append(paths, file.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// WritePathList writes the paths to a text file, one per line.
+func WritePathList(path string, paths []string) error {
+	var sb strings.Builder
+	for _, p := range paths {
+		sb.WriteString(p + "\n")
+	}
+
+	if err := atomicWriteFile(path, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// shellQuote returns s between double quotes, escaping the characters keeping their special meaning in them.
+func shellQuote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		if r == '"' || r == '\\' || r == '$' || r == '`' {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// shellComment returns s with its line breaks escaped, so that it stays in a comment.
+func shellComment(s string) string {
+	return strings.NewReplacer("\n", `\n`, "\r", `\r`).Replace(s)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteDedupScript(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"photo":  {{Path: filepath.Join("backup", "old", "photo.jpg"), Size: 10}, {Path: "photo.jpg", Size: 10}, {Path: `it's "$HOME".jpg`, Size: 10}},
+		"doc":    {{Path: "a.txt", Size: 1}, {Path: "b.txt", Size: 1}},
+		"unique": {{Path: "unique.txt", Size: 100}},
+	}
+
+	var buf bytes.Buffer
+	if err := idx.WriteDedupScript(&buf, "shortest"); err != nil {
+		t.Fatalf("WriteDedupScript failed: %v", err)
+	}
+	script := buf.String()
+
+	lines := strings.Split(script, "\n")
+	if lines[0] != "#!/bin/sh" || !strings.Contains(script, "\nset -e\n") {
+		t.Errorf("expected the script to start with a shebang and set -e, got:\n%s", script)
+	}
+
+	removed := []string{}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "rm -f ") {
+			removed = append(removed, line)
+		}
+	}
+	if len(removed) != 3 {
+		t.Fatalf("expected 3 files to be removed, got %v", removed)
+	}
+	for _, canonical := range []string{`"/data/photo.jpg"`, `"/data/a.txt"`, "unique.txt"} {
+		if strings.Contains(strings.Join(removed, "\n"), canonical+" ") {
+			t.Errorf("expected the canonical file %s not to be removed, got:\n%s", canonical, script)
+		}
+	}
+	if !strings.Contains(script, `rm -f "/data/it's \"\$HOME\".jpg" # duplicate of photo.jpg`) {
+		t.Errorf("expected the special characters of the paths to be escaped, got:\n%s", script)
+	}
+
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available to check the syntax of the script")
+	}
+	scriptPath := filepath.Join(t.TempDir(), "dedup.sh")
+	if err := os.WriteFile(scriptPath, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if output, err := exec.Command(sh, "-n", scriptPath).CombinedOutput(); err != nil {
+		t.Errorf("expected a valid script, got %v: %s", err, output)
+	}
+}
+
+func TestKeepAndDeleteLists(t *testing.T) {
+	idx := NewIndexWithOptions(IndexOptions{RootPath: "/data"})
+	idx.FilesByContentHash = map[string][]*FileInfo{
+		"photo":  {{Path: filepath.Join("backup", "old", "photo.jpg"), Size: 10}, {Path: "photo.jpg", Size: 10}, {Path: "copy.jpg", Size: 10}},
+		"doc":    {{Path: "a.txt", Size: 1}, {Path: "b.txt", Size: 1}},
+		"unique": {{Path: "unique.txt", Size: 100}},
+	}
+
+	for strategy := range keepStrategies {
+		t.Run(strategy, func(t *testing.T) {
+			keep, err := idx.KeepList(strategy)
+			if err != nil {
+				t.Fatalf("KeepList failed: %v", err)
+			}
+			del, err := idx.DeleteList(strategy)
+			if err != nil {
+				t.Fatalf("DeleteList failed: %v", err)
+			}
+
+			if len(keep) != 2 {
+				t.Errorf("expected one file kept per group, got %v", keep)
+			}
+			kept := make(map[string]bool)
+			for _, path := range keep {
+				kept[path] = true
+			}
+			union := append([]string{}, keep...)
+			for _, path := range del {
+				if kept[path] {
+					t.Errorf("expected %s not to be both kept and deleted", path)
+				}
+				union = append(union, path)
+			}
+
+			expected := []string{filepath.Join("backup", "old", "photo.jpg"), "photo.jpg", "copy.jpg", "a.txt", "b.txt"}
+			if got := sortedPaths(union); strings.Join(got, "\n") != strings.Join(sortedPaths(expected), "\n") {
+				t.Errorf("expected the lists to cover all the duplicates %v, got %v", sortedPaths(expected), got)
+			}
+		})
+	}
+
+	if _, err := idx.KeepList("largest"); err == nil {
+		t.Error("expected error for an unknown keep strategy")
+	}
+
+	listPath := filepath.Join(t.TempDir(), "keep.txt")
+	if err := WritePathList(listPath, []string{"a.txt", "photo.jpg"}); err != nil {
+		t.Fatalf("WritePathList failed: %v", err)
+	}
+	if data, err := os.ReadFile(listPath); err != nil || string(data) != "a.txt\nphoto.jpg\n" {
+		t.Errorf("expected one path per line, got %q (%v)", data, err)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+// IndexStats is a summary of the indexed files and their duplicates.
+type IndexStats struct {
+	FileCount        int    `json:"file_count"`
+	TotalSize        int64  `json:"total_size"`                   // Size of all the indexed files, duplicates included.
+	DuplicateGroups  int    `json:"duplicate_groups"`             // Number of groups of files with the same content.
+	WastedBytes      int64  `json:"wasted_bytes"`                 // Space used by all the copies but one of each group.
+	LargestGroupHash string `json:"largest_group_hash,omitempty"` // Hash of the group with the most files, if any.
+	LargestGroupSize int    `json:"largest_group_size"`           // Number of files of the largest group.
+	HashAlgorithm    string `json:"hash_algorithm"`
+}
+
+// Stats returns a summary of the indexed files and their duplicates. The largest duplicate group is the one with
+// the most files, the one with the lowest hash in case of tie. Empty files are not counted as duplicates if
+// SkipZeroBytes is set, like for FindAllDuplicates.
+// The index must be loaded before calling this method.
+func (idx *Index) Stats() IndexStats {
+	stats := IndexStats{
+		FileCount:     idx.FileCount(),
+		TotalSize:     idx.TotalIndexedBytes(),
+		HashAlgorithm: idx.HashAlgorithm,
+	}
+
+	for hash, files := range idx.FindAllDuplicates() {
+		stats.DuplicateGroups++
+		stats.WastedBytes += files[0].Size * int64(len(files)-1)
+		if len(files) > stats.LargestGroupSize || (len(files) == stats.LargestGroupSize && hash < stats.LargestGroupHash) {
+			stats.LargestGroupHash = hash
+			stats.LargestGroupSize = len(files)
+		}
+	}
+
+	return stats
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	stats := newTestDuplicatesIndex().Stats()
+
+	expected := IndexStats{
+		FileCount:        8,
+		TotalSize:        7320,
+		DuplicateGroups:  3,
+		WastedBytes:      1210,
+		LargestGroupHash: "many",
+		LargestGroupSize: 3,
+		HashAlgorithm:    DefaultHashAlgorithm,
+	}
+	if stats != expected {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatalf("failed to marshal stats: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal stats: %v", err)
+	}
+	if decoded["wasted_bytes"] != float64(1210) || decoded["hash_algorithm"] != DefaultHashAlgorithm {
+		t.Errorf("unexpected JSON %s", data)
+	}
+
+	empty := NewIndexWithOptions(IndexOptions{RootPath: "/tmp"}).Stats()
+	if empty.FileCount != 0 || empty.DuplicateGroups != 0 || empty.LargestGroupHash != "" || empty.LargestGroupSize != 0 {
+		t.Errorf("expected empty stats for an empty index, got %+v", empty)
+	}
+}
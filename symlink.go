@@ -0,0 +1,23 @@
+package main
+
+import "fmt"
+
+// Ways of indexing symbolic links.
+const (
+	SymlinkRecord = "record" // Symlinks are indexed like regular files, by the content of their target; symlinks to directories are not followed.
+	SymlinkSkip   = "skip"   // Symlinks are ignored.
+	SymlinkFollow = "follow" // Symlinks to files are indexed like with SymlinkRecord, and symlinks to directories are followed.
+)
+
+// DefaultSymlinkMode is the way symbolic links are indexed when none is specified.
+const DefaultSymlinkMode = SymlinkRecord
+
+// checkSymlinkMode returns an error if mode is not a way of indexing symbolic links.
+func checkSymlinkMode(mode string) error {
+	switch mode {
+	case SymlinkRecord, SymlinkSkip, SymlinkFollow:
+		return nil
+	default:
+		return fmt.Errorf("unknown symlink mode '%s' (expected 'follow', 'skip' or 'record')", mode)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// longPath returns the path unchanged, as there is no path length limit to work around outside of Windows.
+func longPath(p string) string {
+	return p
+}
+
+// fileID returns the inode number of the file at path, read from its file information, to detect the directories
+// visited several times through symlinks.
+func fileID(path string, info os.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Ino), true
+}
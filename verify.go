@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// VerifyAgainstResult is the result of checking the indexed hashes against a list of checksums.
+type VerifyAgainstResult struct {
+	OK         int
+	Mismatched []string // Paths whose indexed hash differs from the checksum.
+	Missing    []string // Paths of checksums that are not indexed.
+}
+
+// HasProblems returns true if some checksums do not match the indexed hashes or are for files that are not indexed.
+func (r *VerifyAgainstResult) HasProblems() bool {
+	return len(r.Mismatched) > 0 || len(r.Missing) > 0
+}
+
+// VerifyAgainst checks the indexed hashes against checksums in the format of sha256sum, e.g. downloaded with the
+// files: one "<hash>  <path>" line per file, the path being relative to the indexed directory. Lines of files
+// read in binary mode ("<hash> *<path>") are accepted too, and empty lines are skipped. The files themselves are
+// not read, the checksums are compared with the hashes stored in the index.
+// The index must be loaded before calling this method.
+func (idx *Index) VerifyAgainst(r io.Reader) (*VerifyAgainstResult, error) {
+	hasher, err := newHasher(idx.HashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	hashLength := hex.EncodedLen(hasher.Size())
+
+	hashes := make(map[string]string)
+	_ = idx.WalkFiles(func(fi *FileInfo, hash string) error {
+		hashes[filepath.ToSlash(fi.Path)] = hash
+		return nil
+	})
+
+	result := &VerifyAgainstResult{Mismatched: []string{}, Missing: []string{}}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+
+		hash, path, found := strings.Cut(text, " ")
+		path = strings.TrimPrefix(strings.TrimPrefix(path, " "), "*")
+		hash = strings.ToLower(hash)
+		if !found || path == "" {
+			return nil, fmt.Errorf("line %d: expected \"<hash>  <path>\"", line)
+		}
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != hashLength {
+			return nil, fmt.Errorf("line %d: invalid %s hash %q", line, idx.HashAlgorithm, hash)
+		}
+
+		path = filepath.ToSlash(filepath.Clean(filepath.FromSlash(path)))
+		indexedHash, indexed := hashes[path]
+		switch {
+		case !indexed:
+			result.Missing = append(result.Missing, path)
+		case indexedHash != hash:
+			result.Mismatched = append(result.Mismatched, path)
+		default:
+			result.OK++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read checksums: %w", err)
+	}
+
+	return result, nil
+}
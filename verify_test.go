@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyAgainst(t *testing.T) {
+	testDir := t.TempDir()
+	files := map[string]string{
+		"a.txt":        "content a",
+		"b.txt":        "content b",
+		"sub/file.txt": "content of sub",
+	}
+	for path, content := range files {
+		fullPath := filepath.Join(testDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.Index(); err != nil {
+		t.Fatalf("failed to index: %v", err)
+	}
+
+	var checksums bytes.Buffer
+	checksumIdx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := checksumIdx.PrintChecksums(context.Background(), &checksums); err != nil {
+		t.Fatalf("PrintChecksums failed: %v", err)
+	}
+
+	result, err := idx.VerifyAgainst(strings.NewReader(checksums.String()))
+	if err != nil {
+		t.Fatalf("VerifyAgainst failed: %v", err)
+	}
+	if result.OK != 3 || result.HasProblems() {
+		t.Errorf("expected 3 OK files, got %+v", result)
+	}
+
+	modified := strings.Replace(checksums.String(), computeHash([]byte("content b")), computeHash([]byte("other")), 1)
+	modified += computeHash([]byte("missing")) + " *missing.txt\n"
+	result, err = idx.VerifyAgainst(strings.NewReader(modified))
+	if err != nil {
+		t.Fatalf("VerifyAgainst failed: %v", err)
+	}
+	if result.OK != 2 {
+		t.Errorf("expected 2 OK files, got %d", result.OK)
+	}
+	if len(result.Mismatched) != 1 || result.Mismatched[0] != "b.txt" {
+		t.Errorf("expected b.txt mismatched, got %v", result.Mismatched)
+	}
+	if len(result.Missing) != 1 || result.Missing[0] != "missing.txt" {
+		t.Errorf("expected missing.txt missing, got %v", result.Missing)
+	}
+
+	if _, err := idx.VerifyAgainst(strings.NewReader("not a checksum line\n")); err == nil {
+		t.Error("expected an error for an invalid line")
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultDebounceDelay is the time a path must be stable before its changes are processed by Watch.
+const DefaultDebounceDelay = 500 * time.Millisecond
+
+// debouncer delays calls to a function for a key until no new trigger happened for this key during the delay.
+type debouncer struct {
+	delay    time.Duration
+	fn       func(key string)
+	mu       sync.Mutex
+	pending  map[string]*time.Timer
+	running  sync.WaitGroup
+	stopping bool
+}
+
+func newDebouncer(delay time.Duration, fn func(key string)) *debouncer {
+	return &debouncer{
+		delay:   delay,
+		fn:      fn,
+		pending: make(map[string]*time.Timer),
+	}
+}
+
+// trigger schedules a call for the key, cancelling and replacing the one still pending for it if any.
+func (d *debouncer) trigger(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopping {
+		return
+	}
+
+	if timer, exists := d.pending[key]; exists {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(d.delay, func() {
+		d.mu.Lock()
+		// The timer may have fired while being replaced by a new trigger, in which case the new one wins.
+		if d.stopping || d.pending[key] != timer {
+			d.mu.Unlock()
+			return
+		}
+		delete(d.pending, key)
+		d.running.Add(1)
+		d.mu.Unlock()
+
+		defer d.running.Done()
+		d.fn(key)
+	})
+	d.pending[key] = timer
+}
+
+// stop cancels all pending calls and waits for the running ones to finish.
+func (d *debouncer) stop() {
+	d.mu.Lock()
+	d.stopping = true
+	for key, timer := range d.pending {
+		timer.Stop()
+		delete(d.pending, key)
+	}
+	d.mu.Unlock()
+
+	d.running.Wait()
+}
+
+// IndexLoop indexes the directory, then again each time the interval has elapsed, until the context is cancelled.
+// It writes the result of each run to out, and between runs the time left until the next one once per second,
+// e.g. "Next index in 4m59s...". A run in progress when the context is cancelled is finished and saved.
+func (idx *Index) IndexLoop(ctx context.Context, interval time.Duration, out io.Writer) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	countdown := time.NewTicker(time.Second)
+	defer countdown.Stop()
+
+	for {
+		// Each run indexes the directory from scratch, like a new index.
+		idx.FilesByContentHash = make(map[string][]*FileInfo)
+		result, err := idx.IndexWithContext(context.WithoutCancel(ctx))
+		if err != nil {
+			return err
+		}
+		for _, err := range result.Errors {
+			fmt.Fprintf(out, "Warning: %v\n", err)
+		}
+		printIndexResult(out, result)
+
+		next := time.Now().Add(interval)
+		ticker.Reset(interval)
+		countdown.Reset(time.Second)
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				break wait
+			case <-countdown.C:
+				fmt.Fprintf(out, "Next index in %s...\n", time.Until(next).Round(time.Second))
+			}
+		}
+	}
+}
+
+// Watch watches the directory for changes and keeps the index file up to date until done is closed.
+// Changes to a path are only processed once the path has been stable for DebounceDelay, after which
+// onUpdate is called with the relative path and the error that occurred while updating it, if any.
+// The index must be loaded before calling this method.
+func (idx *Index) Watch(done <-chan struct{}, onUpdate func(relPath string, err error)) error {
+	if idx.isArchive() {
+		return fmt.Errorf("cannot watch %s: %w", idx.AbsPath, errArchiveIndex)
+	}
+
+	// The .gitignore and .bffignore files are only read once, like for a scan.
+	gitignore, err := idx.loadGitignore()
+	if err != nil {
+		return err
+	}
+	if err := idx.LoadIgnoreFile(); err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	// Changes are processed from the debouncer timers, so updates of the index must be serialized.
+	var mu sync.Mutex
+	debounce := newDebouncer(idx.DebounceDelay, func(path string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		relPath, err := filepath.Rel(idx.AbsPath, path)
+		if err == nil {
+			err = idx.updatePath(relPath, gitignore)
+		}
+		if err == nil {
+			err = idx.save()
+		}
+		if onUpdate != nil {
+			onUpdate(relPath, err)
+		}
+	})
+	defer debounce.stop()
+
+	if err := idx.watchDir(watcher, idx.AbsPath, nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-done:
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if idx.isIndexFile(event.Name) {
+				continue
+			}
+
+			// New directories must be watched too, and may already contain files when moved in.
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := idx.watchDir(watcher, event.Name, debounce.trigger); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+
+			debounce.trigger(event.Name)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("watch error: %w", err)
+		}
+	}
+}
+
+// watchDir adds the directory and its subdirectories to the watcher, skipping hidden ones unless they are included.
+// If onFile is not nil, it is called with the path of each file found.
+func (idx *Index) watchDir(watcher *fsnotify.Watcher, dir string, onFile func(path string)) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("walk error at %s: %w", path, err)
+		}
+
+		if !idx.IncludeHidden && path != idx.AbsPath && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !info.IsDir() {
+			if onFile != nil {
+				onFile(path)
+			}
+			return nil
+		}
+
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// updatePath refreshes the index entry of the file at the given relative path, unless it is excluded like when
+// scanning, including by the patterns of gitignore if not nil.
+// Entries are removed if the path no longer exists, including the ones of the files it contained if it was a directory.
+func (idx *Index) updatePath(relPath string, gitignore *gitignoreParser) error {
+	idx.removePath(relPath)
+
+	absPath := filepath.Join(idx.AbsPath, relPath)
+	info, err := os.Lstat(longPath(absPath))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", absPath, err)
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		switch idx.SymlinkMode {
+		case SymlinkSkip:
+			return nil
+		case SymlinkFollow:
+			if info, err = os.Stat(longPath(absPath)); err != nil {
+				return fmt.Errorf("failed to follow symlink %s: %w", absPath, err)
+			}
+		}
+	}
+
+	if info.IsDir() || idx.isHidden(relPath) || (gitignore != nil && gitignore.matches(relPath, false)) || idx.isIgnored(relPath) || !idx.isSelected(relPath, info) {
+		return nil
+	}
+
+	hash, fileInfo, err := idx.processFile(longPath(absPath), relPath)
+	if err != nil {
+		return fmt.Errorf("failed to process %s: %w", absPath, err)
+	}
+
+	idx.FilesByContentHash[hash] = append(idx.FilesByContentHash[hash], fileInfo)
+
+	return nil
+}
+
+// removePath removes the entry of the given relative path from the index, as well as the entries located under it.
+func (idx *Index) removePath(relPath string) {
+	for hash, files := range idx.FilesByContentHash {
+		kept := files[:0]
+		for _, file := range files {
+			if file.Path != relPath && !isUnderDir(file.Path, relPath) {
+				kept = append(kept, file)
+			}
+		}
+
+		if len(kept) == 0 {
+			delete(idx.FilesByContentHash, hash)
+		} else {
+			idx.FilesByContentHash[hash] = kept
+		}
+	}
+}
+
+// isHidden returns true if the relative path is hidden and hidden files are not included,
+// i.e. if the file or one of its parent directories starts with a dot.
+func (idx *Index) isHidden(relPath string) bool {
+	if idx.IncludeHidden {
+		return false
+	}
+
+	for _, part := range strings.Split(relPath, string(filepath.Separator)) {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
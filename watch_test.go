@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDebouncer(t *testing.T) {
+	var calls atomic.Int32
+	d := newDebouncer(50*time.Millisecond, func(key string) {
+		calls.Add(1)
+	})
+	defer d.stop()
+
+	for i := 0; i < 20; i++ {
+		d.trigger("file.txt")
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected callback to fire once after rapid triggers, got %d", got)
+	}
+
+	d.trigger("file.txt")
+	d.trigger("other.txt")
+	time.Sleep(200 * time.Millisecond)
+
+	if got := calls.Load(); got != 3 {
+		t.Errorf("expected callback to fire once per key, got %d calls in total", got)
+	}
+}
+
+func TestDebouncerStop(t *testing.T) {
+	var calls atomic.Int32
+	d := newDebouncer(50*time.Millisecond, func(key string) {
+		calls.Add(1)
+	})
+
+	d.trigger("file.txt")
+	d.stop()
+	time.Sleep(100 * time.Millisecond)
+
+	if got := calls.Load(); got != 0 {
+		t.Errorf("expected no callback after stop, got %d", got)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	testDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(testDir, "existing.txt"), []byte("content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.IndexWithResult(); err != nil {
+		t.Fatalf("indexing failed: %v", err)
+	}
+	idx.DebounceDelay = 20 * time.Millisecond
+
+	updates := make(chan string, 10)
+	done := make(chan struct{})
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- idx.Watch(done, func(relPath string, err error) {
+			if err != nil {
+				t.Errorf("update of %s failed: %v", relPath, err)
+			}
+			updates <- relPath
+		})
+	}()
+
+	// Give the watcher time to register the directories.
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		if err := os.WriteFile(filepath.Join(testDir, "new.txt"), []byte("content"), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	select {
+	case relPath := <-updates:
+		if relPath != "new.txt" {
+			t.Errorf("expected update for new.txt, got %s", relPath)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for update")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	close(done)
+	if err := <-watchErr; err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+
+	if len(updates) != 0 {
+		t.Errorf("expected a single update for rapid writes, got %d more", len(updates))
+	}
+
+	loaded := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	matches, err := loaded.FindDuplicates("new.txt")
+	if err != nil {
+		t.Fatalf("expected new.txt to be saved in the index: %v", err)
+	}
+	if len(matches) != 2 {
+		t.Errorf("expected new.txt to be a duplicate of existing.txt, got %v", matches)
+	}
+}
+
+func TestUpdatePath(t *testing.T) {
+	testDir := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(testDir, "dir"), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	for _, path := range []string{"file.txt", "dir/a.txt", "dir/b.txt"} {
+		if err := os.WriteFile(filepath.Join(testDir, path), []byte(path), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	if _, err := idx.scan(context.Background()); err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(testDir, "file.txt"), []byte("modified"), 0644); err != nil {
+		t.Fatalf("failed to modify file: %v", err)
+	}
+	if err := idx.updatePath("file.txt", nil); err != nil {
+		t.Fatalf("updatePath() failed: %v", err)
+	}
+	if hash, _ := idx.hashOf("file.txt"); hash != computeHash([]byte("modified")) {
+		t.Errorf("expected file.txt hash to be updated")
+	}
+
+	if err := os.RemoveAll(filepath.Join(testDir, "dir")); err != nil {
+		t.Fatalf("failed to remove directory: %v", err)
+	}
+	if err := idx.updatePath("dir", nil); err != nil {
+		t.Fatalf("updatePath() failed: %v", err)
+	}
+	for _, path := range []string{"dir/a.txt", "dir/b.txt"} {
+		if _, found := idx.hashOf(path); found {
+			t.Errorf("expected %s to be removed from the index", path)
+		}
+	}
+	if len(idx.FilesByContentHash) != 1 {
+		t.Errorf("expected 1 hash left in the index, got %d", len(idx.FilesByContentHash))
+	}
+}
+
+func TestUpdatePathGitignore(t *testing.T) {
+	testDir := t.TempDir()
+	files := map[string]string{GitignoreFile: "*.log\n", "kept.txt": "kept"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	idx.UseGitignore = true
+	gitignore, err := idx.loadGitignore()
+	if err != nil {
+		t.Fatalf("loadGitignore() failed: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(testDir, "debug.log"), []byte("log"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	for _, path := range []string{"kept.txt", "debug.log"} {
+		if err := idx.updatePath(path, gitignore); err != nil {
+			t.Fatalf("updatePath(%s) failed: %v", path, err)
+		}
+	}
+
+	if _, found := idx.hashOf("kept.txt"); !found {
+		t.Error("expected kept.txt to be indexed")
+	}
+	if _, found := idx.hashOf("debug.log"); found {
+		t.Error("expected debug.log to be excluded by the .gitignore file")
+	}
+}
+
+func TestUpdatePathSymlinkModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks requires privileges on Windows")
+	}
+
+	testDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(testDir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a.txt", filepath.Join(testDir, "file.lnk")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	tests := []struct {
+		mode    string
+		indexed bool
+	}{
+		{SymlinkRecord, true},
+		{SymlinkSkip, false},
+		{SymlinkFollow, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+			idx.SymlinkMode = tt.mode
+			if err := idx.updatePath("file.lnk", nil); err != nil {
+				t.Fatalf("updatePath() failed: %v", err)
+			}
+			if _, found := idx.hashOf("file.lnk"); found != tt.indexed {
+				t.Errorf("expected file.lnk indexed = %v with %s, got %v", tt.indexed, tt.mode, found)
+			}
+		})
+	}
+}
+
+func TestIndexLoop(t *testing.T) {
+	testDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(testDir, "file.txt"), []byte("content"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	idx := NewIndexWithOptions(IndexOptions{RootPath: testDir})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var out bytes.Buffer
+	if err := idx.IndexLoop(ctx, time.Millisecond, &out); err != nil {
+		t.Fatalf("IndexLoop failed: %v", err)
+	}
+
+	if runs := strings.Count(out.String(), "Indexed 1 files"); runs < 2 {
+		t.Errorf("expected at least 2 runs, got %d: %q", runs, out.String())
+	}
+	if idx.FileCount() != 1 {
+		t.Errorf("expected 1 indexed file after several runs, got %d", idx.FileCount())
+	}
+}
@@ -0,0 +1,51 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"strings"
+	"syscall"
+)
+
+// maxShortPathLength is the length above which paths get the long path prefix, a bit below
+// the MAX_PATH limit of 260 characters to leave room for the terminating characters.
+const maxShortPathLength = 250
+
+// longPath returns the absolute path with the \\?\ prefix if it is too long for the Windows APIs,
+// so that files in deeply nested directories can be opened.
+func longPath(p string) string {
+	if len(p) <= maxShortPathLength || strings.HasPrefix(p, `\\?\`) {
+		return p
+	}
+
+	// UNC paths (\\server\share\...) have their own form of the prefix.
+	if strings.HasPrefix(p, `\\`) {
+		return `\\?\UNC\` + p[2:]
+	}
+	return `\\?\` + p
+}
+
+// fileID returns the file index of the file at path, unique on its volume, to detect the directories visited several
+// times through symlinks. The file information of the walked files doesn't contain it on Windows, so the file is
+// opened to read it, following the symlinks.
+func fileID(path string, info os.FileInfo) (uint64, bool) {
+	pathPtr, err := syscall.UTF16PtrFromString(longPath(path))
+	if err != nil {
+		return 0, false
+	}
+
+	// FILE_FLAG_BACKUP_SEMANTICS is required to open directories.
+	handle, err := syscall.CreateFile(pathPtr, 0, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return 0, false
+	}
+	defer syscall.CloseHandle(handle)
+
+	var data syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(handle, &data); err != nil {
+		return 0, false
+	}
+	return uint64(data.FileIndexHigh)<<32 | uint64(data.FileIndexLow), true
+}